@@ -0,0 +1,263 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+// cc-collector-replay runs a metric collector on a fixture recorded with the
+// -record option of cc-metric-collector. It prints the messages of each tick in
+// line protocol format without timestamps. With -update, the messages are
+// stored as expected output in the fixture, with -check, the messages are
+// compared to the expected output, so regressions of collectors can be
+// detected without access to the systems they were recorded on.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/collectors"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+)
+
+// toLines returns the messages in line protocol format without timestamps,
+// sorted to be independent of the order of the messages
+func toLines(messages []lp.CCMessage, metaAsTags map[string]bool) []string {
+	lines := make([]string, 0, len(messages))
+	for _, m := range messages {
+		line := strings.TrimSpace(m.ToLineProtocol(metaAsTags))
+		if i := strings.LastIndexByte(line, ' '); i > 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// seriesKey identifies a message by name and tags
+func seriesKey(m lp.CCMessage) string {
+	tags := m.Tags()
+	var b strings.Builder
+	b.WriteString(m.Name())
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		fmt.Fprintf(&b, ",%s=%s", k, tags[k])
+	}
+	return b.String()
+}
+
+// equalValue compares two field values. Numbers may differ by the relative
+// tolerance, e.g. for rates derived from the wall clock time.
+func equalValue(a, b any, tolerance float64) bool {
+	toFloat := func(v any) (float64, bool) {
+		switch x := v.(type) {
+		case float64:
+			return x, true
+		case int64:
+			return float64(x), true
+		case uint64:
+			return float64(x), true
+		}
+		return 0, false
+	}
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if !okA || !okB {
+		return a == b
+	}
+	if fa == fb {
+		return true
+	}
+	return math.Abs(fa-fb) <= tolerance*math.Max(math.Abs(fa), math.Abs(fb))
+}
+
+// compare checks the lines of a tick against the expected lines and returns
+// the differences
+func compare(lines, expected []string, tolerance float64) ([]string, error) {
+	decode := func(lines []string) (map[string][]lp.CCMessage, error) {
+		var b strings.Builder
+		for _, l := range lines {
+			// Line protocol messages require a timestamp
+			fmt.Fprintf(&b, "%s 1\n", l)
+		}
+		messages, err := lp.FromBytes([]byte(b.String()))
+		if err != nil {
+			return nil, err
+		}
+		series := make(map[string][]lp.CCMessage)
+		for _, m := range messages {
+			key := seriesKey(m)
+			series[key] = append(series[key], m)
+		}
+		return series, nil
+	}
+	got, err := decode(lines)
+	if err != nil {
+		return nil, err
+	}
+	want, err := decode(expected)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected output: %w", err)
+	}
+
+	diffs := make([]string, 0)
+	for _, key := range slices.Sorted(maps.Keys(want)) {
+		g, w := got[key], want[key]
+		if len(g) != len(w) {
+			diffs = append(diffs, fmt.Sprintf("%s: got %d messages, expected %d", key, len(g), len(w)))
+			continue
+		}
+		for i := range w {
+			for field, wv := range w[i].Fields() {
+				gv, ok := g[i].GetField(field)
+				if !ok {
+					diffs = append(diffs, fmt.Sprintf("%s: missing field '%s'", key, field))
+				} else if !equalValue(gv, wv, tolerance) {
+					diffs = append(diffs, fmt.Sprintf("%s: field '%s' is %v, expected %v", key, field, gv, wv))
+				}
+			}
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(got)) {
+		if _, ok := want[key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected message", key))
+		}
+	}
+	return diffs, nil
+}
+
+func mainFunc() int {
+	fixtureDir := flag.String("fixture", "", "Path to fixture directory recorded with 'cc-metric-collector -record'")
+	collectorName := flag.String("collector", "", "Name of the collector to replay")
+	configFile := flag.String("config", "", "Path to collectors configuration file (default: empty collector configuration)")
+	intervalString := flag.String("interval", "1s", "Interval of the simulated clock between the replayed ticks")
+	durationString := flag.String("duration", "1s", "Duration passed to the collector like in the configuration file")
+	metaAsTagsString := flag.String("meta_as_tags", "", "Comma separated list of meta keys printed as tags")
+	update := flag.Bool("update", false, "Store the output as expected output in the fixture")
+	check := flag.Bool("check", false, "Compare the output with the expected output in the fixture")
+	tolerance := flag.Float64("tolerance", 0, "Relative tolerance for numeric values with -check")
+	loglevel := flag.String("loglevel", "warn", "Set log level")
+	flag.Parse()
+
+	cclog.Init(*loglevel, false)
+
+	if len(*fixtureDir) == 0 || len(*collectorName) == 0 {
+		cclog.Error("Options -fixture and -collector are required")
+		return 1
+	}
+	if *update && *check {
+		cclog.Error("Options -update and -check are exclusive")
+		return 1
+	}
+	interval, err := time.ParseDuration(*intervalString)
+	if err != nil || interval < 0 {
+		cclog.Errorf("Invalid interval '%s'", *intervalString)
+		return 1
+	}
+	duration, err := time.ParseDuration(*durationString)
+	if err != nil || duration < 0 {
+		cclog.Errorf("Invalid duration '%s'", *durationString)
+		return 1
+	}
+
+	metaAsTags := make(map[string]bool)
+	for key := range strings.SplitSeq(*metaAsTagsString, ",") {
+		if key = strings.TrimSpace(key); len(key) > 0 {
+			metaAsTags[key] = true
+		}
+	}
+
+	// The collector configuration is taken from the collectors configuration file
+	config := json.RawMessage("{}")
+	if len(*configFile) > 0 {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			cclog.Errorf("Failed to read collectors configuration '%s': %v", *configFile, err)
+			return 1
+		}
+		var collectorConfigs map[string]json.RawMessage
+		if err := json.Unmarshal(data, &collectorConfigs); err != nil {
+			cclog.Errorf("Failed to decode collectors configuration '%s': %v", *configFile, err)
+			return 1
+		}
+		if c, ok := collectorConfigs[*collectorName]; ok {
+			config = c
+		}
+	}
+
+	results, err := collectors.ReplayCollector(*collectorName, config, *fixtureDir, time.Now(), interval, duration)
+	if err != nil {
+		cclog.Error(err.Error())
+		return 1
+	}
+
+	failed := false
+	for i, messages := range results {
+		tick := i + 1
+		lines := toLines(messages, metaAsTags)
+		expectedFile := filepath.Join(hostfs.FixtureTickDir(*fixtureDir, tick), *collectorName+".expected")
+		switch {
+		case *update:
+			data := strings.Join(lines, "\n")
+			if len(lines) > 0 {
+				data += "\n"
+			}
+			if err := os.WriteFile(expectedFile, []byte(data), 0o644); err != nil {
+				cclog.Errorf("Failed to write expected output '%s': %v", expectedFile, err)
+				return 1
+			}
+		case *check:
+			data, err := os.ReadFile(expectedFile)
+			if err != nil {
+				cclog.Errorf("Failed to read expected output '%s': %v", expectedFile, err)
+				failed = true
+				continue
+			}
+			expected := make([]string, 0)
+			for l := range strings.SplitSeq(string(data), "\n") {
+				if l = strings.TrimSpace(l); len(l) > 0 {
+					expected = append(expected, l)
+				}
+			}
+			diffs, err := compare(lines, expected, *tolerance)
+			if err != nil {
+				cclog.Errorf("Tick %d: %v", tick, err)
+				failed = true
+				continue
+			}
+			for _, d := range diffs {
+				fmt.Printf("tick %d: %s\n", tick, d)
+			}
+			if len(diffs) > 0 {
+				failed = true
+			}
+		default:
+			fmt.Printf("# tick %d\n", tick)
+			for _, l := range lines {
+				fmt.Println(l)
+			}
+		}
+	}
+	if failed {
+		return 1
+	}
+	if *check {
+		fmt.Printf("%s: %d ticks match the expected output\n", *collectorName, len(results))
+	}
+	return 0
+}
+
+func main() {
+	os.Exit(mainFunc())
+}
@@ -0,0 +1,141 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+// cc-router-tester applies a metric router configuration offline to messages
+// in line protocol format, e.g. captured by a stdout sink. It runs the message
+// processor, the MetricCache and the MetricAggregator with simulated ticks and
+// prints the resulting messages. Errors in the expressions are reported in the
+// log output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mr "github.com/ClusterCockpit/cc-metric-collector/internal/metricRouter"
+)
+
+func mainFunc() int {
+	routerFile := flag.String("router", "./router.json", "Path to metric router configuration file")
+	inputFile := flag.String("input", "-", "Path to line protocol input file ('-' for stdin)")
+	intervalString := flag.String("interval", "10s", "Interval of the simulated ticks")
+	metaAsTagsString := flag.String("meta_as_tags", "", "Comma separated list of meta keys printed as tags")
+	onlyAggregates := flag.Bool("only_aggregates", false, "Print only the messages derived by interval_aggregates")
+	loglevel := flag.String("loglevel", "warn", "Set log level")
+	flag.Parse()
+
+	cclog.Init(*loglevel, false)
+
+	interval, err := time.ParseDuration(*intervalString)
+	if err != nil || interval <= 0 {
+		cclog.Errorf("Invalid interval '%s'", *intervalString)
+		return 1
+	}
+
+	metaAsTags := make(map[string]bool)
+	for key := range strings.SplitSeq(*metaAsTagsString, ",") {
+		if key = strings.TrimSpace(key); len(key) > 0 {
+			metaAsTags[key] = true
+		}
+	}
+
+	routerConfig, err := os.ReadFile(*routerFile)
+	if err != nil {
+		cclog.Errorf("Failed to read router configuration '%s': %v", *routerFile, err)
+		return 1
+	}
+	router, err := mr.NewOffline(routerConfig)
+	if err != nil {
+		cclog.Errorf("Failed to initialize router with configuration '%s': %v", *routerFile, err)
+		return 1
+	}
+
+	var input []byte
+	if *inputFile == "-" {
+		input, err = io.ReadAll(os.Stdin)
+	} else {
+		input, err = os.ReadFile(*inputFile)
+	}
+	if err != nil {
+		cclog.Errorf("Failed to read input '%s': %v", *inputFile, err)
+		return 1
+	}
+	messages, err := lp.FromBytes(input)
+	if err != nil {
+		cclog.Errorf("Failed to decode input '%s': %v", *inputFile, err)
+		return 1
+	}
+	if len(messages) == 0 {
+		cclog.Warn("No messages in input")
+		return 0
+	}
+
+	// Simulate the ticker along the timestamps of the messages
+	slices.SortStableFunc(messages, func(a, b lp.CCMessage) int {
+		return a.Time().Compare(b.Time())
+	})
+	start := messages[0].Time().Truncate(interval)
+	next := start.Add(interval)
+	router.SetTimestamp(start)
+
+	tick := func(t time.Time) {
+		derived, err := router.Tick(t)
+		if err != nil {
+			cclog.Errorf("Tick %s: %v", t.Format(time.RFC3339), err)
+		}
+		for _, m := range derived {
+			fmt.Print(m.ToLineProtocol(metaAsTags))
+		}
+	}
+
+	for _, p := range messages {
+		for !p.Time().Before(next) {
+			tick(next)
+			next = next.Add(interval)
+		}
+		out, err := router.Process(p)
+		if err != nil {
+			cclog.Errorf("Processing '%s': %v", p.Name(), err)
+			continue
+		}
+		if *onlyAggregates {
+			continue
+		}
+		for _, m := range out {
+			fmt.Print(m.ToLineProtocol(metaAsTags))
+		}
+	}
+	// Evaluate the last interval
+	tick(next)
+
+	for name, skipped := range router.SkippedMessages() {
+		if skipped > 0 {
+			cclog.Warnf("Aggregation '%s' skipped %d matching messages without valid value", name, skipped)
+		}
+	}
+	for name, dropped := range router.DroppedMessages() {
+		if dropped > 0 {
+			cclog.Warnf("Aggregation '%s' dropped %d derived messages because the output channel was full", name, dropped)
+		}
+	}
+	if evicted := router.EvictedMessages(); evicted > 0 {
+		cclog.Warnf("MetricCache evicted %d messages because intervals reached cache_max_messages", evicted)
+	}
+
+	return 0
+}
+
+func main() {
+	os.Exit(mainFunc())
+}
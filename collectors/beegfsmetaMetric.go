@@ -12,9 +12,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
 	"os/user"
 	"regexp"
 	"slices"
@@ -24,6 +21,8 @@ import (
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const DEFAULT_BEEGFS_CMD = "beegfs-ctl"
@@ -31,17 +30,16 @@ const DEFAULT_BEEGFS_CMD = "beegfs-ctl"
 // Struct for the collector-specific JSON config
 type BeegfsMetaCollectorConfig struct {
 	Beegfs             string   `json:"beegfs_path"`
-	ExcludeMetrics     []string `json:"exclude_metrics,omitempty"`
-	ExcludeFilesystems []string `json:"exclude_filesystem"`
+	ExcludeFilesystems []string `json:"exclude_filesystem"` // Alias of exclude_devices
+	MetricFilter
 }
 
 type BeegfsMetaCollector struct {
-	metricCollector
+	BaseCollector
 
 	tags    map[string]string
 	matches map[string]string
 	config  BeegfsMetaCollectorConfig
-	skipFS  map[string]struct{}
 }
 
 func (m *BeegfsMetaCollector) Init(config json.RawMessage) error {
@@ -80,11 +78,17 @@ func (m *BeegfsMetaCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	// exclude_filesystem is an alias of exclude_devices
+	m.config.ExcludeDevices = append(m.config.ExcludeDevices, m.config.ExcludeFilesystems...)
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	// Create map with possible variables
 	m.matches = make(map[string]string)
 	for _, value := range nodeMdstat_array {
-		if slices.Contains(m.config.ExcludeMetrics, value) {
+		// Excluded metrics may also be given without prefix
+		if m.config.IsMetricExcluded("beegfs_cmeta_"+value) || slices.Contains(m.config.ExcludeMetrics, value) {
 			m.matches["other"] = "0"
 		} else {
 			m.matches["beegfs_cmeta_"+value] = "0"
@@ -99,10 +103,6 @@ func (m *BeegfsMetaCollector) Init(config json.RawMessage) error {
 		"type":       "node",
 		"filesystem": "",
 	}
-	m.skipFS = make(map[string]struct{})
-	for _, fs := range m.config.ExcludeFilesystems {
-		m.skipFS[fs] = struct{}{}
-	}
 
 	// Beegfs file system statistics can only be queried by user root
 	user, err := user.Current()
@@ -114,7 +114,7 @@ func (m *BeegfsMetaCollector) Init(config json.RawMessage) error {
 	}
 
 	// Check if beegfs-ctl is in executable search path
-	_, err = exec.LookPath(m.config.Beegfs)
+	_, err = hostfs.LookPath(m.config.Beegfs)
 	if err != nil {
 		return fmt.Errorf("%s Init(): Failed to find beegfs-ctl binary '%s': %w", m.name, m.config.Beegfs, err)
 	}
@@ -127,7 +127,7 @@ func (m *BeegfsMetaCollector) Read(interval time.Duration, output chan lp.CCMess
 		return
 	}
 	// Get mounpoint
-	buffer, _ := os.ReadFile(string("/proc/mounts"))
+	buffer, _ := hostfs.ReadFile("/proc/mounts")
 	mounts := strings.Split(string(buffer), "\n")
 	var mountpoints []string
 	for _, line := range mounts {
@@ -137,7 +137,7 @@ func (m *BeegfsMetaCollector) Read(interval time.Duration, output chan lp.CCMess
 		f := strings.Fields(line)
 		if strings.Contains(f[0], "beegfs_ondemand") {
 			// Skip excluded filesystems
-			if _, skip := m.skipFS[f[1]]; skip {
+			if m.config.IsDeviceExcluded(f[1]) {
 				continue
 			}
 			mountpoints = append(mountpoints, f[1])
@@ -157,28 +157,22 @@ func (m *BeegfsMetaCollector) Read(interval time.Duration, output chan lp.CCMess
 		// --interval:
 		// --mount=/mnt/beeond/: Which mount point
 		mountoption := "--mount=" + mountpoint
-		cmd := exec.Command(m.config.Beegfs, "--clientstats",
+		cmd := hostfs.Command(m.config.Beegfs, "--clientstats",
 			"--nodetype=meta", mountoption, "--allstats")
-		cmd.Stdin = strings.NewReader("\n")
-		cmdStdout := new(bytes.Buffer)
-		cmdStderr := new(bytes.Buffer)
-		cmd.Stdout = cmdStdout
-		cmd.Stderr = cmdStderr
-		err := cmd.Run()
+		cmd.Stdin = "\n"
+		dataStdOut, dataStdErr, err := cmd.Run()
 		if err != nil {
-			dataStdErr, _ := io.ReadAll(cmdStderr)
-			dataStdOut, _ := io.ReadAll(cmdStdout)
 			cclog.ComponentError(
 				m.name,
 				fmt.Sprintf("Read(): Failed to execute command \"%s\": %v\n", cmd.String(), err),
-				fmt.Sprintf("Read(): command exit code: \"%d\"\n", cmd.ProcessState.ExitCode()),
+				fmt.Sprintf("Read(): command exit code: \"%d\"\n", hostfs.ExitCode(err)),
 				fmt.Sprintf("Read(): command stderr: \"%s\"\n", string(dataStdErr)),
 				fmt.Sprintf("Read(): command stdout: \"%s\"\n", string(dataStdOut)),
 			)
 			return
 		}
 		// Read I/O statistics
-		scanner := bufio.NewScanner(cmdStdout)
+		scanner := bufio.NewScanner(bytes.NewReader(dataStdOut))
 
 		sumLine := regexp.MustCompile(`^Sum:\s+\d+\s+\[[a-zA-Z]+\]+`)
 		statsLine := regexp.MustCompile(`^(.*?)\s+?(\d.*?)$`)
@@ -227,7 +221,7 @@ func (m *BeegfsMetaCollector) Read(interval time.Duration, output chan lp.CCMess
 
 			for key, data := range m.matches {
 				value, _ := strconv.ParseFloat(data, 32)
-				if y, err := lp.NewMetric(key, m.tags, m.meta, value, time.Now()); err == nil {
+				if y, err := lp.NewMetric(key, m.tags, m.meta, value, hostfs.Now()); err == nil {
 					output <- y
 				}
 			}
@@ -235,6 +229,13 @@ func (m *BeegfsMetaCollector) Read(interval time.Duration, output chan lp.CCMess
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *BeegfsMetaCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "beegfs_cmeta_*", Types: []string{"node"}, Kind: mc.Counter, Description: "BeeGFS client metadata operations of the type given by the suffix, e.g. beegfs_cmeta_open"},
+	}
+}
+
 func (m *BeegfsMetaCollector) Close() {
 	m.init = false
 }
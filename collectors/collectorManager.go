@@ -11,65 +11,113 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	mct "github.com/ClusterCockpit/cc-metric-collector/pkg/multiChanTicker"
 )
 
-// Map of all available metric collectors
-var AvailableCollectors = map[string]MetricCollector{
-	"likwid":          new(LikwidCollector),
-	"loadavg":         new(LoadavgCollector),
-	"memstat":         new(MemstatCollector),
-	"netstat":         new(NetstatCollector),
-	"ibstat":          new(InfinibandCollector),
-	"lustrestat":      new(LustreCollector),
-	"cpustat":         new(CpustatCollector),
-	"topprocs":        new(TopProcsCollector),
-	"nvidia":          new(NvidiaCollector),
-	"customcmd":       new(CustomCmdCollector),
-	"iostat":          new(IOstatCollector),
-	"diskstat":        new(DiskstatCollector),
-	"tempstat":        new(TempCollector),
-	"ipmistat":        new(IpmiCollector),
-	"gpfs":            new(GpfsCollector),
-	"cpufreq":         new(CPUFreqCollector),
-	"cpufreq_cpuinfo": new(CPUFreqCpuInfoCollector),
-	"nfs3stat":        new(Nfs3Collector),
-	"nfs4stat":        new(Nfs4Collector),
-	"numastats":       new(NUMAStatsCollector),
-	"beegfs_meta":     new(BeegfsMetaCollector),
-	"beegfs_storage":  new(BeegfsStorageCollector),
-	"rapl":            new(RAPLCollector),
-	"rocm_smi":        new(RocmSmiCollector),
-	"self":            new(SelfCollector),
-	"schedstat":       new(SchedstatCollector),
-	"nfsiostat":       new(NfsIOStatCollector),
-	"slurm_cgroup":    new(SlurmCgroupCollector),
-	"smartmon":        new(SmartMonCollector),
+// Map of all available metric collectors. Each entry creates a new instance
+// of the collector type.
+var AvailableCollectors = map[string]func() MetricCollector{
+	"likwid":          func() MetricCollector { return new(LikwidCollector) },
+	"loadavg":         func() MetricCollector { return new(LoadavgCollector) },
+	"memstat":         func() MetricCollector { return new(MemstatCollector) },
+	"netstat":         func() MetricCollector { return new(NetstatCollector) },
+	"netprotostat":    func() MetricCollector { return new(NetProtoStatCollector) },
+	"ibstat":          func() MetricCollector { return new(InfinibandCollector) },
+	"lustrestat":      func() MetricCollector { return new(LustreCollector) },
+	"cpustat":         func() MetricCollector { return new(CpustatCollector) },
+	"interrupts":      func() MetricCollector { return new(InterruptsCollector) },
+	"topprocs":        func() MetricCollector { return new(TopProcsCollector) },
+	"nvidia":          func() MetricCollector { return new(NvidiaCollector) },
+	"customcmd":       func() MetricCollector { return new(CustomCmdCollector) },
+	"exec_plugin":     func() MetricCollector { return new(ExecPluginCollector) },
+	"iostat":          func() MetricCollector { return new(IOstatCollector) },
+	"diskstat":        func() MetricCollector { return new(DiskstatCollector) },
+	"tempstat":        func() MetricCollector { return new(TempCollector) },
+	"hwmon":           func() MetricCollector { return new(HwmonCollector) },
+	"thermal":         func() MetricCollector { return new(ThermalCollector) },
+	"ipmistat":        func() MetricCollector { return new(IpmiCollector) },
+	"gpfs":            func() MetricCollector { return new(GpfsCollector) },
+	"cpufreq":         func() MetricCollector { return new(CPUFreqCollector) },
+	"cpufreq_cpuinfo": func() MetricCollector { return new(CPUFreqCpuInfoCollector) },
+	"cpuidle":         func() MetricCollector { return new(CPUIdleCollector) },
+	"nfs3stat":        func() MetricCollector { return new(Nfs3Collector) },
+	"nfs4stat":        func() MetricCollector { return new(Nfs4Collector) },
+	"numastats":       func() MetricCollector { return new(NUMAStatsCollector) },
+	"psi":             func() MetricCollector { return new(PSICollector) },
+	"vmstat":          func() MetricCollector { return new(VmstatCollector) },
+	"beegfs_meta":     func() MetricCollector { return new(BeegfsMetaCollector) },
+	"beegfs_storage":  func() MetricCollector { return new(BeegfsStorageCollector) },
+	"rapl":            func() MetricCollector { return new(RAPLCollector) },
+	"rocm_smi":        func() MetricCollector { return new(RocmSmiCollector) },
+	"self":            func() MetricCollector { return new(SelfCollector) },
+	"schedstat":       func() MetricCollector { return new(SchedstatCollector) },
+	"nfsiostat":       func() MetricCollector { return new(NfsIOStatCollector) },
+	"slurm_cgroup":    func() MetricCollector { return new(SlurmCgroupCollector) },
+	"smartmon":        func() MetricCollector { return new(SmartMonCollector) },
+	"topology":        func() MetricCollector { return new(TopologyCollector) },
+}
+
+// Register adds a metric collector type. The factory creates a new instance
+// for each configured collector of this type. Packages with collectors
+// outside of this repository call Register in their init() function, before
+// the collector manager is created.
+func Register(name string, factory func() MetricCollector) error {
+	if len(name) == 0 || factory == nil {
+		return fmt.Errorf("Register(): invalid collector type '%s'", name)
+	}
+	if _, exists := AvailableCollectors[name]; exists {
+		return fmt.Errorf("Register(): collector type '%s' already registered", name)
+	}
+	AvailableCollectors[name] = factory
+	return nil
+}
+
+// Collector types that can only be configured once, e.g. because they use
+// process-wide library state
+var singleInstanceCollectors = []string{"likwid"}
+
+// Catalog returns the metric catalog of all available metric collectors.
+// Collectors with metrics defined in their configuration, like likwid, only
+// list these metrics after initialization.
+func Catalog() *mc.Catalog {
+	collectors := make([]mc.Collector, 0, len(AvailableCollectors))
+	for name, newCollector := range AvailableCollectors {
+		collectors = append(collectors, mc.Collector{Name: name, Metrics: newCollector().Catalog()})
+	}
+	return mc.New(collectors)
 }
 
 // Metric collector manager data structure
 type collectorManager struct {
-	collectors   []MetricCollector          // List of metric collectors to read in parallel
-	serial       []MetricCollector          // List of metric collectors to read serially
-	output       chan lp.CCMessage          // Output channels
-	done         chan bool                  // channel to finish / stop metric collector manager
-	ticker       mct.MultiChanTicker        // periodically ticking once each interval
-	duration     time.Duration              // duration (for metrics that measure over a given duration)
-	wg           *sync.WaitGroup            // wait group for all goroutines in cc-metric-collector
-	config       map[string]json.RawMessage // json encoded config for collector manager
-	collector_wg sync.WaitGroup             // internally used wait group for the parallel reading of collector
-	parallel_run bool                       // Flag whether the collectors are currently read in parallel
+	collectors   []MetricCollector                      // List of metric collectors to read in parallel
+	serial       []MetricCollector                      // List of metric collectors to read serially
+	output       chan lp.CCMessage                      // Output channels
+	done         chan bool                              // channel to finish / stop metric collector manager
+	ticker       mct.MultiChanTicker                    // periodically ticking once each interval
+	duration     time.Duration                          // duration (for metrics that measure over a given duration)
+	wg           *sync.WaitGroup                        // wait group for all goroutines in cc-metric-collector
+	config       map[string]json.RawMessage             // json encoded config for collector manager
+	names        map[MetricCollector]string             // instance names of the collectors
+	settings     map[MetricCollector]*collectorSettings // common settings of the collectors
+	outputs      map[MetricCollector]chan lp.CCMessage  // output channels of collectors with settings
+	collector_wg sync.WaitGroup                         // internally used wait group for the parallel reading of collector
+	parallel_run bool                                   // Flag whether the collectors are currently read in parallel
 }
 
 // Metric collector manager access functions
 type CollectorManager interface {
 	Init(ticker mct.MultiChanTicker, duration time.Duration, wg *sync.WaitGroup, collectConfig json.RawMessage) error
 	AddOutput(output chan lp.CCMessage)
+	Catalog() *mc.Catalog
 	Start()
 	Close()
 }
@@ -89,6 +137,9 @@ func (cm *collectorManager) Init(ticker mct.MultiChanTicker, duration time.Durat
 	cm.wg = wg
 	cm.ticker = ticker
 	cm.duration = duration
+	cm.names = make(map[MetricCollector]string)
+	cm.settings = make(map[MetricCollector]*collectorSettings)
+	cm.outputs = make(map[MetricCollector]chan lp.CCMessage)
 
 	d := json.NewDecoder(bytes.NewReader(collectConfig))
 	d.DisallowUnknownFields()
@@ -96,20 +147,47 @@ func (cm *collectorManager) Init(ticker mct.MultiChanTicker, duration time.Durat
 		return fmt.Errorf("%s Init(): Error decoding collector manager config: %w", "CollectorManager", err)
 	}
 
-	// Initialize configured collectors
+	// Initialize configured collector instances. The instance name is the
+	// collector type unless the type is given with the 'type' key.
+	types := make(map[string]int)
 	for collectorName, collectorCfg := range cm.config {
-		if _, found := AvailableCollectors[collectorName]; !found {
-			cclog.ComponentError("CollectorManager", "SKIP unknown collector", collectorName)
+		typeName, collectorCfg, err := collectorType(collectorName, collectorCfg)
+		if err != nil {
+			cclog.ComponentError("CollectorManager", fmt.Sprintf("Collector %s initialization failed: %v", collectorName, err))
+			continue
+		}
+		newCollector, found := AvailableCollectors[typeName]
+		if !found {
+			cclog.ComponentError("CollectorManager", fmt.Sprintf("SKIP collector %s of unknown type %s", collectorName, typeName))
+			continue
+		}
+		if types[typeName] > 0 && slices.Contains(singleInstanceCollectors, typeName) {
+			cclog.ComponentError("CollectorManager", fmt.Sprintf("SKIP collector %s, only one instance of type %s is supported", collectorName, typeName))
+			continue
+		}
+		collector := newCollector()
+
+		// Separate the settings common to all collectors from the
+		// collector-specific configuration
+		settings, collectorCfg, err := splitCollectorSettings(collectorCfg)
+		if err != nil {
+			cclog.ComponentError("CollectorManager", fmt.Sprintf("Collector %s initialization failed: %v", collectorName, err))
 			continue
 		}
-		collector := AvailableCollectors[collectorName]
+		cm.config[collectorName] = collectorCfg
 
-		err := collector.Init(collectorCfg)
+		err = collector.Init(collectorCfg)
 		if err != nil {
 			cclog.ComponentError("CollectorManager", fmt.Sprintf("Collector %s initialization failed: %v", collectorName, err))
 			continue
 		}
-		cclog.ComponentDebug("CollectorManager", "ADD COLLECTOR", collector.Name())
+		cclog.ComponentDebug("CollectorManager", fmt.Sprintf("ADD COLLECTOR %s of type %s", collectorName, typeName))
+		types[typeName]++
+		cm.names[collector] = collectorName
+		if settings != nil {
+			cm.settings[collector] = settings
+			cm.outputs[collector] = make(chan lp.CCMessage)
+		}
 		if collector.Parallel() {
 			cm.collectors = append(cm.collectors, collector)
 		} else {
@@ -124,6 +202,17 @@ func (cm *collectorManager) Start() {
 	tick := make(chan time.Time)
 	cm.ticker.AddChannel(tick)
 
+	// Apply the common settings to the messages of the collectors
+	for c, output := range cm.outputs {
+		settings := cm.settings[c]
+		cm.wg.Go(func() {
+			for msg := range output {
+				settings.apply(msg)
+				cm.output <- msg
+			}
+		})
+	}
+
 	cm.wg.Go(func() {
 		// Collector manager is done
 		done := func() {
@@ -135,6 +224,9 @@ func (cm *collectorManager) Start() {
 			for _, c := range cm.collectors {
 				c.Close()
 			}
+			for _, output := range cm.outputs {
+				close(output)
+			}
 			close(cm.done)
 			cclog.ComponentDebug("CollectorManager", "DONE")
 		}
@@ -146,6 +238,14 @@ func (cm *collectorManager) Start() {
 				done()
 				return
 			case t := <-tick:
+				// Files and commands of this tick go to the next tick
+				// directory when recording a fixture
+				hostfs.NextTick()
+				// Re-initialize collectors with per CPU state if hardware
+				// threads went online or offline
+				if topo.Refresh() {
+					cm.topologyChanged()
+				}
 				cm.parallel_run = true
 				for _, c := range cm.collectors {
 					// Wait for done signal or execute the collector
@@ -155,10 +255,10 @@ func (cm *collectorManager) Start() {
 						return
 					default:
 						// Read metrics from collector c via goroutine
-						cclog.ComponentDebug("CollectorManager", c.Name(), t)
+						cclog.ComponentDebug("CollectorManager", cm.names[c], t)
 						cm.collector_wg.Add(1)
 						go func(myc MetricCollector) {
-							myc.Read(cm.duration, cm.output)
+							myc.Read(cm.duration, cm.outputOf(myc))
 							cm.collector_wg.Done()
 						}(c)
 					}
@@ -173,8 +273,8 @@ func (cm *collectorManager) Start() {
 						return
 					default:
 						// Read metrics from collector c
-						cclog.ComponentDebug("CollectorManager", c.Name(), t)
-						c.Read(cm.duration, cm.output)
+						cclog.ComponentDebug("CollectorManager", cm.names[c], t)
+						c.Read(cm.duration, cm.outputOf(c))
 					}
 				}
 			}
@@ -185,6 +285,48 @@ func (cm *collectorManager) Start() {
 	cclog.ComponentDebug("CollectorManager", "STARTED")
 }
 
+// topologyChanged re-initializes the collectors that depend on the CPU topology.
+// Collectors failing to re-initialize are not read until the next change.
+func (cm *collectorManager) topologyChanged() {
+	for _, list := range [][]MetricCollector{cm.collectors, cm.serial} {
+		for _, c := range list {
+			if t, ok := c.(TopologyDependent); !ok || !t.TopologyDependent() {
+				continue
+			}
+			collectorName := cm.names[c]
+			cclog.ComponentInfo("CollectorManager", fmt.Sprintf("Re-initialize collector %s after topology change", collectorName))
+			c.Close()
+			if err := c.Init(cm.config[collectorName]); err != nil {
+				cclog.ComponentError("CollectorManager", fmt.Sprintf("Collector %s re-initialization failed: %v", collectorName, err))
+			}
+		}
+	}
+}
+
+// outputOf returns the output channel for the messages of a collector. The
+// messages of collectors with common settings are modified before they are
+// sent to the output channel of the collector manager.
+func (cm *collectorManager) outputOf(c MetricCollector) chan lp.CCMessage {
+	if output, ok := cm.outputs[c]; ok {
+		return output
+	}
+	return cm.output
+}
+
+// Catalog returns the metric catalog of the initialized metric collectors.
+// The metric names are renamed according to the common collector settings.
+func (cm *collectorManager) Catalog() *mc.Catalog {
+	collectors := make([]mc.Collector, 0, len(cm.names))
+	for c, collectorName := range cm.names {
+		metrics := c.Catalog()
+		if settings, ok := cm.settings[c]; ok {
+			metrics = settings.catalog(metrics)
+		}
+		collectors = append(collectors, mc.Collector{Name: collectorName, Metrics: metrics})
+	}
+	return mc.New(collectors)
+}
+
 // AddOutput adds the output channel to the metric collector manager
 func (cm *collectorManager) AddOutput(output chan lp.CCMessage) {
 	cm.output = output
@@ -0,0 +1,86 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+)
+
+// ReplayCollector runs a collector on a fixture recorded with the -record
+// option of cc-metric-collector. The collector and the topology are
+// initialized with the files and command outputs of the init directory, then
+// the collector is read once for each recorded tick. The messages of each tick
+// are returned.
+//
+// The collectors take their timestamps from the clock of the hostfs package.
+// It is replaced by a simulated clock, which starts at the given start time and
+// advances by the interval for each tick, so the ticks are replayed without
+// waiting and rates are reproducible. The duration is passed to the Read()
+// function like the duration in the configuration file.
+func ReplayCollector(name string, config json.RawMessage, fixtureDir string, start time.Time, interval, duration time.Duration) ([][]lp.CCMessage, error) {
+	newCollector, ok := AvailableCollectors[name]
+	if !ok {
+		return nil, fmt.Errorf("ReplayCollector: unknown collector '%s'", name)
+	}
+	c := newCollector()
+	ticks, err := hostfs.FixtureTicks(fixtureDir)
+	if err != nil {
+		return nil, fmt.Errorf("ReplayCollector: %w", err)
+	}
+	if err := hostfs.Replay(fixtureDir); err != nil {
+		return nil, fmt.Errorf("ReplayCollector: %w", err)
+	}
+	now := start
+	hostfs.SetClock(func() time.Time { return now })
+	defer func() {
+		hostfs.StopFixture()
+		hostfs.SetClock(nil)
+		topo.Init()
+	}()
+	topo.Init()
+
+	if err := c.Init(config); err != nil {
+		return nil, fmt.Errorf("ReplayCollector: collector '%s' initialization failed: %w", name, err)
+	}
+	defer c.Close()
+
+	results := make([][]lp.CCMessage, 0, ticks)
+	for range ticks {
+		now = now.Add(interval)
+		hostfs.NextTick()
+		if topo.Refresh() {
+			if t, ok := c.(TopologyDependent); ok && t.TopologyDependent() {
+				c.Close()
+				if err := c.Init(config); err != nil {
+					return nil, fmt.Errorf("ReplayCollector: collector '%s' re-initialization failed: %w", name, err)
+				}
+			}
+		}
+
+		// Collect the messages of this tick
+		output := make(chan lp.CCMessage)
+		done := make(chan []lp.CCMessage)
+		go func() {
+			messages := make([]lp.CCMessage, 0)
+			for m := range output {
+				messages = append(messages, m)
+			}
+			done <- messages
+		}()
+		c.Read(duration, output)
+		close(output)
+		results = append(results, <-done)
+	}
+	return results, nil
+}
@@ -0,0 +1,145 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+// Fixtures recorded with 'cc-metric-collector -record', one directory per
+// collector. Each directory contains the collector configuration in
+// config.json and the expected output of each tick in <collector>.expected,
+// written by 'cc-collector-replay -update'.
+const replayFixtures = "testdata/replay"
+
+// Collectors with a fixture
+var replayCollectors = []string{"cpustat", "gpfs", "lustrestat"}
+
+// Interval of the simulated clock between the ticks of the fixtures
+const replayInterval = 10 * time.Second
+
+func TestMain(m *testing.M) {
+	cclog.Init("err", false)
+	os.Exit(m.Run())
+}
+
+// replayConfig returns the configuration of the collector in a fixture
+func replayConfig(t *testing.T, name string) json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(replayFixtures, name, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &configs); err != nil {
+		t.Fatal(err)
+	}
+	return configs[name]
+}
+
+// replayLines returns the messages in line protocol format without timestamps,
+// sorted like the expected output of cc-collector-replay
+func replayLines(messages []lp.CCMessage) []string {
+	lines := make([]string, 0, len(messages))
+	for _, m := range messages {
+		line := strings.TrimSpace(m.ToLineProtocol(nil))
+		if i := strings.LastIndexByte(line, ' '); i > 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// replayFixture replays the fixture of the collector and returns the messages
+// of each tick
+func replayFixture(t *testing.T, name string) [][]lp.CCMessage {
+	t.Helper()
+	results, err := ReplayCollector(name, replayConfig(t, name), filepath.Join(replayFixtures, name), time.Unix(1700000000, 0), replayInterval, time.Second)
+	if err != nil {
+		t.Fatalf("ReplayCollector() failed: %v", err)
+	}
+	if len(results) < 2 {
+		t.Fatalf("got %d ticks, want at least 2", len(results))
+	}
+	return results
+}
+
+func TestReplayCollector(t *testing.T) {
+	for _, name := range replayCollectors {
+		t.Run(name, func(t *testing.T) {
+			for i, messages := range replayFixture(t, name) {
+				tick := i + 1
+				data, err := os.ReadFile(filepath.Join(hostfs.FixtureTickDir(filepath.Join(replayFixtures, name), tick), name+".expected"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := make([]string, 0)
+				for l := range strings.SplitSeq(string(data), "\n") {
+					if l = strings.TrimSpace(l); len(l) > 0 {
+						want = append(want, l)
+					}
+				}
+				if got := replayLines(messages); !slices.Equal(got, want) {
+					t.Errorf("tick %d: got\n%s\nwant\n%s", tick, strings.Join(got, "\n"), strings.Join(want, "\n"))
+				}
+			}
+		})
+	}
+}
+
+// The timestamps of the messages follow the simulated clock, so the ticks are
+// replayed without waiting for the interval
+func TestReplayCollectorClock(t *testing.T) {
+	start := time.Now()
+	results := replayFixture(t, "lustrestat")
+	if elapsed := time.Since(start); elapsed >= replayInterval {
+		t.Errorf("replay took %v, longer than one interval", elapsed)
+	}
+	for i, messages := range results {
+		want := time.Unix(1700000000, 0).Add(time.Duration(i+1) * replayInterval)
+		for _, m := range messages {
+			if !m.Time().Equal(want) {
+				t.Errorf("tick %d: %s has timestamp %v, want %v", i+1, m.Name(), m.Time(), want)
+				break
+			}
+		}
+	}
+	if now := hostfs.Now(); now.Before(start) {
+		t.Errorf("clock was not reset after the replay: %v", now)
+	}
+}
+
+// All messages of the replayed collectors have to be described by their
+// catalogs
+func TestReplayCatalog(t *testing.T) {
+	for _, name := range replayCollectors {
+		t.Run(name, func(t *testing.T) {
+			catalog := mc.New([]mc.Collector{{Name: name, Metrics: AvailableCollectors[name]().Catalog()}})
+			for i, messages := range replayFixture(t, name) {
+				for _, m := range messages {
+					if err := catalog.Validate(m); err != nil {
+						t.Errorf("tick %d: %v", i+1, err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+// collectorSettings are accepted in the configuration of all collectors and
+// applied by the collector manager to the messages of a collector before they
+// are sent to the router:
+//
+//	"add_tags": {"fs_cluster": "scratch"},
+//	"add_meta": {"group": "Filesystem"},
+//	"name_prefix": "site_",
+//	"rename_metrics": {"gpfs_num_opens": "gpfs_opens"}
+//
+// Metrics are renamed first, then the prefix is added to all metric names.
+type collectorSettings struct {
+	AddTags       map[string]string `json:"add_tags,omitempty"`       // Tags added to all messages, existing tags are overwritten
+	AddMeta       map[string]string `json:"add_meta,omitempty"`       // Meta information added to all messages, existing keys are overwritten
+	NamePrefix    string            `json:"name_prefix,omitempty"`    // Prefix for all metric names
+	RenameMetrics map[string]string `json:"rename_metrics,omitempty"` // Map of old to new metric names
+}
+
+// collectorSettingsKeys are the configuration keys of the collector settings
+var collectorSettingsKeys = []string{"add_tags", "add_meta", "name_prefix", "rename_metrics"}
+
+// extractConfigKeys removes the given keys from a collector configuration.
+// It returns the values of the found keys and the remaining configuration.
+// Invalid configurations are returned unchanged, reporting them is left to
+// the collector.
+func extractConfigKeys(config json.RawMessage, names ...string) (map[string]json.RawMessage, json.RawMessage, error) {
+	found := make(map[string]json.RawMessage)
+	if len(config) == 0 {
+		return found, config, nil
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(config, &keys); err != nil || keys == nil {
+		return found, config, nil
+	}
+	for _, k := range names {
+		if v, ok := keys[k]; ok {
+			found[k] = v
+			delete(keys, k)
+		}
+	}
+	if len(found) == 0 {
+		return found, config, nil
+	}
+	rest, err := json.Marshal(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return found, rest, nil
+}
+
+// collectorType returns the collector type of a collector instance and its
+// configuration without the 'type' key. Instances without 'type' use the
+// instance name as collector type.
+func collectorType(name string, config json.RawMessage) (string, json.RawMessage, error) {
+	found, rest, err := extractConfigKeys(config, "type")
+	if err != nil {
+		return "", nil, err
+	}
+	raw, ok := found["type"]
+	if !ok {
+		return name, config, nil
+	}
+	var t string
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return "", nil, fmt.Errorf("error decoding collector type: %w", err)
+	}
+	return t, rest, nil
+}
+
+// splitCollectorSettings separates the collector settings from the
+// collector-specific configuration. The settings are nil if the
+// configuration contains none of the keys.
+func splitCollectorSettings(config json.RawMessage) (*collectorSettings, json.RawMessage, error) {
+	found, rest, err := extractConfigKeys(config, collectorSettingsKeys...)
+	if err != nil || len(found) == 0 {
+		return nil, rest, err
+	}
+	c, err := json.Marshal(found)
+	if err != nil {
+		return nil, nil, err
+	}
+	settings := new(collectorSettings)
+	if err := json.Unmarshal(c, settings); err != nil {
+		return nil, nil, fmt.Errorf("error decoding collector settings: %w", err)
+	}
+	return settings, rest, nil
+}
+
+// rename returns the metric name after renaming and adding the prefix
+func (s *collectorSettings) rename(name string) string {
+	if newName, ok := s.RenameMetrics[name]; ok {
+		name = newName
+	}
+	return s.NamePrefix + name
+}
+
+// apply applies the settings to a message
+func (s *collectorSettings) apply(msg lp.CCMessage) {
+	if name := s.rename(msg.Name()); name != msg.Name() {
+		msg.SetName(name)
+	}
+	for k, v := range s.AddTags {
+		msg.AddTag(k, v)
+	}
+	for k, v := range s.AddMeta {
+		msg.AddMeta(k, v)
+	}
+}
+
+// catalog applies the renaming to the metric catalog of a collector
+func (s *collectorSettings) catalog(metrics []mc.Metric) []mc.Metric {
+	metrics = slices.Clone(metrics)
+	for i := range metrics {
+		metrics[i].Name = s.rename(metrics[i].Name)
+	}
+	return metrics
+}
@@ -9,15 +9,17 @@ package collectors
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // CPUFreqCollector
@@ -30,12 +32,15 @@ type CPUFreqCpuInfoCollectorTopology struct {
 }
 
 type CPUFreqCpuInfoCollector struct {
-	metricCollector
+	BaseCollector
 
 	topology []CPUFreqCpuInfoCollectorTopology
+	config   struct {
+		MetricFilter
+	}
 }
 
-func (m *CPUFreqCpuInfoCollector) Init(_ json.RawMessage) error {
+func (m *CPUFreqCpuInfoCollector) Init(config json.RawMessage) error {
 	// Check if already initialized
 	if m.init {
 		return nil
@@ -46,6 +51,16 @@ func (m *CPUFreqCpuInfoCollector) Init(_ json.RawMessage) error {
 		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
 	}
 	m.parallel = true
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.meta = map[string]string{
 		"source": m.name,
 		"group":  "CPU",
@@ -53,7 +68,7 @@ func (m *CPUFreqCpuInfoCollector) Init(_ json.RawMessage) error {
 	}
 
 	const cpuInfoFile = "/proc/cpuinfo"
-	file, err := os.Open(cpuInfoFile)
+	file, err := hostfs.Open(cpuInfoFile)
 	if err != nil {
 		return fmt.Errorf("%s Init(): failed to open file '%s': %w", m.name, cpuInfoFile, err)
 	}
@@ -135,12 +150,12 @@ func (m *CPUFreqCpuInfoCollector) Init(_ json.RawMessage) error {
 
 func (m *CPUFreqCpuInfoCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	// Check if already initialized
-	if !m.init {
+	if !m.init || m.config.IsMetricExcluded("cpufreq") {
 		return
 	}
 
 	const cpuInfoFile = "/proc/cpuinfo"
-	file, err := os.Open(cpuInfoFile)
+	file, err := hostfs.Open(cpuInfoFile)
 	if err != nil {
 		cclog.ComponentError(
 			m.name,
@@ -156,7 +171,7 @@ func (m *CPUFreqCpuInfoCollector) Read(interval time.Duration, output chan lp.CC
 	}()
 
 	processorCounter := 0
-	now := time.Now()
+	now := hostfs.Now()
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		lineSplit := strings.Split(scanner.Text(), ":")
@@ -187,6 +202,19 @@ func (m *CPUFreqCpuInfoCollector) Read(interval time.Duration, output chan lp.CC
 	}
 }
 
+// TopologyDependent marks the collector for re-initialization when hardware
+// threads go online or offline
+func (m *CPUFreqCpuInfoCollector) TopologyDependent() bool {
+	return true
+}
+
+// Catalog returns the metrics the collector can send
+func (m *CPUFreqCpuInfoCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "cpufreq", Unit: "MHz", Types: []string{"hwthread"}, Kind: mc.Gauge, Description: "Current clock frequency from /proc/cpuinfo"},
+	}
+}
+
 func (m *CPUFreqCpuInfoCollector) Close() {
 	m.init = false
 }
@@ -11,7 +11,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -20,29 +19,77 @@ import (
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
 	"github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	"golang.org/x/sys/unix"
 )
 
+// Frequency files of cpufreq in kHz and the metrics sent for them in Hz
+var cpufreqLimitFiles = []struct {
+	file   string
+	metric string
+}{
+	{"scaling_min_freq", "cpufreq_scaling_min"},
+	{"scaling_max_freq", "cpufreq_scaling_max"},
+	{"cpuinfo_max_freq", "cpufreq_cpuinfo_max"},
+}
+
+// Files of the boost state. intel_pstate reports the inverted state.
+const (
+	CPUFREQ_BOOST_FILE    = `/sys/devices/system/cpu/cpufreq/boost`
+	INTEL_PSTATE_NO_TURBO = `/sys/devices/system/cpu/intel_pstate/no_turbo`
+)
+
 type CPUFreqCollectorTopology struct {
 	scalingCurFreqFile string
+	limitFiles         map[string]string // Frequency limit files by metric name
+	governorFile       string            // Empty if not available
+	eppFile            string            // Empty if not available
+	throttleCountFile  string            // Empty if not available
+	throttleTimeFile   string            // Empty if not available
 	tagSet             map[string]string
+	governor           string // Last sent governor
+	epp                string // Last sent energy performance preference
+}
+
+// CPUFreqCollectorSocket holds the package throttle counters of a socket.
+// They are read from the first hardware thread of the socket.
+type CPUFreqCollectorSocket struct {
+	throttleCountFile string
+	throttleTimeFile  string
+	tagSet            map[string]string
 }
 
 // CPUFreqCollector
 // a metric collector to measure the current frequency of the CPUs
-// as obtained from the hardware (in KHz)
+// as obtained from the hardware, the frequency limits, the governor and
+// energy performance preference, the boost state and the thermal throttle
+// counters.
 // Only measure on the first hyper-thread
 //
 // See: https://www.kernel.org/doc/html/latest/admin-guide/pm/cpufreq.html
 type CPUFreqCollector struct {
-	metricCollector
+	BaseCollector
 
-	topology []CPUFreqCollectorTopology
-	config   struct {
-		ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
+	topology     []CPUFreqCollectorTopology
+	sockets      []CPUFreqCollectorSocket
+	boostFile    string // Empty if not available
+	boostInverse bool   // Boost file contains the inverted state
+	nodeTags     map[string]string
+	config       struct {
+		MetricFilter
 	}
 }
 
+// cpufreqOptionalFile returns the file if it is readable, otherwise an empty
+// string
+func cpufreqOptionalFile(file string) string {
+	if err := hostfs.Access(file, unix.R_OK); err != nil {
+		return ""
+	}
+	return file
+}
+
 func (m *CPUFreqCollector) Init(config json.RawMessage) error {
 	// Check if already initialized
 	if m.init {
@@ -61,13 +108,20 @@ func (m *CPUFreqCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.meta = map[string]string{
 		"source": m.name,
 		"group":  "CPU",
-		"unit":   "Hz",
+	}
+	m.nodeTags = map[string]string{
+		"type": "node",
 	}
 
 	m.topology = make([]CPUFreqCollectorTopology, 0)
+	m.sockets = make([]CPUFreqCollectorSocket, 0)
+	seenSockets := make(map[int]bool)
 	for _, c := range ccTopology.CpuData() {
 
 		// Skip hyper threading CPUs
@@ -76,22 +130,53 @@ func (m *CPUFreqCollector) Init(config json.RawMessage) error {
 		}
 
 		// Check access to current frequency file
-		scalingCurFreqFile := filepath.Join("/sys/devices/system/cpu", fmt.Sprintf("cpu%d", c.CpuID), "cpufreq/scaling_cur_freq")
-		err := unix.Access(scalingCurFreqFile, unix.R_OK)
+		cpuDir := filepath.Join("/sys/devices/system/cpu", fmt.Sprintf("cpu%d", c.CpuID))
+		scalingCurFreqFile := filepath.Join(cpuDir, "cpufreq/scaling_cur_freq")
+		err := hostfs.Access(scalingCurFreqFile, unix.R_OK)
 		if err != nil {
 			return fmt.Errorf("%s Init(): unable to access file '%s': %w", m.name, scalingCurFreqFile, err)
 		}
 
-		m.topology = append(m.topology,
-			CPUFreqCollectorTopology{
-				tagSet: map[string]string{
-					"type":       "hwthread",
-					"type-id":    strconv.Itoa(c.CpuID),
-					"package_id": strconv.Itoa(c.Socket),
-				},
-				scalingCurFreqFile: scalingCurFreqFile,
+		t := CPUFreqCollectorTopology{
+			tagSet: map[string]string{
+				"type":       "hwthread",
+				"type-id":    strconv.Itoa(c.CpuID),
+				"package_id": strconv.Itoa(c.Socket),
 			},
-		)
+			scalingCurFreqFile: scalingCurFreqFile,
+			limitFiles:         make(map[string]string),
+			governorFile:       cpufreqOptionalFile(filepath.Join(cpuDir, "cpufreq/scaling_governor")),
+			eppFile:            cpufreqOptionalFile(filepath.Join(cpuDir, "cpufreq/energy_performance_preference")),
+			throttleCountFile:  cpufreqOptionalFile(filepath.Join(cpuDir, "thermal_throttle/core_throttle_count")),
+			throttleTimeFile:   cpufreqOptionalFile(filepath.Join(cpuDir, "thermal_throttle/core_throttle_total_time_ms")),
+		}
+		for _, l := range cpufreqLimitFiles {
+			if file := cpufreqOptionalFile(filepath.Join(cpuDir, "cpufreq", l.file)); len(file) > 0 {
+				t.limitFiles[l.metric] = file
+			}
+		}
+		m.topology = append(m.topology, t)
+
+		// Package throttle counters of the socket
+		if !seenSockets[c.Socket] {
+			seenSockets[c.Socket] = true
+			m.sockets = append(m.sockets,
+				CPUFreqCollectorSocket{
+					throttleCountFile: cpufreqOptionalFile(filepath.Join(cpuDir, "thermal_throttle/package_throttle_count")),
+					throttleTimeFile:  cpufreqOptionalFile(filepath.Join(cpuDir, "thermal_throttle/package_throttle_total_time_ms")),
+					tagSet: map[string]string{
+						"type":    "socket",
+						"type-id": strconv.Itoa(c.Socket),
+					},
+				})
+		}
+	}
+
+	m.boostFile = cpufreqOptionalFile(CPUFREQ_BOOST_FILE)
+	m.boostInverse = false
+	if len(m.boostFile) == 0 {
+		m.boostFile = cpufreqOptionalFile(INTEL_PSTATE_NO_TURBO)
+		m.boostInverse = len(m.boostFile) > 0
 	}
 
 	// Initialized
@@ -103,38 +188,135 @@ func (m *CPUFreqCollector) Init(config json.RawMessage) error {
 	return nil
 }
 
+// sendValue reads an integer file and sends its value
+func (m *CPUFreqCollector) sendValue(
+	name string,
+	file string,
+	scale int64,
+	unit string,
+	tags map[string]string,
+	now time.Time,
+	output chan lp.CCMessage,
+) {
+	if len(file) == 0 || m.config.IsMetricExcluded(name) {
+		return
+	}
+	value, err := readInt64File(file)
+	if err != nil {
+		cclog.ComponentError(
+			m.name,
+			fmt.Sprintf("Read(): Failed to read file '%s': %v", file, err))
+		return
+	}
+	if y, err := lp.NewMetric(name, tags, m.meta, value*scale, now); err == nil {
+		if len(unit) > 0 {
+			y.AddMeta("unit", unit)
+		}
+		output <- y
+	}
+}
+
+// sendChange reads a text file and sends its content as event if it
+// differs from the last sent content
+func (m *CPUFreqCollector) sendChange(
+	name string,
+	file string,
+	last *string,
+	tags map[string]string,
+	now time.Time,
+	output chan lp.CCMessage,
+) {
+	if len(file) == 0 || m.config.IsMetricExcluded(name) {
+		return
+	}
+	data, err := hostfs.ReadFile(file)
+	if err != nil {
+		cclog.ComponentError(
+			m.name,
+			fmt.Sprintf("Read(): Failed to read file '%s': %v", file, err))
+		return
+	}
+	value := strings.TrimSpace(string(data))
+	if value == *last {
+		return
+	}
+	if y, err := lp.NewEvent(name, tags, m.meta, value, now); err == nil {
+		*last = value
+		output <- y
+	}
+}
+
 func (m *CPUFreqCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	// Check if already initialized
 	if !m.init {
 		return
 	}
 
-	now := time.Now()
+	now := hostfs.Now()
 	for i := range m.topology {
 		t := &m.topology[i]
 
-		// Read current frequency
-		line, err := os.ReadFile(t.scalingCurFreqFile)
-		if err != nil {
-			cclog.ComponentError(
-				m.name,
-				fmt.Sprintf("Read(): Failed to read file '%s': %v", t.scalingCurFreqFile, err))
-			continue
+		// Frequencies in kHz
+		m.sendValue("cpufreq", t.scalingCurFreqFile, 1000, "Hz", t.tagSet, now, output)
+		for _, l := range cpufreqLimitFiles {
+			m.sendValue(l.metric, t.limitFiles[l.metric], 1000, "Hz", t.tagSet, now, output)
 		}
-		cpuFreq, err := strconv.ParseInt(strings.TrimSpace(string(line)), 10, 64)
+
+		m.sendChange("cpufreq_governor", t.governorFile, &t.governor, t.tagSet, now, output)
+		m.sendChange("cpufreq_energy_perf_preference", t.eppFile, &t.epp, t.tagSet, now, output)
+
+		m.sendValue("thermal_throttle_core_count", t.throttleCountFile, 1, "", t.tagSet, now, output)
+		m.sendValue("thermal_throttle_core_time", t.throttleTimeFile, 1, "ms", t.tagSet, now, output)
+	}
+
+	for i := range m.sockets {
+		s := &m.sockets[i]
+		m.sendValue("thermal_throttle_package_count", s.throttleCountFile, 1, "", s.tagSet, now, output)
+		m.sendValue("thermal_throttle_package_time", s.throttleTimeFile, 1, "ms", s.tagSet, now, output)
+	}
+
+	if len(m.boostFile) > 0 && !m.config.IsMetricExcluded("cpufreq_boost") {
+		boost, err := readInt64File(m.boostFile)
 		if err != nil {
 			cclog.ComponentError(
 				m.name,
-				fmt.Sprintf("Read(): Failed to convert CPU frequency '%s' to int64: %v", line, err))
-			continue
+				fmt.Sprintf("Read(): Failed to read file '%s': %v", m.boostFile, err))
+			return
 		}
-
-		if y, err := lp.NewMetric("cpufreq", t.tagSet, m.meta, cpuFreq, now); err == nil {
+		if m.boostInverse {
+			boost = 1 - boost
+		}
+		if y, err := lp.NewMetric("cpufreq_boost", m.nodeTags, m.meta, boost, now); err == nil {
 			output <- y
 		}
 	}
 }
 
+// TopologyDependent marks the collector for re-initialization when hardware
+// threads go online or offline
+func (m *CPUFreqCollector) TopologyDependent() bool {
+	return true
+}
+
+// Catalog returns the metrics the collector can send
+func (m *CPUFreqCollector) Catalog() []mc.Metric {
+	hwthread := []string{"hwthread"}
+	socket := []string{"socket"}
+	return []mc.Metric{
+		{Name: "cpufreq", Unit: "Hz", Types: hwthread, Kind: mc.Gauge, Description: "Current clock frequency from sysfs"},
+		{Name: "cpufreq_scaling_min", Unit: "Hz", Types: hwthread, Kind: mc.Gauge, Description: "Minimum clock frequency allowed by the cpufreq policy"},
+		{Name: "cpufreq_scaling_max", Unit: "Hz", Types: hwthread, Kind: mc.Gauge, Description: "Maximum clock frequency allowed by the cpufreq policy"},
+		{Name: "cpufreq_cpuinfo_max", Unit: "Hz", Types: hwthread, Kind: mc.Gauge, Description: "Maximum clock frequency supported by the hardware"},
+		{Name: "cpufreq_governor", Types: hwthread, Kind: mc.Event, Description: "Scaling governor, sent on change"},
+		{Name: "cpufreq_energy_perf_preference", Types: hwthread, Kind: mc.Event, Description: "Energy performance preference, sent on change"},
+		{Name: "cpufreq_boost", Types: []string{"node"}, Kind: mc.Gauge, Description: "Boost (turbo) frequencies enabled (1) or disabled (0)"},
+		{Name: "thermal_throttle_core_count", Types: hwthread, Kind: mc.Counter, Description: "Thermal throttling events of the core"},
+		{Name: "thermal_throttle_core_time", Unit: "ms", Types: hwthread, Kind: mc.Counter, Description: "Time the core was thermally throttled"},
+		{Name: "thermal_throttle_package_count", Types: socket, Kind: mc.Counter, Description: "Thermal throttling events of the package"},
+		{Name: "thermal_throttle_package_time", Unit: "ms", Types: socket, Kind: mc.Counter, Description: "Time the package was thermally throttled"},
+	}
+}
+
 func (m *CPUFreqCollector) Close() {
 	m.init = false
 }
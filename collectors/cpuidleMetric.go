@@ -0,0 +1,273 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+type CPUIdleCollectorConfig struct {
+	SendHwthreadValues bool `json:"send_hwthread_values"` // Send the values of the hardware threads
+	SendSocketValues   bool `json:"send_socket_values"`   // Send the values aggregated for the sockets
+	SendNodeValues     bool `json:"send_node_values"`     // Send the values aggregated for the node
+	MetricFilter
+}
+
+// cpuidleState is an idle state of a hardware thread
+type cpuidleState struct {
+	name      string            // Name of the idle state like 'C1' or 'C6'
+	timeFile  string            // Total time spent in the idle state in microseconds
+	usageFile string            // Number of entries into the idle state
+	tags      map[string]string // Tags of the hardware thread metrics
+	lastTime  int64
+	lastUsage int64
+	hasLast   bool
+}
+
+// cpuidleHwthread holds the idle states of a hardware thread
+type cpuidleHwthread struct {
+	cpu    int
+	socket int
+	states []cpuidleState
+}
+
+// CPUIdleCollector reads the time spent in the idle states (C-states) of
+// the hardware threads and the number of entries into the idle states.
+//
+// See: https://www.kernel.org/doc/html/latest/admin-guide/pm/cpuidle.html
+type CPUIdleCollector struct {
+	BaseCollector
+	config        CPUIdleCollectorConfig
+	hwthreads     []cpuidleHwthread
+	lastTimestamp time.Time
+}
+
+func (m *CPUIdleCollector) Init(config json.RawMessage) error {
+	m.name = "CPUIdleCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "CPU",
+	}
+
+	m.config.SendHwthreadValues = true
+	m.config.SendSocketValues = true
+	m.config.SendNodeValues = true
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+
+	numStates := 0
+	m.hwthreads = make([]cpuidleHwthread, 0)
+	for _, c := range ccTopology.CpuData() {
+		globPattern := filepath.Join("/sys/devices/system/cpu", fmt.Sprintf("cpu%d", c.CpuID), "cpuidle/state[0-9]*")
+		dirs, err := hostfs.Glob(globPattern)
+		if err != nil {
+			return fmt.Errorf("%s Init(): unable to glob files with pattern '%s': %w", m.name, globPattern, err)
+		}
+		h := cpuidleHwthread{
+			cpu:    c.CpuID,
+			socket: c.Socket,
+			states: make([]cpuidleState, 0, len(dirs)),
+		}
+		for _, dir := range dirs {
+			name, err := hostfs.ReadFile(filepath.Join(dir, "name"))
+			if err != nil {
+				return fmt.Errorf("%s Init(): unable to read name of idle state '%s': %w", m.name, dir, err)
+			}
+			s := cpuidleState{
+				name:      strings.TrimSpace(string(name)),
+				timeFile:  filepath.Join(dir, "time"),
+				usageFile: filepath.Join(dir, "usage"),
+			}
+			if m.config.IsDeviceExcluded(s.name) {
+				continue
+			}
+			s.tags = map[string]string{
+				"type":     "hwthread",
+				"type-id":  strconv.Itoa(c.CpuID),
+				"stype":    "cstate",
+				"stype-id": s.name,
+			}
+			h.states = append(h.states, s)
+		}
+		numStates += len(h.states)
+		m.hwthreads = append(m.hwthreads, h)
+	}
+	if numStates == 0 {
+		return fmt.Errorf("%s Init(): no idle states found in /sys/devices/system/cpu/cpu*/cpuidle", m.name)
+	}
+
+	// Initialized
+	cclog.ComponentDebug(m.name, fmt.Sprintf("initialized %d idle states of %d hardware threads", numStates, len(m.hwthreads)))
+	m.lastTimestamp = time.Time{}
+	m.init = true
+	return nil
+}
+
+// readInt64File reads a file containing a single integer like many sysfs files
+func readInt64File(file string) (int64, error) {
+	data, err := hostfs.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// cpuidleSum sums up the residencies and entry rates of the hardware
+// threads of a socket or the node for an idle state
+type cpuidleSum struct {
+	residency float64
+	rate      float64
+	count     int
+}
+
+func (m *CPUIdleCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+
+	now := hostfs.Now()
+	timeDiff := now.Sub(m.lastTimestamp).Seconds()
+	m.lastTimestamp = now
+
+	sendResidency := !m.config.IsMetricExcluded("cpuidle_residency")
+	sendRate := !m.config.IsMetricExcluded("cpuidle_entry_rate")
+	send := func(tags map[string]string, residency, rate float64) {
+		if sendResidency {
+			if y, err := lp.NewMetric("cpuidle_residency", tags, m.meta, residency, now); err == nil {
+				y.AddMeta("unit", "Percent")
+				output <- y
+			}
+		}
+		if sendRate {
+			if y, err := lp.NewMetric("cpuidle_entry_rate", tags, m.meta, rate, now); err == nil {
+				y.AddMeta("unit", "entries/sec")
+				output <- y
+			}
+		}
+	}
+
+	socketSums := make(map[int]map[string]*cpuidleSum)
+	nodeSums := make(map[string]*cpuidleSum)
+	states := make([]string, 0)
+	for i := range m.hwthreads {
+		h := &m.hwthreads[i]
+		for j := range h.states {
+			s := &h.states[j]
+			idleTime, err := readInt64File(s.timeFile)
+			if err != nil {
+				cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to read file '%s': %v", s.timeFile, err))
+				continue
+			}
+			usage, err := readInt64File(s.usageFile)
+			if err != nil {
+				cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to read file '%s': %v", s.usageFile, err))
+				continue
+			}
+			valid := s.hasLast && idleTime >= s.lastTime && usage >= s.lastUsage && timeDiff > 0
+			lastTime, lastUsage := s.lastTime, s.lastUsage
+			s.lastTime, s.lastUsage, s.hasLast = idleTime, usage, true
+			if !valid {
+				continue
+			}
+
+			// Idle time in microseconds to percent of the elapsed time
+			residency := min(float64(idleTime-lastTime)/(timeDiff*1e4), 100)
+			rate := float64(usage-lastUsage) / timeDiff
+			if m.config.SendHwthreadValues {
+				send(s.tags, residency, rate)
+			}
+
+			if _, ok := nodeSums[s.name]; !ok {
+				states = append(states, s.name)
+			}
+			if _, ok := socketSums[h.socket]; !ok {
+				socketSums[h.socket] = make(map[string]*cpuidleSum)
+			}
+			for _, sums := range []map[string]*cpuidleSum{socketSums[h.socket], nodeSums} {
+				sum, ok := sums[s.name]
+				if !ok {
+					sum = new(cpuidleSum)
+					sums[s.name] = sum
+				}
+				sum.residency += residency
+				sum.rate += rate
+				sum.count++
+			}
+		}
+	}
+
+	// The residency of sockets and node is the average of the hardware
+	// threads, the entry rate is the sum
+	if m.config.SendSocketValues {
+		for _, socket := range ccTopology.SocketList() {
+			for _, state := range states {
+				if sum, ok := socketSums[socket][state]; ok {
+					send(map[string]string{
+						"type":     "socket",
+						"type-id":  strconv.Itoa(socket),
+						"stype":    "cstate",
+						"stype-id": state,
+					}, sum.residency/float64(sum.count), sum.rate)
+				}
+			}
+		}
+	}
+	if m.config.SendNodeValues {
+		for _, state := range states {
+			sum := nodeSums[state]
+			send(map[string]string{
+				"type":     "node",
+				"stype":    "cstate",
+				"stype-id": state,
+			}, sum.residency/float64(sum.count), sum.rate)
+		}
+	}
+}
+
+// TopologyDependent marks the collector for re-initialization when hardware
+// threads go online or offline
+func (m *CPUIdleCollector) TopologyDependent() bool {
+	return true
+}
+
+// Catalog returns the metrics the collector can send
+func (m *CPUIdleCollector) Catalog() []mc.Metric {
+	types := []string{"hwthread", "socket", "node"}
+	return []mc.Metric{
+		{Name: "cpuidle_residency", Unit: "Percent", Types: types, Kind: mc.Gauge, Description: "Share of the time spent in the idle state given by the stype-id tag, averaged over the hardware threads of sockets and node"},
+		{Name: "cpuidle_entry_rate", Unit: "entries/sec", Types: types, Kind: mc.Gauge, Description: "Entries into the idle state given by the stype-id tag per second, summed up for sockets and node"},
+	}
+}
+
+func (m *CPUIdleCollector) Close() {
+	m.init = false
+}
@@ -12,26 +12,26 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	sysconf "github.com/tklauser/go-sysconf"
 )
 
 const CPUSTATFILE = `/proc/stat`
 
 type CpustatCollectorConfig struct {
-	ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
+	MetricFilter
 	excludeNumCPUs bool
 }
 
 type CpustatCollector struct {
-	metricCollector
+	BaseCollector
 
 	config        CpustatCollectorConfig
 	lastTimestamp time.Time // Store time stamp of last tick to derive values
@@ -61,6 +61,9 @@ func (m *CpustatCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %s", m.name, err.Error())
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	matches := map[string]int{
 		"cpu_user":       1,
 		"cpu_nice":       2,
@@ -76,14 +79,14 @@ func (m *CpustatCollector) Init(config json.RawMessage) error {
 
 	m.matches = make(map[string]int)
 	for match, index := range matches {
-		if !slices.Contains(m.config.ExcludeMetrics, match) {
+		if !m.config.IsMetricExcluded(match) {
 			m.matches[match] = index
 		}
 	}
-	m.config.excludeNumCPUs = slices.Contains(m.config.ExcludeMetrics, "num_cpus")
+	m.config.excludeNumCPUs = m.config.IsMetricExcluded("num_cpus")
 
 	// Check input file
-	file, err := os.Open(CPUSTATFILE)
+	file, err := hostfs.Open(CPUSTATFILE)
 	if err != nil {
 		return fmt.Errorf("%s Init(): Failed to open file '%s': %s", m.name, CPUSTATFILE, err.Error())
 	}
@@ -126,7 +129,7 @@ func (m *CpustatCollector) Init(config json.RawMessage) error {
 		return fmt.Errorf("%s Init(): Failed to close file '%s': %s", m.name, CPUSTATFILE, err.Error())
 	}
 
-	m.lastTimestamp = time.Now()
+	m.lastTimestamp = hostfs.Now()
 	m.init = true
 	return nil
 }
@@ -134,12 +137,22 @@ func (m *CpustatCollector) Init(config json.RawMessage) error {
 func (m *CpustatCollector) parseStatLine(linefields []string, tags map[string]string, output chan lp.CCMessage, now time.Time, tsdelta time.Duration) {
 	values := make(map[string]float64)
 	clktck, _ := sysconf.Sysconf(sysconf.SC_CLK_TCK)
+	olddata, ok := m.olddata[linefields[0]]
+	if !ok {
+		// CPU went online after Init(), store the first values only
+		olddata = make(map[string]int64)
+		for match, index := range m.matches {
+			olddata[match], _ = strconv.ParseInt(linefields[index], 0, 64)
+		}
+		m.olddata[linefields[0]] = olddata
+		return
+	}
 	for match, index := range m.matches {
 		if len(match) > 0 {
 			x, err := strconv.ParseInt(linefields[index], 0, 64)
 			if err == nil {
-				vdiff := x - m.olddata[linefields[0]][match]
-				m.olddata[linefields[0]][match] = x // Store new value for next run
+				vdiff := x - olddata[match]
+				olddata[match] = x // Store new value for next run
 				values[match] = float64(vdiff) / float64(tsdelta.Seconds()) / float64(clktck)
 			}
 		}
@@ -154,7 +167,7 @@ func (m *CpustatCollector) parseStatLine(linefields []string, tags map[string]st
 			output <- y
 		}
 	}
-	if v, ok := values["cpu_idle"]; ok {
+	if v, ok := values["cpu_idle"]; ok && !m.config.IsMetricExcluded("cpu_used") {
 		sum -= v
 		y, err := lp.NewMetric("cpu_used", tags, m.meta, sum*100, now)
 		if err == nil {
@@ -169,10 +182,10 @@ func (m *CpustatCollector) Read(interval time.Duration, output chan lp.CCMessage
 		return
 	}
 	num_cpus := 0
-	now := time.Now()
+	now := hostfs.Now()
 	tsdelta := now.Sub(m.lastTimestamp)
 
-	file, err := os.Open(CPUSTATFILE)
+	file, err := hostfs.Open(CPUSTATFILE)
 	if err != nil {
 		cclog.ComponentError(
 			m.name,
@@ -193,7 +206,16 @@ func (m *CpustatCollector) Read(interval time.Duration, output chan lp.CCMessage
 		if strings.Compare(linefields[0], "cpu") == 0 {
 			m.parseStatLine(linefields, m.nodetags, output, now, tsdelta)
 		} else if strings.HasPrefix(linefields[0], "cpu") {
-			m.parseStatLine(linefields, m.cputags[linefields[0]], output, now, tsdelta)
+			tags, ok := m.cputags[linefields[0]]
+			if !ok {
+				cpu, _ := strconv.Atoi(strings.TrimPrefix(linefields[0], "cpu"))
+				tags = map[string]string{
+					"type":    "hwthread",
+					"type-id": strconv.Itoa(cpu),
+				}
+				m.cputags[linefields[0]] = tags
+			}
+			m.parseStatLine(linefields, tags, output, now, tsdelta)
 			num_cpus++
 		}
 	}
@@ -210,6 +232,31 @@ func (m *CpustatCollector) Read(interval time.Duration, output chan lp.CCMessage
 	m.lastTimestamp = now
 }
 
+// TopologyDependent marks the collector for re-initialization when hardware
+// threads go online or offline
+func (m *CpustatCollector) TopologyDependent() bool {
+	return true
+}
+
+// Catalog returns the metrics the collector can send
+func (m *CpustatCollector) Catalog() []mc.Metric {
+	types := []string{"node", "hwthread"}
+	return []mc.Metric{
+		{Name: "cpu_user", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent in user mode"},
+		{Name: "cpu_nice", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent in user mode with low priority"},
+		{Name: "cpu_system", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent in system mode"},
+		{Name: "cpu_idle", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent in the idle task"},
+		{Name: "cpu_iowait", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent waiting for I/O to complete"},
+		{Name: "cpu_irq", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent servicing interrupts"},
+		{Name: "cpu_softirq", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent servicing softirqs"},
+		{Name: "cpu_steal", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent in other operating systems when running virtualized"},
+		{Name: "cpu_guest", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent running a virtual CPU for guest operating systems"},
+		{Name: "cpu_guest_nice", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time spent running a niced guest"},
+		{Name: "cpu_used", Unit: "Percent", UnitTag: true, Types: types, Kind: mc.Gauge, Description: "Share of time not spent in the idle task"},
+		{Name: "num_cpus", Types: []string{"node"}, Kind: mc.Gauge, Description: "Number of online hardware threads"},
+	}
+}
+
 func (m *CpustatCollector) Close() {
 	m.init = false
 }
@@ -12,25 +12,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
-	"slices"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const CUSTOMCMDPATH = `/home/unrz139/Work/cc-metric-collector/collectors/custom`
 
 type CustomCmdCollectorConfig struct {
-	Commands       []string `json:"commands"`
-	Files          []string `json:"files"`
-	ExcludeMetrics []string `json:"exclude_metrics"`
+	Commands []string `json:"commands"`
+	Files    []string `json:"files"`
+	MetricFilter
 }
 
 type CustomCmdCollector struct {
-	metricCollector
+	BaseCollector
 
 	config         CustomCmdCollectorConfig
 	cmdFieldsSlice [][]string
@@ -53,6 +53,9 @@ func (m *CustomCmdCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	// Setup
 	if err := m.setup(); err != nil {
@@ -62,7 +65,7 @@ func (m *CustomCmdCollector) Init(config json.RawMessage) error {
 	// Check if command can be executed
 	for _, c := range m.config.Commands {
 		cmdFields := strings.Fields(c)
-		command := exec.Command(cmdFields[0], cmdFields[1:]...)
+		command := hostfs.Command(cmdFields[0], cmdFields[1:]...)
 		if _, err := command.Output(); err != nil {
 			cclog.ComponentWarn(
 				m.name,
@@ -97,7 +100,7 @@ func (m *CustomCmdCollector) Read(interval time.Duration, output chan lp.CCMessa
 
 	// Execute configured commands
 	for _, cmdFields := range m.cmdFieldsSlice {
-		command := exec.Command(cmdFields[0], cmdFields[1:]...)
+		command := hostfs.Command(cmdFields[0], cmdFields[1:]...)
 		stdout, err := command.Output()
 		if err != nil {
 			cclog.ComponentError(
@@ -117,7 +120,7 @@ func (m *CustomCmdCollector) Read(interval time.Duration, output chan lp.CCMessa
 			continue
 		}
 		for _, metric := range metrics {
-			if slices.Contains(m.config.ExcludeMetrics, metric.Name()) {
+			if m.config.IsMetricExcluded(metric.Name()) {
 				continue
 			}
 			output <- metric
@@ -145,7 +148,7 @@ func (m *CustomCmdCollector) Read(interval time.Duration, output chan lp.CCMessa
 			continue
 		}
 		for _, metric := range metrics {
-			if slices.Contains(m.config.ExcludeMetrics, metric.Name()) {
+			if m.config.IsMetricExcluded(metric.Name()) {
 				continue
 			}
 			output <- metric
@@ -153,6 +156,14 @@ func (m *CustomCmdCollector) Read(interval time.Duration, output chan lp.CCMessa
 	}
 }
 
+// Catalog returns the metrics the collector can send. The files and commands
+// may output any metric in line protocol format.
+func (m *CustomCmdCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "*", Unit: mc.AnyUnit, Kind: mc.Gauge, Description: "Metric in line protocol format read from a configured file or command"},
+	}
+}
+
 func (m *CustomCmdCollector) Close() {
 	m.init = false
 }
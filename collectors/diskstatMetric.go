@@ -12,27 +12,31 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const MOUNTFILE = `/proc/self/mounts`
 
 type DiskstatCollectorConfig struct {
-	ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
-	ExcludeMounts  []string `json:"exclude_mounts,omitempty"`
+	ExcludeMounts   []string `json:"exclude_mounts,omitempty"`    // Exclude mount points containing one of these strings, alias of exclude_devices
+	AddAffinityTags bool     `json:"add_affinity_tags,omitempty"` // Add tags numa and socket of the disk controller
+	MetricFilter
 }
 
 type DiskstatCollector struct {
-	metricCollector
+	BaseCollector
 
 	config         DiskstatCollectorConfig
 	allowedMetrics map[string]bool
+	affinityTags   map[string]map[string]string // Affinity tags per device
 }
 
 func (m *DiskstatCollector) Init(config json.RawMessage) error {
@@ -49,17 +53,23 @@ func (m *DiskstatCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	// exclude_mounts is an alias of exclude_devices with substring matching
+	for _, excl := range m.config.ExcludeMounts {
+		m.config.ExcludeDevices = append(m.config.ExcludeDevices, substringPattern(excl))
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+	m.affinityTags = make(map[string]map[string]string)
 	m.allowedMetrics = map[string]bool{
 		"disk_total":    true,
 		"disk_free":     true,
 		"part_max_used": true,
 	}
-	for _, excl := range m.config.ExcludeMetrics {
-		if _, ok := m.allowedMetrics[excl]; ok {
-			m.allowedMetrics[excl] = false
-		}
+	for name := range m.allowedMetrics {
+		m.allowedMetrics[name] = !m.config.IsMetricExcluded(name)
 	}
-	file, err := os.Open(MOUNTFILE)
+	file, err := hostfs.Open(MOUNTFILE)
 	if err != nil {
 		return fmt.Errorf("%s Init(): file open for file \"%s\" failed: %w", m.name, MOUNTFILE, err)
 	}
@@ -75,7 +85,7 @@ func (m *DiskstatCollector) Read(interval time.Duration, output chan lp.CCMessag
 		return
 	}
 
-	file, err := os.Open(MOUNTFILE)
+	file, err := hostfs.Open(MOUNTFILE)
 	if err != nil {
 		cclog.ComponentError(
 			m.name,
@@ -92,7 +102,6 @@ func (m *DiskstatCollector) Read(interval time.Duration, output chan lp.CCMessag
 
 	part_max_used := uint64(0)
 	scanner := bufio.NewScanner(file)
-mountLoop:
 	for scanner.Scan() {
 		line := scanner.Text()
 		if len(line) == 0 {
@@ -111,14 +120,13 @@ mountLoop:
 
 		mountPath := strings.ReplaceAll(linefields[1], `\040`, " ")
 
-		for _, excl := range m.config.ExcludeMounts {
-			if strings.Contains(mountPath, excl) {
-				continue mountLoop
-			}
+		// Devices are identified by the device file and the mount point
+		if m.config.IsDeviceExcluded(linefields[0], mountPath) {
+			continue
 		}
 
 		stat := syscall.Statfs_t{}
-		err := syscall.Statfs(mountPath, &stat)
+		err := syscall.Statfs(hostfs.Path(mountPath), &stat)
 		if err != nil {
 			continue
 		}
@@ -126,16 +134,23 @@ mountLoop:
 			continue
 		}
 		tags := map[string]string{"type": "node", "device": linefields[0]}
+		if m.config.AddAffinityTags {
+			device := linefields[0]
+			if _, ok := m.affinityTags[device]; !ok {
+				m.affinityTags[device] = classAffinityTags("block", filepath.Base(device))
+			}
+			addAffinityTags(tags, m.affinityTags[device])
+		}
 		total := (stat.Blocks * uint64(stat.Bsize)) / uint64(1000_000_000)
 		if m.allowedMetrics["disk_total"] {
-			if y, err := lp.NewMetric("disk_total", tags, m.meta, total, time.Now()); err == nil {
+			if y, err := lp.NewMetric("disk_total", tags, m.meta, total, hostfs.Now()); err == nil {
 				y.AddMeta("unit", "GBytes")
 				output <- y
 			}
 		}
 		free := (stat.Bfree * uint64(stat.Bsize)) / uint64(1000_000_000)
 		if m.allowedMetrics["disk_free"] {
-			if y, err := lp.NewMetric("disk_free", tags, m.meta, free, time.Now()); err == nil {
+			if y, err := lp.NewMetric("disk_free", tags, m.meta, free, hostfs.Now()); err == nil {
 				y.AddMeta("unit", "GBytes")
 				output <- y
 			}
@@ -153,7 +168,7 @@ mountLoop:
 			"Read(): Call to scanner.Err failed: %s", err.Error())
 	}
 	if m.allowedMetrics["part_max_used"] {
-		y, err := lp.NewMetric("part_max_used", map[string]string{"type": "node"}, m.meta, int(part_max_used), time.Now())
+		y, err := lp.NewMetric("part_max_used", map[string]string{"type": "node"}, m.meta, int(part_max_used), hostfs.Now())
 		if err == nil {
 			y.AddMeta("unit", "percent")
 			output <- y
@@ -161,6 +176,16 @@ mountLoop:
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *DiskstatCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	return []mc.Metric{
+		{Name: "disk_total", Unit: "GBytes", Types: types, Kind: mc.Gauge, Description: "Size of the filesystem on the device"},
+		{Name: "disk_free", Unit: "GBytes", Types: types, Kind: mc.Gauge, Description: "Free space of the filesystem on the device"},
+		{Name: "part_max_used", Unit: "percent", Types: types, Kind: mc.Gauge, Description: "Maximal usage of all mounted filesystems"},
+	}
+}
+
 func (m *DiskstatCollector) Close() {
 	m.init = false
 }
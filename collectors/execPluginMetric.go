@@ -0,0 +1,398 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+const (
+	EXEC_PLUGIN_PROTOCOL          = 1                // Version of the plugin protocol
+	EXEC_PLUGIN_DEFAULT_TIMEOUT   = 10 * time.Second // Deadline for the handshake and responses
+	EXEC_PLUGIN_DEFAULT_MAX_DELAY = 5 * time.Minute  // Maximal delay between restarts
+	EXEC_PLUGIN_MAX_LINE_SIZE     = 1024 * 1024      // Maximal size of a line sent by the plugin
+)
+
+type ExecPluginCollectorConfig struct {
+	Command         string   `json:"command"`                     // Path of the plugin
+	Args            []string `json:"args,omitempty"`              // Command line arguments of the plugin
+	Timeout         string   `json:"timeout,omitempty"`           // Deadline for the handshake and each response
+	MaxRestartDelay string   `json:"max_restart_delay,omitempty"` // Maximal delay between restarts of a failing plugin
+	MetricFilter
+}
+
+// execPluginRequest is sent to the plugin on stdin
+type execPluginRequest struct {
+	Request   string     `json:"request"`             // "init" or "read"
+	Protocol  int        `json:"protocol,omitempty"`  // Protocol version (init)
+	Timestamp *time.Time `json:"timestamp,omitempty"` // Time of the tick in RFC 3339 format (read)
+}
+
+// execPluginHello is the answer of the plugin to the init request
+type execPluginHello struct {
+	Name    string      `json:"name"`              // Name of the plugin
+	Catalog []mc.Metric `json:"catalog,omitempty"` // Metrics the plugin can send
+}
+
+// execPluginMessage is a message sent by the plugin in JSON format
+type execPluginMessage struct {
+	Name      string            `json:"name"`
+	Tags      map[string]string `json:"tags"`
+	Meta      map[string]string `json:"meta"`
+	Fields    map[string]any    `json:"fields"`
+	Timestamp *time.Time        `json:"timestamp,omitempty"`
+}
+
+// execPlugin is a running plugin process
+type execPlugin struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string // Lines of the plugin's stdout, closed when the plugin exited
+}
+
+// ExecPluginCollector starts a plugin process once and requests its metrics
+// at every tick. Crashed or hanging plugins are restarted with an increasing
+// delay.
+type ExecPluginCollector struct {
+	BaseCollector
+	config          ExecPluginCollectorConfig
+	timeout         time.Duration // Deadline for the handshake and each response
+	maxRestartDelay time.Duration // Maximal delay between restarts
+	restartDelay    time.Duration // Current delay between restarts
+	nextStart       time.Time     // Earliest time for the next restart
+	plugin          *execPlugin   // Running plugin, nil if not running
+	pluginName      string        // Name of the plugin from the handshake
+	catalog         []mc.Metric   // Metric catalog from the handshake
+}
+
+func (m *ExecPluginCollector) Init(config json.RawMessage) error {
+	m.name = "ExecPluginCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+
+	// Read configuration
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+	if len(m.config.Command) == 0 {
+		return fmt.Errorf("%s Init(): no plugin command configured", m.name)
+	}
+	m.timeout = EXEC_PLUGIN_DEFAULT_TIMEOUT
+	if len(m.config.Timeout) > 0 {
+		t, err := time.ParseDuration(m.config.Timeout)
+		if err != nil {
+			return fmt.Errorf("%s Init(): failed to parse timeout '%s': %w", m.name, m.config.Timeout, err)
+		}
+		m.timeout = t
+	}
+	m.maxRestartDelay = EXEC_PLUGIN_DEFAULT_MAX_DELAY
+	if len(m.config.MaxRestartDelay) > 0 {
+		t, err := time.ParseDuration(m.config.MaxRestartDelay)
+		if err != nil {
+			return fmt.Errorf("%s Init(): failed to parse max_restart_delay '%s': %w", m.name, m.config.MaxRestartDelay, err)
+		}
+		m.maxRestartDelay = t
+	}
+	m.restartDelay = 0
+	m.nextStart = time.Time{}
+
+	// Start the plugin and get its name and metric catalog. If this fails,
+	// Read retries after a delay.
+	if err := m.start(); err != nil {
+		m.backoff()
+		cclog.ComponentError(m.name, fmt.Sprintf("Init(): %v, retrying in %v", err, m.restartDelay))
+	}
+
+	m.init = true
+	return nil
+}
+
+// start starts the plugin process and performs the handshake
+func (m *ExecPluginCollector) start() error {
+	cmd := exec.Command(m.config.Command, m.config.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe for plugin '%s': %w", m.config.Command, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe for plugin '%s': %w", m.config.Command, err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe for plugin '%s': %w", m.config.Command, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin '%s': %w", m.config.Command, err)
+	}
+	p := &execPlugin{
+		cmd:   cmd,
+		stdin: stdin,
+		lines: make(chan string),
+	}
+
+	// Forward stderr of the plugin to the log
+	stderrDone := make(chan struct{})
+	go func() {
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			cclog.ComponentWarn(m.name, fmt.Sprintf("Plugin %s: %s", m.config.Command, s.Text()))
+		}
+		close(stderrDone)
+	}()
+
+	// Read stdout of the plugin line by line until the plugin exits
+	go func() {
+		s := bufio.NewScanner(stdout)
+		s.Buffer(make([]byte, 64*1024), EXEC_PLUGIN_MAX_LINE_SIZE)
+		for s.Scan() {
+			p.lines <- s.Text()
+		}
+		if err := s.Err(); err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Failed to read output of plugin %s: %v", m.config.Command, err))
+			_ = cmd.Process.Kill()
+		}
+		<-stderrDone
+		if err := cmd.Wait(); err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Plugin %s exited: %v", m.config.Command, err))
+		} else {
+			cclog.ComponentDebug(m.name, fmt.Sprintf("Plugin %s exited", m.config.Command))
+		}
+		close(p.lines)
+	}()
+
+	// Handshake
+	var hello execPluginHello
+	err = p.send(execPluginRequest{Request: "init", Protocol: EXEC_PLUGIN_PROTOCOL})
+	if err == nil {
+		deadline := time.NewTimer(m.timeout)
+		defer deadline.Stop()
+		var line string
+		if line, err = p.receive(deadline.C); err == nil {
+			err = json.Unmarshal([]byte(line), &hello)
+		}
+	}
+	if err != nil {
+		p.kill()
+		return fmt.Errorf("handshake with plugin '%s' failed: %w", m.config.Command, err)
+	}
+
+	m.pluginName = hello.Name
+	if len(m.pluginName) == 0 {
+		m.pluginName = filepath.Base(m.config.Command)
+	}
+	m.meta = map[string]string{
+		"source": m.pluginName,
+		"group":  "Plugin",
+	}
+	m.catalog = hello.Catalog
+	for i := range m.catalog {
+		if len(m.catalog[i].Kind) == 0 {
+			m.catalog[i].Kind = mc.Gauge
+		}
+	}
+	m.plugin = p
+	cclog.ComponentDebug(m.name, fmt.Sprintf("Started plugin %s (%s)", m.pluginName, m.config.Command))
+	return nil
+}
+
+// send sends a request to the plugin
+func (p *execPlugin) send(request execPluginRequest) error {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if _, err := p.stdin.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	return nil
+}
+
+// receive returns the next line sent by the plugin
+func (p *execPlugin) receive(deadline <-chan time.Time) (string, error) {
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return "", errors.New("plugin exited")
+		}
+		return line, nil
+	case <-deadline:
+		return "", errors.New("timeout waiting for plugin")
+	}
+}
+
+// kill kills the plugin process
+func (p *execPlugin) kill() {
+	_ = p.stdin.Close()
+	_ = p.cmd.Process.Kill()
+	// Discard remaining output, so the reader can wait for the process
+	go func() {
+		for range p.lines {
+		}
+	}()
+}
+
+// stop closes stdin of the plugin and waits for the plugin to exit. The plugin
+// is killed if it does not exit before the timeout.
+func (p *execPlugin) stop(timeout time.Duration) {
+	_ = p.stdin.Close()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case _, ok := <-p.lines:
+			if !ok {
+				return
+			}
+		case <-deadline.C:
+			p.kill()
+			return
+		}
+	}
+}
+
+// parseExecPluginMessage parses a line sent by the plugin in JSON format or
+// InfluxDB line protocol. JSON messages without timestamp get the time of the
+// tick.
+func parseExecPluginMessage(line string, tick time.Time) (lp.CCMessage, error) {
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var j execPluginMessage
+		if err := json.Unmarshal([]byte(line), &j); err != nil {
+			return nil, err
+		}
+		t := tick
+		if j.Timestamp != nil {
+			t = *j.Timestamp
+		}
+		return lp.NewMessage(j.Name, j.Tags, j.Meta, j.Fields, t)
+	}
+	messages, err := lp.FromBytes([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("expected one message, got %d", len(messages))
+	}
+	return messages[0], nil
+}
+
+// read requests the messages of a tick from the plugin. The response ends
+// with an empty line.
+func (m *ExecPluginCollector) read(tick time.Time) ([]lp.CCMessage, error) {
+	if err := m.plugin.send(execPluginRequest{Request: "read", Timestamp: &tick}); err != nil {
+		return nil, err
+	}
+	deadline := time.NewTimer(m.timeout)
+	defer deadline.Stop()
+	messages := make([]lp.CCMessage, 0)
+	for {
+		line, err := m.plugin.receive(deadline.C)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return messages, nil
+		}
+		msg, err := parseExecPluginMessage(line, tick)
+		if err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to decode message of plugin %s: %v", m.pluginName, err))
+			continue
+		}
+		messages = append(messages, msg)
+	}
+}
+
+// backoff schedules the next start of the plugin. The delay doubles with
+// every failure up to max_restart_delay.
+func (m *ExecPluginCollector) backoff() {
+	m.restartDelay = min(max(2*m.restartDelay, time.Second), m.maxRestartDelay)
+	m.nextStart = time.Now().Add(m.restartDelay)
+}
+
+func (m *ExecPluginCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+	now := time.Now()
+
+	// Restart the plugin after a failure
+	if m.plugin == nil {
+		if now.Before(m.nextStart) {
+			return
+		}
+		if err := m.start(); err != nil {
+			m.backoff()
+			cclog.ComponentError(m.name, fmt.Sprintf("Read(): %v, retrying in %v", err, m.restartDelay))
+			return
+		}
+	}
+
+	messages, err := m.read(now)
+	if err != nil {
+		m.plugin.kill()
+		m.plugin = nil
+		m.backoff()
+		cclog.ComponentError(m.name, fmt.Sprintf("Read(): Plugin %s failed: %v, restarting in %v", m.pluginName, err, m.restartDelay))
+		return
+	}
+	m.restartDelay = 0
+
+	for _, msg := range messages {
+		if m.config.IsMetricExcluded(msg.Name()) {
+			continue
+		}
+		for k, v := range m.meta {
+			if !msg.HasMeta(k) {
+				msg.AddMeta(k, v)
+			}
+		}
+		output <- msg
+	}
+}
+
+// Catalog returns the metrics the collector can send. After initialization,
+// these are the metrics declared by the plugin in the handshake.
+func (m *ExecPluginCollector) Catalog() []mc.Metric {
+	if m.init && len(m.catalog) > 0 {
+		return slices.Clone(m.catalog)
+	}
+	return []mc.Metric{
+		{Name: "*", Unit: mc.AnyUnit, Kind: mc.Gauge, Description: "Metric sent by the plugin, see the metric catalog declared by the plugin"},
+	}
+}
+
+func (m *ExecPluginCollector) Close() {
+	if m.plugin != nil {
+		m.plugin.stop(m.timeout)
+		m.plugin = nil
+	}
+	m.init = false
+}
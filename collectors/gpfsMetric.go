@@ -13,8 +13,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"os/exec"
 	"os/user"
 	"slices"
 	"strconv"
@@ -24,6 +22,8 @@ import (
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const DEFAULT_GPFS_CMD = "mmpmon"
@@ -32,14 +32,14 @@ type GpfsCollectorState map[string]int64
 
 type GpfsCollectorConfig struct {
 	Mmpmon             string   `json:"mmpmon_path,omitempty"`
-	ExcludeFilesystems []string `json:"exclude_filesystem,omitempty"`
-	ExcludeMetrics     []string `json:"exclude_metrics,omitempty"`
+	ExcludeFilesystems []string `json:"exclude_filesystem,omitempty"` // Alias of exclude_devices
 	Sudo               bool     `json:"use_sudo,omitempty"`
 	SendAbsoluteValues bool     `json:"send_abs_values,omitempty"`
 	SendDiffValues     bool     `json:"send_diff_values,omitempty"`
 	SendDerivedValues  bool     `json:"send_derived_values,omitempty"`
 	SendTotalValues    bool     `json:"send_total_values,omitempty"`
 	SendBandwidths     bool     `json:"send_bandwidths,omitempty"`
+	MetricFilter
 }
 
 type GpfsMetricDefinition struct {
@@ -51,12 +51,11 @@ type GpfsMetricDefinition struct {
 }
 
 type GpfsCollector struct {
-	metricCollector
+	BaseCollector
 
 	tags          map[string]string
 	config        GpfsCollectorConfig
 	sudoCmd       string
-	skipFS        map[string]struct{}
 	lastTimestamp map[string]time.Time          // Store timestamp of lastState per filesystem to derive bandwidths
 	definitions   []GpfsMetricDefinition        // all metrics to report
 	lastState     map[string]GpfsCollectorState // one GpfsCollectorState per filesystem
@@ -328,6 +327,11 @@ func (m *GpfsCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	// exclude_filesystem is an alias of exclude_devices
+	m.config.ExcludeDevices = append(m.config.ExcludeDevices, m.config.ExcludeFilesystems...)
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.meta = map[string]string{
 		"source": m.name,
 		"group":  "GPFS",
@@ -336,10 +340,6 @@ func (m *GpfsCollector) Init(config json.RawMessage) error {
 		"type":       "node",
 		"filesystem": "",
 	}
-	m.skipFS = make(map[string]struct{})
-	for _, fs := range m.config.ExcludeFilesystems {
-		m.skipFS[fs] = struct{}{}
-	}
 	m.lastState = make(map[string]GpfsCollectorState)
 	m.lastTimestamp = make(map[string]time.Time)
 
@@ -353,7 +353,7 @@ func (m *GpfsCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): GPFS file system statistics can only be queried by user root", m.name)
 		}
 	} else {
-		p, err := exec.LookPath("sudo")
+		p, err := hostfs.LookPath("sudo")
 		if err != nil {
 			return fmt.Errorf("%s Init(): cannot find 'sudo': %w", m.name, err)
 		}
@@ -367,7 +367,7 @@ func (m *GpfsCollector) Init(config json.RawMessage) error {
 	}
 
 	// Check if mmpmon is in executable search path
-	p, err := exec.LookPath(m.config.Mmpmon)
+	p, err := hostfs.LookPath(m.config.Mmpmon)
 	if err != nil {
 		// if using sudo, exec.lookPath will return EACCES (file mode r-x------), this can be ignored
 		if m.config.Sudo && errors.Is(err, syscall.EACCES) {
@@ -383,28 +383,28 @@ func (m *GpfsCollector) Init(config json.RawMessage) error {
 	m.definitions = []GpfsMetricDefinition{}
 	if m.config.SendAbsoluteValues {
 		for _, def := range GpfsAbsMetrics {
-			if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+			if !m.config.IsMetricExcluded(def.name) {
 				m.definitions = append(m.definitions, def)
 			}
 		}
 	}
 	if m.config.SendDiffValues {
 		for _, def := range GpfsDiffMetrics {
-			if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+			if !m.config.IsMetricExcluded(def.name) {
 				m.definitions = append(m.definitions, def)
 			}
 		}
 	}
 	if m.config.SendDerivedValues {
 		for _, def := range GpfsDeriveMetrics {
-			if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+			if !m.config.IsMetricExcluded(def.name) {
 				m.definitions = append(m.definitions, def)
 			}
 		}
 	} else if m.config.SendBandwidths {
 		for _, def := range GpfsDeriveMetrics {
 			if def.unit == "bytes/sec" {
-				if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+				if !m.config.IsMetricExcluded(def.name) {
 					m.definitions = append(m.definitions, def)
 				}
 			}
@@ -412,7 +412,7 @@ func (m *GpfsCollector) Init(config json.RawMessage) error {
 	}
 	if m.config.SendTotalValues {
 		for _, def := range GpfsTotalMetrics {
-			if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+			if !m.config.IsMetricExcluded(def.name) {
 				// only send total metrics of the types requested
 				if (def.calc == "none" && m.config.SendAbsoluteValues) ||
 					(def.calc == "difference" && m.config.SendDiffValues) ||
@@ -424,7 +424,7 @@ func (m *GpfsCollector) Init(config json.RawMessage) error {
 	} else if m.config.SendBandwidths {
 		for _, def := range GpfsTotalMetrics {
 			if def.unit == "bytes/sec" {
-				if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+				if !m.config.IsMetricExcluded(def.name) {
 					m.definitions = append(m.definitions, def)
 				}
 			}
@@ -448,26 +448,20 @@ func (m *GpfsCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	// -p: generate output that can be parsed
 	// -s: suppress the prompt on input
 	// fs_io_s: Displays I/O statistics per mounted file system
-	var cmd *exec.Cmd
+	var cmd *hostfs.Cmd
 	if m.config.Sudo {
-		cmd = exec.Command(m.sudoCmd, m.config.Mmpmon, "-p", "-s")
+		cmd = hostfs.Command(m.sudoCmd, m.config.Mmpmon, "-p", "-s")
 	} else {
-		cmd = exec.Command(m.config.Mmpmon, "-p", "-s")
+		cmd = hostfs.Command(m.config.Mmpmon, "-p", "-s")
 	}
 
-	cmd.Stdin = strings.NewReader("once fs_io_s\n")
-	cmdStdout := new(bytes.Buffer)
-	cmdStderr := new(bytes.Buffer)
-	cmd.Stdout = cmdStdout
-	cmd.Stderr = cmdStderr
-	err := cmd.Run()
+	cmd.Stdin = "once fs_io_s\n"
+	dataStdOut, dataStdErr, err := cmd.Run()
 	if err != nil {
-		dataStdErr, _ := io.ReadAll(cmdStderr)
-		dataStdOut, _ := io.ReadAll(cmdStdout)
 		cclog.ComponentError(
 			m.name,
 			fmt.Sprintf("Read(): Failed to execute command \"%s\": %v\n", cmd.String(), err),
-			fmt.Sprintf("Read(): command exit code: \"%d\"\n", cmd.ProcessState.ExitCode()),
+			fmt.Sprintf("Read(): command exit code: \"%d\"\n", hostfs.ExitCode(err)),
 			fmt.Sprintf("Read(): command stderr: \"%s\"\n", string(dataStdErr)),
 			fmt.Sprintf("Read(): command stdout: \"%s\"\n", string(dataStdOut)),
 		)
@@ -475,7 +469,7 @@ func (m *GpfsCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	}
 
 	// Read I/O statistics
-	scanner := bufio.NewScanner(cmdStdout)
+	scanner := bufio.NewScanner(bytes.NewReader(dataStdOut))
 	for scanner.Scan() {
 		lineSplit := strings.Fields(scanner.Text())
 
@@ -502,7 +496,7 @@ func (m *GpfsCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 		}
 
 		// Skip excluded filesystems
-		if _, skip := m.skipFS[filesystem]; skip {
+		if m.config.IsDeviceExcluded(filesystem) {
 			continue
 		}
 
@@ -648,6 +642,38 @@ func (m *GpfsCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *GpfsCollector) Catalog() []mc.Metric {
+	metrics := make([]mc.Metric, 0)
+	add := func(defs []GpfsMetricDefinition, options ...string) {
+		for _, def := range defs {
+			kind := mc.Gauge
+			option := "send_abs_values"
+			switch def.calc {
+			case "none":
+				kind = mc.Counter
+			case "difference":
+				option = "send_diff_values"
+			case "derivative":
+				option = "send_derived_values"
+			}
+			metrics = append(metrics, mc.Metric{
+				Name:        def.name,
+				Unit:        def.unit,
+				Types:       []string{"node"},
+				Kind:        kind,
+				Description: "GPFS " + def.desc,
+				Options:     append(slices.Clone(options), option),
+			})
+		}
+	}
+	add(GpfsAbsMetrics)
+	add(GpfsDiffMetrics)
+	add(GpfsDeriveMetrics)
+	add(GpfsTotalMetrics, "send_total_values")
+	return metrics
+}
+
 func (m *GpfsCollector) Close() {
 	m.init = false
 }
@@ -0,0 +1,262 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+// See: https://www.kernel.org/doc/html/latest/hwmon/sysfs-interface.html
+// /sys/class/hwmon/hwmon*/name -> amd_energy
+// /sys/class/hwmon/hwmon*/energy*_label -> Esocket0
+// /sys/class/hwmon/hwmon*/energy*_input -> 2103403118 = 2103.4 J
+// /sys/class/hwmon/hwmon*/power*_average -> 243000000 = 243.0 W
+
+// hwmonSensorType describes the sensor files of a sensor type
+type hwmonSensorType struct {
+	prefix string  // Prefix of the sensor files like 'in' for in0_input
+	suffix string  // Suffix of the sensor files like '_input'
+	metric string  // Metric name
+	unit   string  // Unit of the metric
+	scale  float64 // Factor to convert the value of the file to the unit
+	kind   mc.Kind
+	desc   string
+}
+
+var hwmonSensorTypes = []hwmonSensorType{
+	{"power", "_input", "hwmon_power", "Watt", 1e-6, mc.Gauge, "Power"},
+	{"power", "_average", "hwmon_power_average", "Watt", 1e-6, mc.Gauge, "Average power over the averaging interval of the sensor"},
+	{"energy", "_input", "hwmon_energy", "Joule", 1e-6, mc.Counter, "Energy"},
+	{"in", "_input", "hwmon_voltage", "Volt", 1e-3, mc.Gauge, "Voltage"},
+	{"curr", "_input", "hwmon_current", "Ampere", 1e-3, mc.Gauge, "Current"},
+	{"fan", "_input", "hwmon_fan", "RPM", 1, mc.Gauge, "Fan speed"},
+	{"temp", "_input", "hwmon_temp", "degC", 1e-3, mc.Gauge, "Temperature"},
+}
+
+// Sensor types read by default. Temperatures are sent by the tempstat
+// collector.
+var hwmonDefaultSensorTypes = []string{"power", "energy", "in", "curr", "fan"}
+
+type HwmonCollectorSensor struct {
+	sensorType *hwmonSensorType
+	file       string
+	tags       map[string]string
+	lastEnergy float64 // Energy of the last read for the derived power
+	hasLast    bool
+}
+
+// HwmonCollector reads the power, energy, voltage, current, fan and
+// optionally temperature sensors of the hwmon devices
+type HwmonCollector struct {
+	BaseCollector
+
+	config struct {
+		SensorTypes     []string                     `json:"sensor_types,omitempty"`
+		TagOverride     map[string]map[string]string `json:"tag_override"`
+		SendEnergyPower bool                         `json:"send_energy_power"`
+		// Devices are identified by hwmon name and directory, e.g. amd_energy or hwmon1
+		MetricFilter
+	}
+	sensors       []*HwmonCollectorSensor
+	lastTimestamp time.Time
+}
+
+func (m *HwmonCollector) Init(config json.RawMessage) error {
+	// Check if already initialized
+	if m.init {
+		return nil
+	}
+
+	m.name = "HwmonCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.config.SendEnergyPower = true
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+	sensorTypes := m.config.SensorTypes
+	if len(sensorTypes) == 0 {
+		sensorTypes = hwmonDefaultSensorTypes
+	}
+	for _, t := range sensorTypes {
+		if !slices.ContainsFunc(hwmonSensorTypes, func(s hwmonSensorType) bool { return s.prefix == t }) {
+			return fmt.Errorf("%s Init(): unknown sensor type '%s'", m.name, t)
+		}
+	}
+
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "Hwmon",
+	}
+
+	m.sensors = make([]*HwmonCollectorSensor, 0)
+	for i := range hwmonSensorTypes {
+		sensorType := &hwmonSensorTypes[i]
+		if !slices.Contains(sensorTypes, sensorType.prefix) {
+			continue
+		}
+
+		// Find all sensor files of the sensor type
+		globPattern := filepath.Join("/sys/class/hwmon", "*", sensorType.prefix+"[0-9]*"+sensorType.suffix)
+		files, err := hostfs.Glob(globPattern)
+		if err != nil {
+			return fmt.Errorf("%s Init(): unable to glob files with pattern '%s': %w", m.name, globPattern, err)
+		}
+
+		for _, file := range files {
+			dir := filepath.Dir(file)
+			sensorName := strings.TrimSuffix(filepath.Base(file), sensorType.suffix)
+
+			// hwmon device name
+			device := filepath.Base(dir)
+			if name, err := hostfs.ReadFile(filepath.Join(dir, "name")); err == nil {
+				device = strings.TrimSpace(string(name))
+			}
+
+			// Skip excluded devices
+			if m.config.IsDeviceExcluded(device, filepath.Base(dir)) {
+				continue
+			}
+
+			// Skip sensors without a valid value, e.g. unconnected fans
+			if _, err := readInt64File(file); err != nil {
+				continue
+			}
+
+			// sensor label
+			label := sensorName
+			if buffer, err := hostfs.ReadFile(filepath.Join(dir, sensorName+"_label")); err == nil {
+				label = strings.TrimSpace(string(buffer))
+			}
+
+			// Sensor tags
+			tags := map[string]string{
+				"type": "node",
+			}
+
+			// Apply tag override configuration. The key of a single sensor like
+			// hwmon3/energy2 takes precedence over the key of its device like hwmon3.
+			hwmon := filepath.Base(dir)
+			for _, key := range []string{hwmon + "/" + sensorName, hwmon} {
+				if newtags, ok := m.config.TagOverride[key]; ok {
+					tags = maps.Clone(newtags)
+					break
+				}
+			}
+			tags["device"] = device
+			tags["sensor"] = label
+
+			m.sensors = append(m.sensors,
+				&HwmonCollectorSensor{
+					sensorType: sensorType,
+					file:       file,
+					tags:       tags,
+				})
+		}
+	}
+
+	// Empty sensors map
+	if len(m.sensors) == 0 {
+		return fmt.Errorf("%s Init(): no hwmon sensors found for sensor types %v", m.name, sensorTypes)
+	}
+
+	// Finished initialization
+	m.lastTimestamp = time.Time{}
+	m.init = true
+	return nil
+}
+
+func (m *HwmonCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+
+	now := hostfs.Now()
+	timeDiff := now.Sub(m.lastTimestamp).Seconds()
+	m.lastTimestamp = now
+
+	for _, sensor := range m.sensors {
+		t := sensor.sensorType
+
+		// Read sensor file
+		x, err := readInt64File(sensor.file)
+		if err != nil {
+			cclog.ComponentError(
+				m.name,
+				fmt.Sprintf("Read(): Failed to read file '%s': %v", sensor.file, err))
+			continue
+		}
+		value := float64(x) * t.scale
+
+		if !m.config.IsMetricExcluded(t.metric) {
+			if y, err := lp.NewMetric(t.metric, sensor.tags, m.meta, value, now); err == nil {
+				y.AddMeta("unit", t.unit)
+				output <- y
+			}
+		}
+
+		// Average power derived from energy counters
+		if t.prefix == "energy" && m.config.SendEnergyPower {
+			if sensor.hasLast && value >= sensor.lastEnergy && timeDiff > 0 &&
+				!m.config.IsMetricExcluded("hwmon_energy_power") {
+				power := (value - sensor.lastEnergy) / timeDiff
+				if y, err := lp.NewMetric("hwmon_energy_power", sensor.tags, m.meta, power, now); err == nil {
+					y.AddMeta("unit", "Watt")
+					output <- y
+				}
+			}
+			sensor.lastEnergy = value
+			sensor.hasLast = true
+		}
+	}
+}
+
+// Catalog returns the metrics the collector can send
+func (m *HwmonCollector) Catalog() []mc.Metric {
+	metrics := make([]mc.Metric, 0, len(hwmonSensorTypes)+1)
+	for _, t := range hwmonSensorTypes {
+		var options []string
+		if !slices.Contains(hwmonDefaultSensorTypes, t.prefix) {
+			options = []string{"sensor_types"}
+		}
+		metrics = append(metrics, mc.Metric{
+			Name: t.metric, Unit: t.unit, Kind: t.kind, Options: options,
+			Description: fmt.Sprintf("%s of a hwmon sensor (%s*%s)", t.desc, t.prefix, t.suffix),
+		})
+	}
+	metrics = append(metrics, mc.Metric{
+		Name: "hwmon_energy_power", Unit: "Watt", Kind: mc.Gauge,
+		Description: "Average power of a hwmon energy sensor since the last read",
+	})
+	return metrics
+}
+
+func (m *HwmonCollector) Close() {
+	m.init = false
+}
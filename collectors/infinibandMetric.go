@@ -13,13 +13,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	"golang.org/x/sys/unix"
 )
 
@@ -53,13 +54,15 @@ type InfinibandCollectorInfo struct {
 }
 
 type InfinibandCollector struct {
-	metricCollector
+	BaseCollector
 
 	config struct {
-		ExcludeDevices     []string `json:"exclude_devices,omitempty"` // IB device to exclude e.g. mlx5_0
-		SendAbsoluteValues bool     `json:"send_abs_values"`           // Send absolut values as read from sys filesystem
-		SendTotalValues    bool     `json:"send_total_values"`         // Send computed total values
-		SendDerivedValues  bool     `json:"send_derived_values"`       // Send derived values e.g. rates
+		SendAbsoluteValues bool `json:"send_abs_values"`             // Send absolut values as read from sys filesystem
+		SendTotalValues    bool `json:"send_total_values"`           // Send computed total values
+		SendDerivedValues  bool `json:"send_derived_values"`         // Send derived values e.g. rates
+		AddAffinityTags    bool `json:"add_affinity_tags,omitempty"` // Add tags numa and socket of the IB device
+		// Metrics and IB devices to exclude e.g. mlx5_0
+		MetricFilter
 	}
 	info          []InfinibandCollectorInfo
 	lastTimestamp time.Time // Store time stamp of last tick to derive bandwidths
@@ -94,10 +97,13 @@ func (m *InfinibandCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	// Loop for all InfiniBand directories
 	globPattern := filepath.Join(ibBasePath, "*", "ports", "*")
-	ibDirs, err := filepath.Glob(globPattern)
+	ibDirs, err := hostfs.Glob(globPattern)
 	if err != nil {
 		return fmt.Errorf("%s Init(): unable to glob files with pattern %s: %w", m.name, globPattern, err)
 	}
@@ -108,7 +114,7 @@ func (m *InfinibandCollector) Init(config json.RawMessage) error {
 	for _, path := range ibDirs {
 
 		// Skip, when no LID is assigned
-		line, err := os.ReadFile(filepath.Join(path, "lid"))
+		line, err := hostfs.ReadFile(filepath.Join(path, "lid"))
 		if err != nil {
 			continue
 		}
@@ -123,7 +129,7 @@ func (m *InfinibandCollector) Init(config json.RawMessage) error {
 		port := pathSplit[6]
 
 		// Skip excluded devices
-		if slices.Contains(m.config.ExcludeDevices, device) {
+		if m.config.IsDeviceExcluded(device) {
 			continue
 		}
 
@@ -170,24 +176,29 @@ func (m *InfinibandCollector) Init(config json.RawMessage) error {
 			},
 		}
 		for _, counter := range portCounterFiles {
-			err := unix.Access(counter.path, unix.R_OK)
+			err := hostfs.Access(counter.path, unix.R_OK)
 			if err != nil {
 				return fmt.Errorf("%s Init(): unable to access %s: %w", m.name, counter.path, err)
 			}
 		}
 
+		tagSet := map[string]string{
+			"type":   "node",
+			"device": device,
+			"port":   port,
+			"lid":    LID,
+		}
+		if m.config.AddAffinityTags {
+			addAffinityTags(tagSet, classAffinityTags("infiniband", device))
+		}
+
 		m.info = append(m.info,
 			InfinibandCollectorInfo{
 				lid:              LID,
 				device:           device,
 				port:             port,
 				portCounterFiles: portCounterFiles,
-				tagSet: map[string]string{
-					"type":   "node",
-					"device": device,
-					"port":   port,
-					"lid":    LID,
-				},
+				tagSet:           tagSet,
 			})
 	}
 
@@ -207,7 +218,7 @@ func (m *InfinibandCollector) Read(interval time.Duration, output chan lp.CCMess
 	}
 
 	// Current time stamp
-	now := time.Now()
+	now := hostfs.Now()
 	// time difference to last time stamp
 	timeDiff := now.Sub(m.lastTimestamp).Seconds()
 	// Save current timestamp
@@ -223,7 +234,7 @@ func (m *InfinibandCollector) Read(interval time.Duration, output chan lp.CCMess
 			counterDef := &info.portCounterFiles[i]
 
 			// Read counter file
-			line, err := os.ReadFile(counterDef.path)
+			line, err := hostfs.ReadFile(counterDef.path)
 			if err != nil {
 				cclog.ComponentError(
 					m.name,
@@ -250,7 +261,7 @@ func (m *InfinibandCollector) Read(interval time.Duration, output chan lp.CCMess
 			}
 
 			// Send absolut values
-			if m.config.SendAbsoluteValues {
+			if m.config.SendAbsoluteValues && !m.config.IsMetricExcluded(counterDef.name) {
 				if y, err := lp.NewMetric(counterDef.name, info.tagSet, m.meta, vScaledCounter, now); err == nil {
 					y.AddMeta("unit", counterDef.unit)
 					output <- y
@@ -271,9 +282,11 @@ func (m *InfinibandCollector) Read(interval time.Duration, output chan lp.CCMess
 					if counterDef.scaleByFourLanes {
 						rate *= float64(4)
 					}
-					if y, err := lp.NewMetric(counterDef.name+"_bw", info.tagSet, m.meta, rate, now); err == nil {
-						y.AddMeta("unit", counterDef.unitRates)
-						output <- y
+					if !m.config.IsMetricExcluded(counterDef.name + "_bw") {
+						if y, err := lp.NewMetric(counterDef.name+"_bw", info.tagSet, m.meta, rate, now); err == nil {
+							y.AddMeta("unit", counterDef.unitRates)
+							output <- y
+						}
 					}
 
 					// Sum up rates for total rates
@@ -305,25 +318,25 @@ func (m *InfinibandCollector) Read(interval time.Duration, output chan lp.CCMess
 
 		// Send total values
 		if m.config.SendTotalValues {
-			if y, err := lp.NewMetric("ib_total", info.tagSet, m.meta, ibTotal, now); err == nil {
+			if y, err := lp.NewMetric("ib_total", info.tagSet, m.meta, ibTotal, now); err == nil && !m.config.IsMetricExcluded("ib_total") {
 				y.AddMeta("unit", ibDataUnit)
 				output <- y
 			}
 
-			if y, err := lp.NewMetric("ib_total_pkts", info.tagSet, m.meta, ibTotalPkts, now); err == nil {
+			if y, err := lp.NewMetric("ib_total_pkts", info.tagSet, m.meta, ibTotalPkts, now); err == nil && !m.config.IsMetricExcluded("ib_total_pkts") {
 				y.AddMeta("unit", ibPkgUnit)
 				output <- y
 			}
 
 			if m.config.SendDerivedValues && ibTotalBwAvailable {
-				if y, err := lp.NewMetric("ib_total_bw", info.tagSet, m.meta, ibTotalBw, now); err == nil {
+				if y, err := lp.NewMetric("ib_total_bw", info.tagSet, m.meta, ibTotalBw, now); err == nil && !m.config.IsMetricExcluded("ib_total_bw") {
 					y.AddMeta("unit", ibDataRateUnit)
 					output <- y
 				}
 			}
 
 			if m.config.SendDerivedValues && ibTotalPktsBwAvailable {
-				if y, err := lp.NewMetric("ib_total_pkts_bw", info.tagSet, m.meta, ibTotalPktsBw, now); err == nil {
+				if y, err := lp.NewMetric("ib_total_pkts_bw", info.tagSet, m.meta, ibTotalPktsBw, now); err == nil && !m.config.IsMetricExcluded("ib_total_pkts_bw") {
 					y.AddMeta("unit", ibPkgRateUnit)
 					output <- y
 				}
@@ -332,6 +345,27 @@ func (m *InfinibandCollector) Read(interval time.Duration, output chan lp.CCMess
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *InfinibandCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	derived := []string{"send_derived_values"}
+	total := []string{"send_total_values"}
+	return []mc.Metric{
+		{Name: "ib_recv", Unit: ibDataUnit, Types: types, Kind: mc.Counter, Description: "Received bytes"},
+		{Name: "ib_xmit", Unit: ibDataUnit, Types: types, Kind: mc.Counter, Description: "Transmitted bytes"},
+		{Name: "ib_recv_pkts", Unit: ibPkgUnit, Types: types, Kind: mc.Counter, Description: "Received packets"},
+		{Name: "ib_xmit_pkts", Unit: ibPkgUnit, Types: types, Kind: mc.Counter, Description: "Transmitted packets"},
+		{Name: "ib_total", Unit: ibDataUnit, Types: types, Kind: mc.Counter, Description: "Received and transmitted bytes", Options: total},
+		{Name: "ib_total_pkts", Unit: ibPkgUnit, Types: types, Kind: mc.Counter, Description: "Received and transmitted packets", Options: total},
+		{Name: "ib_recv_bw", Unit: ibDataRateUnit, Types: types, Kind: mc.Gauge, Description: "Receive bandwidth", Options: derived},
+		{Name: "ib_xmit_bw", Unit: ibDataRateUnit, Types: types, Kind: mc.Gauge, Description: "Transmit bandwidth", Options: derived},
+		{Name: "ib_recv_pkts_bw", Unit: ibPkgRateUnit, Types: types, Kind: mc.Gauge, Description: "Receive packet rate", Options: derived},
+		{Name: "ib_xmit_pkts_bw", Unit: ibPkgRateUnit, Types: types, Kind: mc.Gauge, Description: "Transmit packet rate", Options: derived},
+		{Name: "ib_total_bw", Unit: ibDataRateUnit, Types: types, Kind: mc.Gauge, Description: "Receive and transmit bandwidth", Options: append(total, derived...)},
+		{Name: "ib_total_pkts_bw", Unit: ibPkgRateUnit, Types: types, Kind: mc.Gauge, Description: "Receive and transmit packet rate", Options: append(total, derived...)},
+	}
+}
+
 func (m *InfinibandCollector) Close() {
 	m.init = false
 }
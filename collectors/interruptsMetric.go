@@ -0,0 +1,378 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+const (
+	INTERRUPTS_FILE = `/proc/interrupts`
+	SOFTIRQS_FILE   = `/proc/softirqs`
+)
+
+// Softirq types of /proc/softirqs and their descriptions
+var softirqTypes = []struct {
+	name string
+	desc string
+}{
+	{"HI", "high priority tasklets"},
+	{"TIMER", "timers"},
+	{"NET_TX", "network transmit processing"},
+	{"NET_RX", "network receive processing"},
+	{"BLOCK", "block device completions"},
+	{"IRQ_POLL", "interrupt polling of block devices"},
+	{"TASKLET", "tasklets"},
+	{"SCHED", "scheduler load balancing"},
+	{"HRTIMER", "high resolution timers"},
+	{"RCU", "read-copy-update processing"},
+}
+
+// Valid names of IRQ groups, used in the metric names
+var irqGroupNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+type InterruptsCollectorConfig struct {
+	IrqGroups          map[string][]string `json:"irq_groups,omitempty"` // Map of group names to patterns of IRQ numbers and names
+	SendHwthreadValues bool                `json:"send_hwthread_values"` // Send the rates of the hardware threads
+	SendNodeTotals     bool                `json:"send_node_totals"`     // Send the rates summed over all hardware threads
+	MetricFilter
+}
+
+// irqGroup is an IRQ group with its compiled patterns
+type irqGroup struct {
+	name     string
+	patterns []filterPattern
+}
+
+// InterruptsCollector reads the hardware interrupts from /proc/interrupts and
+// the software interrupts from /proc/softirqs and sends their rates per
+// hardware thread and for the node
+type InterruptsCollector struct {
+	BaseCollector
+	config         InterruptsCollectorConfig
+	groups         []irqGroup                // IRQ groups sorted by name
+	lineGroups     map[string][]int          // Indices of the groups matching an IRQ line by IRQ number and description
+	hwthreadTags   map[int]map[string]string // Tags of the hardware threads known to ccTopology
+	nodeTags       map[string]string         // Tags of the node totals
+	previousValues map[string]map[int]int64  // Values of the last read by metric name and hardware thread, -1 for the node
+	lastTimestamp  time.Time
+}
+
+func (m *InterruptsCollector) Init(config json.RawMessage) error {
+	m.name = "InterruptsCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "CPU",
+		"unit":   "interrupts/sec",
+	}
+	m.nodeTags = map[string]string{
+		"type": "node",
+	}
+
+	m.config.SendHwthreadValues = true
+	m.config.SendNodeTotals = true
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+
+	m.groups = make([]irqGroup, 0, len(m.config.IrqGroups))
+	for name, patterns := range m.config.IrqGroups {
+		if !irqGroupNameRegex.MatchString(name) {
+			return fmt.Errorf("%s Init(): invalid IRQ group name '%s'", m.name, name)
+		}
+		compiled, err := compileFilterPatterns("irq_groups."+name, patterns)
+		if err != nil {
+			return fmt.Errorf("%s Init(): %w", m.name, err)
+		}
+		m.groups = append(m.groups, irqGroup{name: name, patterns: compiled})
+	}
+	slices.SortFunc(m.groups, func(a, b irqGroup) int { return strings.Compare(a.name, b.name) })
+	m.lineGroups = make(map[string][]int)
+
+	// Check input files
+	for _, file := range []string{INTERRUPTS_FILE, SOFTIRQS_FILE} {
+		if _, err := hostfs.Stat(file); err != nil {
+			return fmt.Errorf("%s Init(): %w", m.name, err)
+		}
+	}
+
+	m.hwthreadTags = make(map[int]map[string]string)
+	for _, cpu := range ccTopology.HwthreadList() {
+		m.hwthreadTags[cpu] = map[string]string{
+			"type":    "hwthread",
+			"type-id": strconv.Itoa(cpu),
+		}
+	}
+
+	m.previousValues = make(map[string]map[int]int64)
+	m.lastTimestamp = time.Time{}
+	m.init = true
+	return nil
+}
+
+// parseCPUHeader parses the header line 'CPU0 CPU1 ...' of /proc/interrupts
+// and /proc/softirqs. Offline hardware threads are missing in the header.
+func parseCPUHeader(line string) ([]int, error) {
+	fields := strings.Fields(line)
+	cpus := make([]int, 0, len(fields))
+	for _, f := range fields {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(f, "CPU"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU column '%s'", f)
+		}
+		cpus = append(cpus, cpu)
+	}
+	return cpus, nil
+}
+
+// parseCounts parses the per CPU counts following the label of a line. It
+// returns the counts and the remaining fields.
+func parseCounts(fields []string, numCPUs int) ([]int64, []string) {
+	counts := make([]int64, 0, numCPUs)
+	for _, f := range fields {
+		if len(counts) == numCPUs {
+			break
+		}
+		v, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			break
+		}
+		counts = append(counts, v)
+	}
+	return counts, fields[len(counts):]
+}
+
+// matchGroups returns the indices of the IRQ groups matching an IRQ line.
+// Patterns match the IRQ number or name like '42' or 'LOC' and the names of
+// the IRQ actions like 'nvme0q1' or 'mlx5_comp3@pci:0000:81:00.0'.
+func (m *InterruptsCollector) matchGroups(irq string, desc []string) []int {
+	key := irq + " " + strings.Join(desc, " ")
+	if groups, ok := m.lineGroups[key]; ok {
+		return groups
+	}
+	names := []string{irq}
+	if _, err := strconv.Atoi(irq); err == nil && len(desc) > 2 {
+		// Numbered IRQs: chip name, hardware IRQ and trigger type, actions
+		for action := range strings.SplitSeq(strings.Join(desc[2:], " "), ",") {
+			names = append(names, strings.TrimSpace(action))
+		}
+	}
+	groups := make([]int, 0)
+	for i := range m.groups {
+		if matchAny(m.groups[i].patterns, names...) {
+			groups = append(groups, i)
+		}
+	}
+	m.lineGroups[key] = groups
+	return groups
+}
+
+// readInterrupts adds the counts of /proc/interrupts to the values by
+// metric name and CPU
+func (m *InterruptsCollector) readInterrupts(values map[string]map[int]int64) error {
+	data, err := hostfs.ReadFile(INTERRUPTS_FILE)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	cpus, err := parseCPUHeader(lines[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse file '%s': %w", INTERRUPTS_FILE, err)
+	}
+	total := make(map[int]int64, len(cpus))
+	groups := make([]map[int]int64, len(m.groups))
+	for i := range groups {
+		groups[i] = make(map[int]int64, len(cpus))
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		irq := strings.TrimSuffix(fields[0], ":")
+		counts, desc := parseCounts(fields[1:], len(cpus))
+		// Lines like 'ERR' and 'MIS' hold a single system-wide count
+		if len(counts) != len(cpus) || irq == "ERR" || irq == "MIS" {
+			continue
+		}
+		matched := m.matchGroups(irq, desc)
+		for i, c := range counts {
+			total[cpus[i]] += c
+			for _, g := range matched {
+				groups[g][cpus[i]] += c
+			}
+		}
+	}
+	values["irq_rate"] = total
+	for i := range m.groups {
+		values["irq_"+m.groups[i].name+"_rate"] = groups[i]
+	}
+	return nil
+}
+
+// readSoftirqs adds the counts of /proc/softirqs to the values by metric
+// name and CPU
+func (m *InterruptsCollector) readSoftirqs(values map[string]map[int]int64) error {
+	data, err := hostfs.ReadFile(SOFTIRQS_FILE)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	cpus, err := parseCPUHeader(lines[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse file '%s': %w", SOFTIRQS_FILE, err)
+	}
+	total := make(map[int]int64, len(cpus))
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], ":")
+		known := false
+		for _, t := range softirqTypes {
+			known = known || t.name == name
+		}
+		if !known {
+			continue
+		}
+		counts, _ := parseCounts(fields[1:], len(cpus))
+		if len(counts) != len(cpus) {
+			continue
+		}
+		v := make(map[int]int64, len(cpus))
+		for i, c := range counts {
+			v[cpus[i]] = c
+			total[cpus[i]] += c
+		}
+		values["softirq_"+strings.ToLower(name)+"_rate"] = v
+	}
+	values["softirq_rate"] = total
+	return nil
+}
+
+// sendRate sends the rate of a counter if a previous value exists
+func (m *InterruptsCollector) sendRate(
+	name string,
+	id int,
+	value int64,
+	tags map[string]string,
+	timeDiff float64,
+	now time.Time,
+	output chan lp.CCMessage,
+) {
+	prev, ok := m.previousValues[name]
+	if !ok {
+		prev = make(map[int]int64)
+		m.previousValues[name] = prev
+	}
+	if last, ok := prev[id]; ok && value >= last {
+		if y, err := lp.NewMetric(name, tags, m.meta, float64(value-last)/timeDiff, now); err == nil {
+			output <- y
+		}
+	}
+	prev[id] = value
+}
+
+func (m *InterruptsCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+
+	now := hostfs.Now()
+	timeDiff := now.Sub(m.lastTimestamp).Seconds()
+	m.lastTimestamp = now
+
+	values := make(map[string]map[int]int64)
+	if err := m.readInterrupts(values); err != nil {
+		cclog.ComponentError(m.name, fmt.Sprintf("Read(): %v", err))
+	}
+	if err := m.readSoftirqs(values); err != nil {
+		cclog.ComponentError(m.name, fmt.Sprintf("Read(): %v", err))
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if m.config.IsMetricExcluded(name) {
+			continue
+		}
+		var nodeValue int64
+		cpus := make([]int, 0, len(values[name]))
+		for cpu, value := range values[name] {
+			nodeValue += value
+			cpus = append(cpus, cpu)
+		}
+		if m.config.SendHwthreadValues {
+			slices.Sort(cpus)
+			for _, cpu := range cpus {
+				if tags, ok := m.hwthreadTags[cpu]; ok {
+					m.sendRate(name, cpu, values[name][cpu], tags, timeDiff, now, output)
+				}
+			}
+		}
+		if m.config.SendNodeTotals {
+			m.sendRate(name, -1, nodeValue, m.nodeTags, timeDiff, now, output)
+		}
+	}
+}
+
+// TopologyDependent marks the collector for re-initialization when hardware
+// threads go online or offline
+func (m *InterruptsCollector) TopologyDependent() bool {
+	return true
+}
+
+// Catalog returns the metrics the collector can send
+func (m *InterruptsCollector) Catalog() []mc.Metric {
+	types := []string{"hwthread", "node"}
+	unit := "interrupts/sec"
+	metrics := []mc.Metric{
+		{Name: "irq_rate", Unit: unit, Types: types, Kind: mc.Gauge, Description: "Hardware interrupts per second"},
+		{Name: "irq_*_rate", Unit: unit, Types: types, Kind: mc.Gauge, Description: "Hardware interrupts of the IRQ group per second", Options: []string{"irq_groups"}},
+		{Name: "softirq_rate", Unit: unit, Types: types, Kind: mc.Gauge, Description: "Software interrupts per second"},
+	}
+	for _, t := range softirqTypes {
+		metrics = append(metrics, mc.Metric{
+			Name: "softirq_" + strings.ToLower(t.name) + "_rate", Unit: unit, Types: types, Kind: mc.Gauge,
+			Description: "Software interrupts for " + t.desc + " per second",
+		})
+	}
+	return metrics
+}
+
+func (m *InterruptsCollector) Close() {
+	m.init = false
+}
@@ -12,21 +12,21 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const IOSTATFILE = `/proc/diskstats`
 
 type IOstatCollectorConfig struct {
-	ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
-	ExcludeDevices []string `json:"exclude_devices,omitempty"`
+	AddAffinityTags bool `json:"add_affinity_tags,omitempty"` // Add tags numa and socket of the disk controller
+	MetricFilter
 }
 
 type IOstatCollectorEntry struct {
@@ -36,7 +36,7 @@ type IOstatCollectorEntry struct {
 }
 
 type IOstatCollector struct {
-	metricCollector
+	BaseCollector
 
 	matches map[string]int
 	config  IOstatCollectorConfig
@@ -57,6 +57,9 @@ func (m *IOstatCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %s", m.name, err.Error())
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	// https://www.kernel.org/doc/html/latest/admin-guide/iostats.html
 	matches := map[string]int{
 		"io_reads":             3,
@@ -80,14 +83,14 @@ func (m *IOstatCollector) Init(config json.RawMessage) error {
 	m.devices = make(map[string]IOstatCollectorEntry)
 	m.matches = make(map[string]int)
 	for k, v := range matches {
-		if !slices.Contains(m.config.ExcludeMetrics, k) {
+		if !m.config.IsMetricExcluded(k) {
 			m.matches[k] = v
 		}
 	}
 	if len(m.matches) == 0 {
 		return fmt.Errorf("%s Init(): no metrics to collect", m.name)
 	}
-	file, err := os.Open(IOSTATFILE)
+	file, err := hostfs.Open(IOSTATFILE)
 	if err != nil {
 		return fmt.Errorf("%s Init(): Failed to open file \"%s\": %s", m.name, IOSTATFILE, err.Error())
 	}
@@ -104,7 +107,7 @@ func (m *IOstatCollector) Init(config json.RawMessage) error {
 		if strings.Contains(device, "loop") {
 			continue
 		}
-		if slices.Contains(m.config.ExcludeDevices, device) {
+		if m.config.IsDeviceExcluded(device) {
 			continue
 		}
 		currentValues := make(map[string]int64)
@@ -121,11 +124,15 @@ func (m *IOstatCollector) Init(config json.RawMessage) error {
 				}
 			}
 		}
+		tags := map[string]string{
+			"device": device,
+			"type":   "node",
+		}
+		if m.config.AddAffinityTags {
+			addAffinityTags(tags, classAffinityTags("block", device))
+		}
 		m.devices[device] = IOstatCollectorEntry{
-			tags: map[string]string{
-				"device": device,
-				"type":   "node",
-			},
+			tags:          tags,
 			currentValues: currentValues,
 			lastValues:    lastValues,
 		}
@@ -146,7 +153,7 @@ func (m *IOstatCollector) Read(interval time.Duration, output chan lp.CCMessage)
 		return
 	}
 
-	file, err := os.Open(IOSTATFILE)
+	file, err := hostfs.Open(IOSTATFILE)
 	if err != nil {
 		cclog.ComponentError(
 			m.name,
@@ -175,7 +182,7 @@ func (m *IOstatCollector) Read(interval time.Duration, output chan lp.CCMessage)
 		if strings.Contains(device, "loop") {
 			continue
 		}
-		if slices.Contains(m.config.ExcludeDevices, device) {
+		if m.config.IsDeviceExcluded(device) {
 			continue
 		}
 		if _, ok := m.devices[device]; !ok {
@@ -189,7 +196,7 @@ func (m *IOstatCollector) Read(interval time.Duration, output chan lp.CCMessage)
 				if err == nil {
 					// Calculate difference using previous current and new value
 					diff := x - entry.currentValues[name]
-					y, err := lp.NewMetric(name, entry.tags, m.meta, int(diff), time.Now())
+					y, err := lp.NewMetric(name, entry.tags, m.meta, int(diff), hostfs.Now())
 					if err == nil {
 						output <- y
 					}
@@ -208,6 +215,31 @@ func (m *IOstatCollector) Read(interval time.Duration, output chan lp.CCMessage)
 	}
 }
 
+// Catalog returns the metrics the collector can send. The values are the
+// differences since the last read.
+func (m *IOstatCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	return []mc.Metric{
+		{Name: "io_reads", Types: types, Kind: mc.Gauge, Description: "Completed reads"},
+		{Name: "io_reads_merged", Types: types, Kind: mc.Gauge, Description: "Merged reads"},
+		{Name: "io_read_sectors", Types: types, Kind: mc.Gauge, Description: "Read sectors"},
+		{Name: "io_read_ms", Types: types, Kind: mc.Gauge, Description: "Milliseconds spent reading"},
+		{Name: "io_writes", Types: types, Kind: mc.Gauge, Description: "Completed writes"},
+		{Name: "io_writes_merged", Types: types, Kind: mc.Gauge, Description: "Merged writes"},
+		{Name: "io_writes_sectors", Types: types, Kind: mc.Gauge, Description: "Written sectors"},
+		{Name: "io_writes_ms", Types: types, Kind: mc.Gauge, Description: "Milliseconds spent writing"},
+		{Name: "io_ioops", Types: types, Kind: mc.Gauge, Description: "I/Os currently in progress"},
+		{Name: "io_ioops_ms", Types: types, Kind: mc.Gauge, Description: "Milliseconds spent doing I/Os"},
+		{Name: "io_ioops_weighted_ms", Types: types, Kind: mc.Gauge, Description: "Weighted milliseconds spent doing I/Os"},
+		{Name: "io_discards", Types: types, Kind: mc.Gauge, Description: "Completed discards"},
+		{Name: "io_discards_merged", Types: types, Kind: mc.Gauge, Description: "Merged discards"},
+		{Name: "io_discards_sectors", Types: types, Kind: mc.Gauge, Description: "Discarded sectors"},
+		{Name: "io_discards_ms", Types: types, Kind: mc.Gauge, Description: "Milliseconds spent discarding"},
+		{Name: "io_flushes", Types: types, Kind: mc.Gauge, Description: "Completed flush requests"},
+		{Name: "io_flushes_ms", Types: types, Kind: mc.Gauge, Description: "Milliseconds spent flushing"},
+	}
+}
+
 func (m *IOstatCollector) Close() {
 	m.init = false
 }
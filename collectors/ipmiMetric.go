@@ -12,31 +12,31 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const IPMISENSORS_PATH = `ipmi-sensors`
 
 type IpmiCollector struct {
-	metricCollector
+	BaseCollector
 
 	config struct {
 		IpmitoolPath    string `json:"ipmitool_path"`
 		IpmisensorsPath string `json:"ipmisensors_path"`
 		Sudo            bool   `json:"use_sudo"`
-		IncludeMetrics  []string `json:"include_metrics"`
+		MetricFilter
 	}
 
 	ipmitool    string
 	ipmisensors string
-	includeMetrics map[string]bool
 }
 
 func (m *IpmiCollector) Init(config json.RawMessage) error {
@@ -66,13 +66,17 @@ func (m *IpmiCollector) Init(config json.RawMessage) error {
 		}
 	}
 
-	// Read metrics to include
-	m.includeMetrics = make(map[string]bool)
-	for _, metric := range m.config.IncludeMetrics {
-		metric = strings.ToLower(strings.TrimSpace(metric))
-		if metric != "" {
-			m.includeMetrics[metric] = true
+	// Metric names are lower case, so are the exact names and glob patterns
+	for _, metrics := range []*[]string{&m.config.IncludeMetrics, &m.config.ExcludeMetrics} {
+		for i, metric := range *metrics {
+			if !strings.HasPrefix(metric, "/") {
+				(*metrics)[i] = strings.ToLower(strings.TrimSpace(metric))
+			}
 		}
+		*metrics = slices.DeleteFunc(*metrics, func(metric string) bool { return metric == "" })
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
 	}
 
 	m.ipmitool = m.config.IpmitoolPath
@@ -87,7 +91,7 @@ func (m *IpmiCollector) Init(config json.RawMessage) error {
 
 	// Test if ipmi-sensors works (preferred over ipmitool, because it's faster)
 	var ipmiSensorsErr error
-	if _, ipmiSensorsErr = exec.LookPath(m.ipmisensors); ipmiSensorsErr == nil {
+	if _, ipmiSensorsErr = hostfs.LookPath(m.ipmisensors); ipmiSensorsErr == nil {
 		dummyChan = make(chan lp.CCMessage)
 		go dummyConsumer()
 		ipmiSensorsErr = m.readIpmiSensors(dummyChan)
@@ -103,7 +107,7 @@ func (m *IpmiCollector) Init(config json.RawMessage) error {
 
 	// Test if ipmitool works (may be very slow)
 	var ipmiToolErr error
-	if _, ipmiToolErr = exec.LookPath(m.ipmitool); ipmiToolErr == nil {
+	if _, ipmiToolErr = hostfs.LookPath(m.ipmitool); ipmiToolErr == nil {
 		dummyChan = make(chan lp.CCMessage)
 		go dummyConsumer()
 		ipmiToolErr = m.readIpmiTool(dummyChan)
@@ -127,18 +131,16 @@ func (m *IpmiCollector) readIpmiTool(output chan lp.CCMessage) error {
 		argv = append(argv, "sudo", "-n")
 	}
 	argv = append(argv, m.ipmitool, "sensor")
-	command := exec.Command(argv[0], argv[1:]...)
-	stdout, _ := command.StdoutPipe()
-	errBuf := new(bytes.Buffer)
-	command.Stderr = errBuf
-
-	// start command
-	if err := command.Start(); err != nil {
-		return fmt.Errorf("failed to start command '%s': %w", command.String(), err)
+	command := hostfs.Command(argv[0], argv[1:]...)
+
+	// Run command
+	stdout, stderr, err := command.Run()
+	if err != nil {
+		return fmt.Errorf("failed to run command '%s': %w (stderr: %s)", command.String(), err, strings.TrimSpace(string(stderr)))
 	}
 
 	// Read command output
-	scanner := bufio.NewScanner(stdout)
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
 	for scanner.Scan() {
 		lv := strings.Split(scanner.Text(), "|")
 		if len(lv) < 3 {
@@ -157,7 +159,7 @@ func (m *IpmiCollector) readIpmiTool(output chan lp.CCMessage) error {
 		}
 		name := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lv[0]), " ", "_"))
 
-		if len(m.includeMetrics) > 0 && !m.includeMetrics[name] {
+		if m.config.IsMetricExcluded(name) {
  			continue
 		}
 
@@ -173,7 +175,7 @@ func (m *IpmiCollector) readIpmiTool(output chan lp.CCMessage) error {
 			unit = "Watts"
 		}
 
-		y, err := lp.NewMetric(name, map[string]string{"type": "node"}, m.meta, v, time.Now())
+		y, err := lp.NewMetric(name, map[string]string{"type": "node"}, m.meta, v, hostfs.Now())
 		if err != nil {
 			cclog.ComponentErrorf(m.name, "Failed to create message: %v", err)
 			continue
@@ -185,12 +187,6 @@ func (m *IpmiCollector) readIpmiTool(output chan lp.CCMessage) error {
 		return fmt.Errorf("failed to scan output of command: %s", err.Error())
 	}
 
-	// Wait for command end
-	if err := command.Wait(); err != nil {
-		errMsg, _ := io.ReadAll(errBuf)
-		return fmt.Errorf("failed to complete command '%s': %w (stderr: %s)", command.String(), err, strings.TrimSpace(string(errMsg)))
-	}
-
 	return nil
 }
 
@@ -201,18 +197,16 @@ func (m *IpmiCollector) readIpmiSensors(output chan lp.CCMessage) error {
 		argv = append(argv, "sudo", "-n")
 	}
 	argv = append(argv, m.ipmisensors, "--comma-separated-output", "--sdr-cache-recreate")
-	command := exec.Command(argv[0], argv[1:]...)
-	stdout, _ := command.StdoutPipe()
-	errBuf := new(bytes.Buffer)
-	command.Stderr = errBuf
-
-	// start command
-	if err := command.Start(); err != nil {
-		return fmt.Errorf("failed to start command '%s': %w", command.String(), err)
+	command := hostfs.Command(argv[0], argv[1:]...)
+
+	// Run command
+	stdout, stderr, err := command.Run()
+	if err != nil {
+		return fmt.Errorf("failed to run command '%s': %w (stderr: %s)", command.String(), err, strings.TrimSpace(string(stderr)))
 	}
 
 	// Read command output
-	scanner := bufio.NewScanner(stdout)
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
 	for scanner.Scan() {
 		lv := strings.Split(scanner.Text(), ",")
 		if len(lv) <= 3 {
@@ -229,11 +223,11 @@ func (m *IpmiCollector) readIpmiSensors(output chan lp.CCMessage) error {
 		}
 		name := strings.ToLower(strings.ReplaceAll(lv[1], " ", "_"))
 
-		if len(m.includeMetrics) > 0 && !m.includeMetrics[name] {
+		if m.config.IsMetricExcluded(name) {
 			continue
 		}
 
-		y, err := lp.NewMetric(name, map[string]string{"type": "node"}, m.meta, v, time.Now())
+		y, err := lp.NewMetric(name, map[string]string{"type": "node"}, m.meta, v, hostfs.Now())
 		if err != nil {
 			cclog.ComponentErrorf(m.name, "Failed to create message: %v", err)
 			continue
@@ -244,12 +238,6 @@ func (m *IpmiCollector) readIpmiSensors(output chan lp.CCMessage) error {
 		output <- y
 	}
 
-	// Wait for command end
-	if err := command.Wait(); err != nil {
-		errMsg, _ := io.ReadAll(errBuf)
-		return fmt.Errorf("failed to complete command '%s': %w (stderr: %s)", command.String(), err, strings.TrimSpace(string(errMsg)))
-	}
-
 	return nil
 }
 
@@ -272,6 +260,14 @@ func (m *IpmiCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	}
 }
 
+// Catalog returns the metrics the collector can send. The metric names are
+// derived from the sensor names, e.g. 'cpu1_temp'.
+func (m *IpmiCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "*", Unit: mc.AnyUnit, Types: []string{"node"}, Kind: mc.Gauge, Description: "Value of an IPMI sensor with the unit reported by the sensor"},
+	}
+}
+
 func (m *IpmiCollector) Close() {
 	m.init = false
 }
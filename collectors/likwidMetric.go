@@ -36,6 +36,8 @@ import (
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
 	agg "github.com/ClusterCockpit/cc-metric-collector/internal/metricAggregator"
 	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	"github.com/NVIDIA/go-nvml/pkg/dl"
 	"github.com/fsnotify/fsnotify"
 	"golang.design/x/thread"
@@ -83,10 +85,11 @@ type LikwidCollectorConfig struct {
 	DaemonPath     string                          `json:"accessdaemon_path,omitempty"`
 	LibraryPath    string                          `json:"liblikwid_path,omitempty"`
 	LockfilePath   string                          `json:"lockfile_path,omitempty"`
+	MetricFilter
 }
 
 type LikwidCollector struct {
-	metricCollector
+	BaseCollector
 	cpulist       []C.int
 	cpu2tid       map[int]int
 	sock2tid      map[int]int
@@ -178,7 +181,7 @@ func getBaseFreq() float64 {
 	}
 	var freq float64 = math.NaN()
 	for _, f := range files {
-		buffer, err := os.ReadFile(f)
+		buffer, err := hostfs.ReadFile(f)
 		if err == nil {
 			data := strings.ReplaceAll(string(buffer), "\n", "")
 			x, err := strconv.ParseInt(data, 0, 64)
@@ -213,6 +216,9 @@ func (m *LikwidCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	lib := dl.New(m.config.LibraryPath, LIKWID_LIB_DL_FLAGS)
 	if lib == nil {
 		return fmt.Errorf("%s Init(): error instantiating DynamicLibrary for %s", m.name, m.config.LibraryPath)
@@ -573,6 +579,12 @@ func (m *LikwidCollector) takeMeasurement(evidx int, evset LikwidEventsetConfig,
 	return false, nil
 }
 
+// publish returns whether a metric is sent to the router. Metrics which are
+// not published are still computed for the global metrics.
+func (m *LikwidCollector) publish(metric LikwidCollectorMetricConfig) bool {
+	return metric.Publish && !m.config.IsMetricExcluded(metric.Name)
+}
+
 // Get all measurement results for an event set, derive the metric values out of the measurement results and send it
 func (m *LikwidCollector) calcEventsetMetrics(evset LikwidEventsetConfig, interval time.Duration, output chan lp.CCMessage) error {
 	invClock := float64(1.0 / m.basefreq)
@@ -584,6 +596,7 @@ func (m *LikwidCollector) calcEventsetMetrics(evset LikwidEventsetConfig, interv
 
 	// Go over the event set metrics, derive the value out of the event:counter values and send it
 	for _, metric := range m.config.Eventsets[evset.internal].Metrics {
+		publish := m.publish(metric)
 		// The metric scope is determined in the Init() function
 		// Get the map scope-id -> tids
 		scopemap := m.cpu2tid
@@ -605,7 +618,7 @@ func (m *LikwidCollector) calcEventsetMetrics(evset LikwidEventsetConfig, interv
 				}
 				evset.metrics[tid][metric.Name] = value
 				// Now we have the result, send it with the proper tags
-				if !math.IsNaN(value) && metric.Publish {
+				if !math.IsNaN(value) && publish {
 					y, err := lp.NewMessage(
 						metric.Name,
 						map[string]string{
@@ -631,13 +644,13 @@ func (m *LikwidCollector) calcEventsetMetrics(evset LikwidEventsetConfig, interv
 		}
 
 		// Send per core aggregated values
-		if metric.SendCoreTotalVal {
+		if publish && metric.SendCoreTotalVal {
 			totalCoreValues := make(map[int]float64)
 			for _, tid := range scopemap {
 				if tid >= 0 && len(metric.Calc) > 0 {
 					coreID := m.tid2core[tid]
 					value := evset.metrics[tid][metric.Name]
-					if !math.IsNaN(value) && metric.Publish {
+					if !math.IsNaN(value) {
 						totalCoreValues[coreID] += value
 					}
 				}
@@ -667,13 +680,13 @@ func (m *LikwidCollector) calcEventsetMetrics(evset LikwidEventsetConfig, interv
 		}
 
 		// Send per socket aggregated values
-		if metric.SendSocketTotalVal {
+		if publish && metric.SendSocketTotalVal {
 			totalSocketValues := make(map[int]float64)
 			for _, tid := range scopemap {
 				if tid >= 0 && len(metric.Calc) > 0 {
 					socketID := m.tid2socket[tid]
 					value := evset.metrics[tid][metric.Name]
-					if !math.IsNaN(value) && metric.Publish {
+					if !math.IsNaN(value) {
 						totalSocketValues[socketID] += value
 					}
 				}
@@ -703,12 +716,12 @@ func (m *LikwidCollector) calcEventsetMetrics(evset LikwidEventsetConfig, interv
 		}
 
 		// Send per node aggregated value
-		if metric.SendNodeTotalVal {
+		if publish && metric.SendNodeTotalVal {
 			var totalNodeValue float64 = 0.0
 			for _, tid := range scopemap {
 				if tid >= 0 && len(metric.Calc) > 0 {
 					value := evset.metrics[tid][metric.Name]
-					if !math.IsNaN(value) && metric.Publish {
+					if !math.IsNaN(value) {
 						totalNodeValue += value
 					}
 				}
@@ -770,7 +783,7 @@ func (m *LikwidCollector) calcGlobalMetrics(groups []LikwidEventsetConfig, inter
 				}
 				// Now we have the result, send it with the proper tags
 				if !math.IsNaN(value) {
-					if metric.Publish {
+					if m.publish(metric) {
 						y, err := lp.NewMessage(
 							metric.Name,
 							map[string]string{
@@ -846,6 +859,43 @@ func (m *LikwidCollector) Read(interval time.Duration, output chan lp.CCMessage)
 	})
 }
 
+// Catalog returns the metrics the collector can send. The metrics are defined
+// in the configuration, so the catalog is empty before Init().
+func (m *LikwidCollector) Catalog() []mc.Metric {
+	metrics := make([]mc.Metric, 0)
+	add := func(metric LikwidCollectorMetricConfig) {
+		if !metric.Publish {
+			return
+		}
+		types := []string{metric.Type}
+		if metric.SendCoreTotalVal {
+			types = append(types, "core")
+		}
+		if metric.SendSocketTotalVal {
+			types = append(types, "socket")
+		}
+		if metric.SendNodeTotalVal {
+			types = append(types, "node")
+		}
+		metrics = append(metrics, mc.Metric{
+			Name:        metric.Name,
+			Unit:        metric.Unit,
+			Types:       types,
+			Kind:        mc.Gauge,
+			Description: metric.Calc,
+		})
+	}
+	for _, evset := range m.config.Eventsets {
+		for _, metric := range evset.Metrics {
+			add(metric)
+		}
+	}
+	for _, metric := range m.config.Metrics {
+		add(metric)
+	}
+	return metrics
+}
+
 func (m *LikwidCollector) Close() {
 	if m.init {
 		m.init = false
@@ -11,14 +11,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // LoadavgCollector collects:
@@ -30,7 +30,7 @@ import (
 const LOADAVGFILE = "/proc/loadavg"
 
 type LoadavgCollector struct {
-	metricCollector
+	BaseCollector
 
 	tags         map[string]string
 	load_matches []string
@@ -38,7 +38,7 @@ type LoadavgCollector struct {
 	proc_matches []string
 	proc_skips   []bool
 	config       struct {
-		ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
+		MetricFilter
 	}
 }
 
@@ -55,6 +55,9 @@ func (m *LoadavgCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.meta = map[string]string{
 		"source": m.name,
 		"group":  "LOAD",
@@ -72,12 +75,12 @@ func (m *LoadavgCollector) Init(config json.RawMessage) error {
 
 	m.load_skips = make([]bool, len(m.load_matches))
 	for i, name := range m.load_matches {
-		m.load_skips[i] = slices.Contains(m.config.ExcludeMetrics, name)
+		m.load_skips[i] = m.config.IsMetricExcluded(name)
 	}
 
 	m.proc_skips = make([]bool, len(m.proc_matches))
 	for i, name := range m.proc_matches {
-		m.proc_skips[i] = slices.Contains(m.config.ExcludeMetrics, name)
+		m.proc_skips[i] = m.config.IsMetricExcluded(name)
 	}
 	m.init = true
 	return nil
@@ -87,14 +90,14 @@ func (m *LoadavgCollector) Read(interval time.Duration, output chan lp.CCMessage
 	if !m.init {
 		return
 	}
-	buffer, err := os.ReadFile(LOADAVGFILE)
+	buffer, err := hostfs.ReadFile(LOADAVGFILE)
 	if err != nil {
 		cclog.ComponentError(
 			m.name,
 			fmt.Sprintf("Read(): Failed to read file '%s': %v", LOADAVGFILE, err))
 		return
 	}
-	now := time.Now()
+	now := hostfs.Now()
 
 	// Load metrics
 	ls := strings.Split(string(buffer), ` `)
@@ -136,6 +139,18 @@ func (m *LoadavgCollector) Read(interval time.Duration, output chan lp.CCMessage
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *LoadavgCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	return []mc.Metric{
+		{Name: "load_one", Types: types, Kind: mc.Gauge, Description: "Load average over the last minute"},
+		{Name: "load_five", Types: types, Kind: mc.Gauge, Description: "Load average over the last five minutes"},
+		{Name: "load_fifteen", Types: types, Kind: mc.Gauge, Description: "Load average over the last fifteen minutes"},
+		{Name: "proc_run", Types: types, Kind: mc.Gauge, Description: "Number of runnable scheduling entities"},
+		{Name: "proc_total", Types: types, Kind: mc.Gauge, Description: "Number of existing scheduling entities"},
+	}
+}
+
 func (m *LoadavgCollector) Close() {
 	m.init = false
 }
@@ -12,7 +12,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
 	"os/user"
 	"slices"
 	"strconv"
@@ -20,6 +19,8 @@ import (
 	"time"
 
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const (
@@ -29,12 +30,12 @@ const (
 )
 
 type LustreCollectorConfig struct {
-	LCtlCommand        string   `json:"lctl_command,omitempty"`
-	ExcludeMetrics     []string `json:"exclude_metrics,omitempty"`
-	Sudo               bool     `json:"use_sudo,omitempty"`
-	SendAbsoluteValues bool     `json:"send_abs_values,omitempty"`
-	SendDerivedValues  bool     `json:"send_derived_values,omitempty"`
-	SendDiffValues     bool     `json:"send_diff_values,omitempty"`
+	LCtlCommand        string `json:"lctl_command,omitempty"`
+	Sudo               bool   `json:"use_sudo,omitempty"`
+	SendAbsoluteValues bool   `json:"send_abs_values,omitempty"`
+	SendDerivedValues  bool   `json:"send_derived_values,omitempty"`
+	SendDiffValues     bool   `json:"send_diff_values,omitempty"`
+	MetricFilter
 }
 
 type LustreMetricDefinition struct {
@@ -46,7 +47,7 @@ type LustreMetricDefinition struct {
 }
 
 type LustreCollector struct {
-	metricCollector
+	BaseCollector
 
 	tags          map[string]string
 	config        LustreCollectorConfig
@@ -58,12 +59,12 @@ type LustreCollector struct {
 }
 
 func (m *LustreCollector) getDeviceDataCommand(device string) []string {
-	var command *exec.Cmd
+	var command *hostfs.Cmd
 	statsfile := fmt.Sprintf("llite.%s.stats", device)
 	if m.config.Sudo {
-		command = exec.Command(m.sudoCmd, m.lctl, LCTL_OPTION, statsfile)
+		command = hostfs.Command(m.sudoCmd, m.lctl, LCTL_OPTION, statsfile)
 	} else {
-		command = exec.Command(m.lctl, LCTL_OPTION, statsfile)
+		command = hostfs.Command(m.lctl, LCTL_OPTION, statsfile)
 	}
 	stdout, _ := command.Output()
 	return strings.Split(string(stdout), "\n")
@@ -306,6 +307,9 @@ func (m *LustreCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	if err := m.setup(); err != nil {
 		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
 	}
@@ -323,16 +327,16 @@ func (m *LustreCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Lustre file system statistics can only be queried by user root", m.name)
 		}
 	} else {
-		p, err := exec.LookPath("sudo")
+		p, err := hostfs.LookPath("sudo")
 		if err != nil {
 			return fmt.Errorf("%s Init(): Cannot find 'sudo': %w", m.name, err)
 		}
 		m.sudoCmd = p
 	}
 
-	p, err := exec.LookPath(m.config.LCtlCommand)
+	p, err := hostfs.LookPath(m.config.LCtlCommand)
 	if err != nil {
-		p, err = exec.LookPath(LCTL_CMD)
+		p, err = hostfs.LookPath(LCTL_CMD)
 		if err != nil {
 			return fmt.Errorf("%s Init(): Cannot find %s command: %w", m.name, LCTL_CMD, err)
 		}
@@ -342,21 +346,21 @@ func (m *LustreCollector) Init(config json.RawMessage) error {
 	m.definitions = []LustreMetricDefinition{}
 	if m.config.SendAbsoluteValues {
 		for _, def := range LustreAbsMetrics {
-			if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+			if !m.config.IsMetricExcluded(def.name) {
 				m.definitions = append(m.definitions, def)
 			}
 		}
 	}
 	if m.config.SendDiffValues {
 		for _, def := range LustreDiffMetrics {
-			if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+			if !m.config.IsMetricExcluded(def.name) {
 				m.definitions = append(m.definitions, def)
 			}
 		}
 	}
 	if m.config.SendDerivedValues {
 		for _, def := range LustreDeriveMetrics {
-			if !slices.Contains(m.config.ExcludeMetrics, def.name) {
+			if !m.config.IsMetricExcluded(def.name) {
 				m.definitions = append(m.definitions, def)
 			}
 		}
@@ -365,7 +369,9 @@ func (m *LustreCollector) Init(config json.RawMessage) error {
 		return fmt.Errorf("%s Init(): no metrics to collect", m.name)
 	}
 
-	devices := m.getDevices()
+	devices := slices.DeleteFunc(m.getDevices(), func(d string) bool {
+		return m.config.IsDeviceExcluded(d)
+	})
 	if len(devices) == 0 {
 		return fmt.Errorf("%s Init(): no Lustre devices found", m.name)
 	}
@@ -382,7 +388,7 @@ func (m *LustreCollector) Init(config json.RawMessage) error {
 			}
 		}
 	}
-	m.lastTimestamp = time.Now()
+	m.lastTimestamp = hostfs.Now()
 	m.init = true
 	return nil
 }
@@ -391,7 +397,7 @@ func (m *LustreCollector) Read(interval time.Duration, output chan lp.CCMessage)
 	if !m.init {
 		return
 	}
-	now := time.Now()
+	now := hostfs.Now()
 	tdiff := now.Sub(m.lastTimestamp)
 	for device, devData := range m.stats {
 		data := m.getDeviceDataCommand(device)
@@ -409,19 +415,19 @@ func (m *LustreCollector) Read(interval time.Duration, output chan lp.CCMessage)
 			switch def.calc {
 			case "none":
 				value = use_x
-				y, err = lp.NewMessage(def.name, m.tags, m.meta, map[string]any{"value": value}, time.Now())
+				y, err = lp.NewMessage(def.name, m.tags, m.meta, map[string]any{"value": value}, hostfs.Now())
 			case "difference":
 				value = use_x - devData[def.name]
 				if value.(int64) < 0 {
 					value = 0
 				}
-				y, err = lp.NewMessage(def.name, m.tags, m.meta, map[string]any{"value": value}, time.Now())
+				y, err = lp.NewMessage(def.name, m.tags, m.meta, map[string]any{"value": value}, hostfs.Now())
 			case "derivative":
 				value = float64(use_x-devData[def.name]) / tdiff.Seconds()
 				if value.(float64) < 0 {
 					value = 0
 				}
-				y, err = lp.NewMessage(def.name, m.tags, m.meta, map[string]any{"value": value}, time.Now())
+				y, err = lp.NewMessage(def.name, m.tags, m.meta, map[string]any{"value": value}, hostfs.Now())
 			}
 			if err == nil {
 				y.AddTag("device", device)
@@ -436,6 +442,27 @@ func (m *LustreCollector) Read(interval time.Duration, output chan lp.CCMessage)
 	m.lastTimestamp = now
 }
 
+// Catalog returns the metrics the collector can send
+func (m *LustreCollector) Catalog() []mc.Metric {
+	metrics := make([]mc.Metric, 0)
+	add := func(defs []LustreMetricDefinition, kind mc.Kind, description string, option string) {
+		for _, def := range defs {
+			metrics = append(metrics, mc.Metric{
+				Name:        def.name,
+				Unit:        def.unit,
+				Types:       []string{"node"},
+				Kind:        kind,
+				Description: fmt.Sprintf(description, def.lineprefix),
+				Options:     []string{option},
+			})
+		}
+	}
+	add(LustreAbsMetrics, mc.Counter, "Lustre client statistics '%s'", "send_abs_values")
+	add(LustreDiffMetrics, mc.Gauge, "Lustre client statistics '%s' since the last read", "send_diff_values")
+	add(LustreDeriveMetrics, mc.Gauge, "Lustre client statistics '%s' per second", "send_derived_values")
+	return metrics
+}
+
 func (m *LustreCollector) Close() {
 	m.init = false
 }
@@ -12,16 +12,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const (
@@ -30,9 +30,9 @@ const (
 )
 
 type MemstatCollectorConfig struct {
-	ExcludeMetrics []string `json:"exclude_metrics"`
-	NodeStats      bool     `json:"node_stats,omitempty"`
-	NumaStats      bool     `json:"numa_stats,omitempty"`
+	MetricFilter
+	NodeStats bool `json:"node_stats,omitempty"`
+	NumaStats bool `json:"numa_stats,omitempty"`
 }
 
 type MemstatCollectorNode struct {
@@ -41,7 +41,7 @@ type MemstatCollectorNode struct {
 }
 
 type MemstatCollector struct {
-	metricCollector
+	BaseCollector
 
 	stats       map[string]int64
 	tags        map[string]string
@@ -58,7 +58,7 @@ type MemstatStats struct {
 
 func getStats(filename string) map[string]MemstatStats {
 	stats := make(map[string]MemstatStats)
-	file, err := os.Open(filename)
+	file, err := hostfs.Open(filename)
 	if err != nil {
 		cclog.Error(err.Error())
 	}
@@ -107,6 +107,9 @@ func (m *MemstatCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.meta = map[string]string{
 		"source": m.name,
 		"group":  "Memory",
@@ -149,12 +152,12 @@ func (m *MemstatCollector) Init(config json.RawMessage) error {
 		"KernelStack":     "mem_kernelstack",
 	}
 	for k, v := range matches {
-		if !slices.Contains(m.config.ExcludeMetrics, v) {
+		if !m.config.IsMetricExcluded(v) {
 			m.matches[k] = v
 		}
 	}
 	m.sendMemUsed = false
-	if !slices.Contains(m.config.ExcludeMetrics, "mem_used") {
+	if !m.config.IsMetricExcluded("mem_used") {
 		m.sendMemUsed = true
 	}
 	if len(m.matches) == 0 && !m.sendMemUsed {
@@ -173,7 +176,7 @@ func (m *MemstatCollector) Init(config json.RawMessage) error {
 	if m.config.NumaStats {
 		globPattern := filepath.Join(NUMA_MEMSTAT_BASE, "node[0-9]*", "meminfo")
 		regex := regexp.MustCompile(filepath.Join(NUMA_MEMSTAT_BASE, "node(\\d+)", "meminfo"))
-		files, err := filepath.Glob(globPattern)
+		files, err := hostfs.Glob(globPattern)
 		if err == nil {
 			m.nodefiles = make(map[int]MemstatCollectorNode)
 			for _, f := range files {
@@ -217,7 +220,7 @@ func (m *MemstatCollector) Read(interval time.Duration, output chan lp.CCMessage
 				}
 			}
 
-			y, err := lp.NewMetric(name, tags, m.meta, value, time.Now())
+			y, err := lp.NewMetric(name, tags, m.meta, value, hostfs.Now())
 			if err == nil {
 				if len(unit) > 0 {
 					y.AddMeta("unit", unit)
@@ -256,7 +259,7 @@ func (m *MemstatCollector) Read(interval time.Duration, output chan lp.CCMessage
 					}
 				}
 			}
-			y, err := lp.NewMetric("mem_used", tags, m.meta, memUsed, time.Now())
+			y, err := lp.NewMetric("mem_used", tags, m.meta, memUsed, hostfs.Now())
 			if err == nil {
 				if len(unit) > 0 {
 					y.AddMeta("unit", unit)
@@ -279,6 +282,47 @@ func (m *MemstatCollector) Read(interval time.Duration, output chan lp.CCMessage
 	}
 }
 
+// Catalog returns the metrics the collector can send. With numa_stats, the
+// metrics are also sent for each NUMA domain.
+func (m *MemstatCollector) Catalog() []mc.Metric {
+	types := []string{"node", "memoryDomain"}
+	return []mc.Metric{
+		{Name: "mem_total", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Total usable memory"},
+		{Name: "mem_free", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Unused memory"},
+		{Name: "mem_available", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory available for starting new applications without swapping"},
+		{Name: "mem_used", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Used memory: mem_total - (mem_free + mem_buffers + mem_cached + mem_shared)"},
+		{Name: "mem_buffers", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used for block device buffers"},
+		{Name: "mem_cached", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used for the page cache"},
+		{Name: "mem_shared", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used by shared memory and tmpfs"},
+		{Name: "mem_sreclaimable", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Reclaimable slab memory"},
+		{Name: "mem_slab", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used by the kernel slab allocator"},
+		{Name: "mem_active", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Recently used memory"},
+		{Name: "mem_inactive", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Less recently used memory"},
+		{Name: "mem_dirty", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory waiting to be written back to disk"},
+		{Name: "mem_writeback", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory actively being written back to disk"},
+		{Name: "mem_anon_pages", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used by anonymous pages"},
+		{Name: "mem_mapped", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used by mapped files"},
+		{Name: "mem_vmalloc_total", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Total size of the vmalloc address space"},
+		{Name: "mem_anon_hugepages", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used by anonymous transparent huge pages"},
+		{Name: "mem_shared_hugepages", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Shared memory allocated with huge pages"},
+		{Name: "mem_shared_pmd_mapped", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Shared memory mapped into user space with huge pages"},
+		{Name: "mem_hugepages_total", Types: types, Kind: mc.Gauge, Description: "Number of huge pages in the pool"},
+		{Name: "mem_hugepages_free", Types: types, Kind: mc.Gauge, Description: "Number of unallocated huge pages"},
+		{Name: "mem_hugepages_reserved", Types: types, Kind: mc.Gauge, Description: "Number of reserved huge pages"},
+		{Name: "mem_hugepages_surplus", Types: types, Kind: mc.Gauge, Description: "Number of surplus huge pages"},
+		{Name: "mem_hugepages_size", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Default size of huge pages"},
+		{Name: "mem_direct_mapped_4k", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory directly mapped with 4k pages"},
+		{Name: "mem_direct_mapped_2m", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory directly mapped with 2M pages"},
+		{Name: "mem_direct_mapped_4m", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory directly mapped with 4M pages"},
+		{Name: "mem_direct_mapped_1g", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory directly mapped with 1G pages"},
+		{Name: "mem_locked", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory locked with mlock()"},
+		{Name: "mem_pagetables", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used by page tables"},
+		{Name: "mem_kernelstack", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Memory used by kernel stacks"},
+		{Name: "swap_total", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Total swap space"},
+		{Name: "swap_free", Unit: "kB", Types: types, Kind: mc.Gauge, Description: "Unused swap space"},
+	}
+}
+
 func (m *MemstatCollector) Close() {
 	m.init = false
 }
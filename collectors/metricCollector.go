@@ -8,11 +8,16 @@
 package collectors
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"strconv"
 	"time"
 
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 type MetricCollector interface {
@@ -22,9 +27,21 @@ type MetricCollector interface {
 	Parallel() bool
 	Read(duration time.Duration, output chan lp.CCMessage) // Read metrics from metric collector
 	Close()                                                // Close / finish metric collector
+	Catalog() []mc.Metric                                  // Metrics the metric collector can send
 }
 
-type metricCollector struct {
+// TopologyDependent is implemented by metric collectors holding per CPU state
+// like tags or last values. When hardware threads go online or offline, the
+// collector manager re-initializes these collectors with Close() and Init()
+// before the next Read().
+type TopologyDependent interface {
+	TopologyDependent() bool
+}
+
+// BaseCollector is embedded in all metric collectors. It implements Name(),
+// Parallel() and Initialized() of the MetricCollector interface. Collectors
+// outside of this package set it up with InitBase() and SetInitialized().
+type BaseCollector struct {
 	name     string            // name of the metric
 	init     bool              // is metric collector initialized?
 	parallel bool              // can the metric collector be executed in parallel with others
@@ -32,25 +49,100 @@ type metricCollector struct {
 }
 
 // Name returns the name of the metric collector
-func (c *metricCollector) Name() string {
+func (c *BaseCollector) Name() string {
 	return c.name
 }
 
 // Name returns the name of the metric collector
-func (c *metricCollector) Parallel() bool {
+func (c *BaseCollector) Parallel() bool {
 	return c.parallel
 }
 
 // Setup is for future use
-func (c *metricCollector) setup() error {
+func (c *BaseCollector) setup() error {
 	return nil
 }
 
 // Initialized indicates whether the metric collector has been initialized
-func (c *metricCollector) Initialized() bool {
+func (c *BaseCollector) Initialized() bool {
 	return c.init
 }
 
+// InitBase sets the name of the metric collector, whether it can be read in
+// parallel with other collectors and the meta information of its messages
+func (c *BaseCollector) InitBase(name string, parallel bool, meta map[string]string) {
+	c.name = name
+	c.parallel = parallel
+	c.meta = maps.Clone(meta)
+}
+
+// SetInitialized marks the metric collector as (un)initialized
+func (c *BaseCollector) SetInitialized(init bool) {
+	c.init = init
+}
+
+// Meta returns the meta information of the messages of the metric collector
+func (c *BaseCollector) Meta() map[string]string {
+	return c.meta
+}
+
+// DecodeConfig decodes the JSON configuration of the metric collector into v.
+// Unknown fields are rejected. An empty configuration leaves v unchanged.
+func (c *BaseCollector) DecodeConfig(config json.RawMessage, v any) error {
+	if len(config) == 0 {
+		return nil
+	}
+	d := json.NewDecoder(bytes.NewReader(config))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return fmt.Errorf("%s Init(): Error decoding JSON config: %w", c.name, err)
+	}
+	return nil
+}
+
+// NewMetric creates a metric with the meta information of the metric collector
+func (c *BaseCollector) NewMetric(name string, tags map[string]string, value any, t time.Time) (lp.CCMessage, error) {
+	return lp.NewMetric(name, tags, c.meta, value, t)
+}
+
+// TypeTags returns the tags 'type' and 'type-id' of a metric, e.g. for the
+// type 'socket' and the socket ID. The type 'node' has no type ID.
+func TypeTags(typeName string, typeID string) map[string]string {
+	if typeName == "node" || len(typeID) == 0 {
+		return map[string]string{"type": typeName}
+	}
+	return map[string]string{"type": typeName, "type-id": typeID}
+}
+
+// pciAffinityTags returns the tags 'numa' and 'socket' for a PCI device like
+// a GPU. A tag is only set if the device is local to a single NUMA domain or
+// socket.
+func pciAffinityTags(pciAddress string) map[string]string {
+	tags := make(map[string]string)
+	if numaDomain := topo.GetPciDeviceNumaDomain(pciAddress); numaDomain >= 0 {
+		tags["numa"] = strconv.Itoa(numaDomain)
+	}
+	if socket := topo.GetPciDeviceSocket(pciAddress); socket >= 0 {
+		tags["socket"] = strconv.Itoa(socket)
+	}
+	return tags
+}
+
+// classAffinityTags returns the tags 'numa' and 'socket' for a device in a
+// sysfs class like the block device nvme0n1 or the InfiniBand HCA mlx5_0.
+// Virtual devices get no tags.
+func classAffinityTags(class string, name string) map[string]string {
+	if pciAddress, ok := topo.GetClassDevicePciAddress(class, name); ok {
+		return pciAffinityTags(pciAddress)
+	}
+	return map[string]string{}
+}
+
+// addAffinityTags adds the affinity tags to the tags of a metric
+func addAffinityTags(tags map[string]string, affinityTags map[string]string) {
+	maps.Copy(tags, affinityTags)
+}
+
 // RemoveFromStringList removes the string r from the array of strings s
 // If r is not contained in the array an error is returned
 func RemoveFromStringList(s []string, r string) ([]string, error) {
@@ -0,0 +1,179 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MetricFilter selects the metrics and devices a collector sends. It is
+// embedded in the configuration of the collectors:
+//
+//	"include_metrics": ["load_*"],
+//	"exclude_metrics": ["/^proc_(run|total)$/"],
+//	"include_devices": ["mlx5_*"],
+//	"exclude_devices": ["sda", "/^nvme[0-9]+n2$/"]
+//
+// Patterns are exact names, glob patterns or regular expressions enclosed in
+// slashes. In glob patterns, '*' matches any sequence of characters, '?' any
+// single character and '[...]' a character class like '[0-9]' or '[!0-9]'.
+// A metric or device is sent if the include list is empty or one of its
+// patterns matches, and no pattern of the exclude list matches.
+type MetricFilter struct {
+	IncludeMetrics []string `json:"include_metrics,omitempty"` // Send only metrics matching one of these patterns
+	ExcludeMetrics []string `json:"exclude_metrics,omitempty"` // Do not send metrics matching one of these patterns
+	IncludeDevices []string `json:"include_devices,omitempty"` // Send only devices matching one of these patterns
+	ExcludeDevices []string `json:"exclude_devices,omitempty"` // Do not send devices matching one of these patterns
+
+	includeMetrics []filterPattern
+	excludeMetrics []filterPattern
+	includeDevices []filterPattern
+	excludeDevices []filterPattern
+}
+
+// filterPattern is a compiled pattern of a MetricFilter. Exact names are
+// compared directly, glob patterns are translated to regular expressions.
+type filterPattern struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// globToRegex translates a glob pattern to an anchored regular expression
+func globToRegex(glob string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 == len(glob) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("missing ']'")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// newFilterPattern compiles a pattern of a MetricFilter
+func newFilterPattern(pattern string) (filterPattern, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return filterPattern{}, fmt.Errorf("invalid regular expression '%s': %w", pattern, err)
+		}
+		return filterPattern{pattern: pattern, regex: regex}, nil
+	}
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		return filterPattern{pattern: pattern}, nil
+	}
+	expr, err := globToRegex(pattern)
+	if err == nil {
+		var regex *regexp.Regexp
+		if regex, err = regexp.Compile(expr); err == nil {
+			return filterPattern{pattern: pattern, regex: regex}, nil
+		}
+	}
+	return filterPattern{}, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+}
+
+// match checks whether the name matches the pattern
+func (p *filterPattern) match(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	return p.pattern == name
+}
+
+// substringPattern returns a pattern matching all names containing the string,
+// e.g. for aliases with substring matching like exclude_mounts
+func substringPattern(s string) string {
+	return "/" + regexp.QuoteMeta(s) + "/"
+}
+
+// compileFilterPatterns compiles the patterns of a MetricFilter list
+func compileFilterPatterns(option string, patterns []string) ([]filterPattern, error) {
+	compiled := make([]filterPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := newFilterPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", option, err)
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled, nil
+}
+
+// matchAny checks whether one of the names matches one of the patterns
+func matchAny(patterns []filterPattern, names ...string) bool {
+	for i := range patterns {
+		for _, name := range names {
+			if patterns[i].match(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Init compiles the patterns of the filter. It has to be called after the
+// configuration was decoded and the aliases of the collector were added.
+func (f *MetricFilter) Init() error {
+	var err error
+	if f.includeMetrics, err = compileFilterPatterns("include_metrics", f.IncludeMetrics); err != nil {
+		return err
+	}
+	if f.excludeMetrics, err = compileFilterPatterns("exclude_metrics", f.ExcludeMetrics); err != nil {
+		return err
+	}
+	if f.includeDevices, err = compileFilterPatterns("include_devices", f.IncludeDevices); err != nil {
+		return err
+	}
+	if f.excludeDevices, err = compileFilterPatterns("exclude_devices", f.ExcludeDevices); err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsMetricExcluded checks whether the metric should not be sent
+func (f *MetricFilter) IsMetricExcluded(name string) bool {
+	if len(f.includeMetrics) > 0 && !matchAny(f.includeMetrics, name) {
+		return true
+	}
+	return matchAny(f.excludeMetrics, name)
+}
+
+// IsDeviceExcluded checks whether the device should not be sent. A device may
+// be identified in several ways, e.g. by its name and its PCI address, so
+// patterns may match any of the given identifiers.
+func (f *MetricFilter) IsDeviceExcluded(ids ...string) bool {
+	if len(f.includeDevices) > 0 && !matchAny(f.includeDevices, ids...) {
+		return true
+	}
+	return matchAny(f.excludeDevices, ids...)
+}
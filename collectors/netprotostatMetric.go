@@ -0,0 +1,252 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+// /proc/net links to the network namespace of the reading process, so with a
+// procfs root hostfs reads the files of the host's init process
+const (
+	NETSNMP_FILE    = `/proc/net/snmp`
+	NETSNMP6_FILE   = `/proc/net/snmp6`
+	NETNETSTAT_FILE = `/proc/net/netstat`
+)
+
+// netProtoCounter is a kernel protocol counter sent by the collector
+type netProtoCounter struct {
+	file string // File containing the counter
+	key  string // Counter in the file, '<Section>.<Field>' for files with header lines
+	name string // Metric name without the prefix 'netproto_'
+	unit string // Unit of the absolute value
+	desc string
+}
+
+var netProtoCounters = []netProtoCounter{
+	{NETSNMP_FILE, "Tcp.OutSegs", "tcp_out_segs", "packets", "TCP segments sent"},
+	{NETSNMP_FILE, "Tcp.RetransSegs", "tcp_retrans_segs", "packets", "TCP segments retransmitted"},
+	{NETSNMP_FILE, "Tcp.InErrs", "tcp_in_errs", "packets", "TCP segments received with errors"},
+	{NETSNMP_FILE, "Tcp.OutRsts", "tcp_out_rsts", "packets", "TCP segments sent with the RST flag"},
+	{NETSNMP_FILE, "Tcp.EstabResets", "tcp_estab_resets", "connections", "Established TCP connections reset"},
+	{NETSNMP_FILE, "Tcp.AttemptFails", "tcp_attempt_fails", "connections", "Failed TCP connection attempts"},
+	{NETNETSTAT_FILE, "TcpExt.ListenOverflows", "tcp_listen_overflows", "connections", "TCP connections not accepted because the accept queue of the listening socket was full"},
+	{NETNETSTAT_FILE, "TcpExt.ListenDrops", "tcp_listen_drops", "connections", "TCP connections dropped by listening sockets"},
+	{NETSNMP_FILE, "Udp.InErrors", "udp_in_errors", "packets", "UDP datagrams received with errors"},
+	{NETSNMP_FILE, "Udp.RcvbufErrors", "udp_rcvbuf_errors", "packets", "UDP datagrams dropped because the receive buffer was full"},
+	{NETSNMP_FILE, "Udp.SndbufErrors", "udp_sndbuf_errors", "packets", "UDP datagrams dropped because the send buffer was full"},
+	{NETSNMP_FILE, "Ip.FragFails", "ip_frag_fails", "packets", "IPv4 packets dropped because they could not be fragmented"},
+	{NETSNMP_FILE, "Ip.ReasmFails", "ip_reasm_fails", "packets", "IPv4 packet reassembly failures"},
+	{NETSNMP6_FILE, "Udp6InErrors", "udp6_in_errors", "packets", "UDP datagrams over IPv6 received with errors"},
+	{NETSNMP6_FILE, "Udp6RcvbufErrors", "udp6_rcvbuf_errors", "packets", "UDP datagrams over IPv6 dropped because the receive buffer was full"},
+	{NETSNMP6_FILE, "Udp6SndbufErrors", "udp6_sndbuf_errors", "packets", "UDP datagrams over IPv6 dropped because the send buffer was full"},
+	{NETSNMP6_FILE, "Ip6FragFails", "ip6_frag_fails", "packets", "IPv6 packets dropped because they could not be fragmented"},
+	{NETSNMP6_FILE, "Ip6ReasmFails", "ip6_reasm_fails", "packets", "IPv6 packet reassembly failures"},
+}
+
+type NetProtoStatCollectorConfig struct {
+	SendAbsoluteValues bool `json:"send_abs_values"`
+	SendDerivedValues  bool `json:"send_derived_values"`
+	MetricFilter
+}
+
+// NetProtoStatCollector reads the protocol statistics of the kernel network
+// stack from /proc/net/snmp, /proc/net/snmp6 and /proc/net/netstat
+type NetProtoStatCollector struct {
+	BaseCollector
+	config         NetProtoStatCollectorConfig
+	files          []string          // Files containing at least one of the counters
+	counters       []netProtoCounter // Counters found in the files
+	tags           map[string]string
+	previousValues map[string]int64 // Values of the last read by metric name
+	lastTimestamp  time.Time
+}
+
+func (m *NetProtoStatCollector) Init(config json.RawMessage) error {
+	m.name = "NetProtoStatCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "Network",
+	}
+	m.tags = map[string]string{
+		"type": "node",
+	}
+
+	m.config.SendDerivedValues = true
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+
+	// Keep the counters available on this node. /proc/net/snmp6 is missing
+	// if IPv6 is disabled.
+	m.files = make([]string, 0, 3)
+	for _, file := range []string{NETSNMP_FILE, NETNETSTAT_FILE, NETSNMP6_FILE} {
+		if _, err := hostfs.Stat(file); err == nil {
+			m.files = append(m.files, file)
+		}
+	}
+	values := m.readCounters()
+	m.counters = make([]netProtoCounter, 0, len(netProtoCounters))
+	for _, c := range netProtoCounters {
+		if _, ok := values[c.file][c.key]; ok {
+			m.counters = append(m.counters, c)
+		}
+	}
+	if len(m.counters) == 0 {
+		return fmt.Errorf("%s Init(): no protocol statistics found in %s, %s or %s",
+			m.name, NETSNMP_FILE, NETNETSTAT_FILE, NETSNMP6_FILE)
+	}
+
+	m.previousValues = make(map[string]int64)
+	m.lastTimestamp = time.Time{}
+	m.init = true
+	return nil
+}
+
+// parseNetSNMP parses the files /proc/net/snmp and /proc/net/netstat with
+// pairs of header and value lines like
+//
+//	Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors ...
+//	Udp: 14 0 0 14 0 ...
+//
+// The keys of the returned values are '<Section>.<Field>', e.g. 'Udp.InErrors'
+func parseNetSNMP(data []byte) (map[string]int64, error) {
+	values := make(map[string]int64)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		header := strings.Fields(lines[i])
+		fields := strings.Fields(lines[i+1])
+		if len(header) == 0 || len(header) != len(fields) || header[0] != fields[0] {
+			return nil, fmt.Errorf("invalid header line '%s' for value line '%s'", lines[i], lines[i+1])
+		}
+		section := strings.TrimSuffix(header[0], ":")
+		for j := 1; j < len(header); j++ {
+			v, err := strconv.ParseInt(fields[j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s.%s='%s' to int64: %w", section, header[j], fields[j], err)
+			}
+			values[section+"."+header[j]] = v
+		}
+	}
+	return values, nil
+}
+
+// parseNetSNMP6 parses the file /proc/net/snmp6 with one counter per line
+func parseNetSNMP6(data []byte) (map[string]int64, error) {
+	values := make(map[string]int64)
+	for line := range strings.SplitSeq(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s='%s' to int64: %w", fields[0], fields[1], err)
+		}
+		values[fields[0]] = v
+	}
+	return values, nil
+}
+
+// readCounters reads all counters of the files by file name
+func (m *NetProtoStatCollector) readCounters() map[string]map[string]int64 {
+	values := make(map[string]map[string]int64, len(m.files))
+	for _, file := range m.files {
+		data, err := hostfs.ReadFile(file)
+		if err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Failed to read file '%s': %v", file, err))
+			continue
+		}
+		parse := parseNetSNMP
+		if file == NETSNMP6_FILE {
+			parse = parseNetSNMP6
+		}
+		v, err := parse(data)
+		if err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Failed to parse file '%s': %v", file, err))
+			continue
+		}
+		values[file] = v
+	}
+	return values
+}
+
+func (m *NetProtoStatCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+
+	now := hostfs.Now()
+	timeDiff := now.Sub(m.lastTimestamp).Seconds()
+	m.lastTimestamp = now
+
+	values := m.readCounters()
+	for _, c := range m.counters {
+		value, ok := values[c.file][c.key]
+		if !ok {
+			continue
+		}
+		name := "netproto_" + c.name
+		if m.config.SendAbsoluteValues && !m.config.IsMetricExcluded(name) {
+			if y, err := lp.NewMetric(name, m.tags, m.meta, value, now); err == nil {
+				y.AddMeta("unit", c.unit)
+				output <- y
+			}
+		}
+		if m.config.SendDerivedValues {
+			prev, ok := m.previousValues[name]
+			if ok && value >= prev && !m.config.IsMetricExcluded(name+"_rate") {
+				rate := float64(value-prev) / timeDiff
+				if y, err := lp.NewMetric(name+"_rate", m.tags, m.meta, rate, now); err == nil {
+					y.AddMeta("unit", c.unit+"/sec")
+					output <- y
+				}
+			}
+			m.previousValues[name] = value
+		}
+	}
+}
+
+// Catalog returns the metrics the collector can send
+func (m *NetProtoStatCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	metrics := make([]mc.Metric, 0, 2*len(netProtoCounters))
+	for _, c := range netProtoCounters {
+		name := "netproto_" + c.name
+		metrics = append(metrics,
+			mc.Metric{Name: name, Unit: c.unit, Types: types, Kind: mc.Counter, Description: c.desc, Options: []string{"send_abs_values"}},
+			mc.Metric{Name: name + "_rate", Unit: c.unit + "/sec", Types: types, Kind: mc.Gauge, Description: c.desc + " per second"},
+		)
+	}
+	return metrics
+}
+
+func (m *NetProtoStatCollector) Close() {
+	m.init = false
+}
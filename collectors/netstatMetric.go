@@ -12,23 +12,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const NETSTATFILE = "/proc/net/dev"
 
 type NetstatCollectorConfig struct {
-	IncludeDevices     []string            `json:"include_devices"`
 	SendAbsoluteValues bool                `json:"send_abs_values"`
 	SendDerivedValues  bool                `json:"send_derived_values"`
 	InterfaceAliases   map[string][]string `json:"interface_aliases,omitempty"`
+	MetricFilter
 }
 
 type NetstatCollectorMetric struct {
@@ -41,7 +41,7 @@ type NetstatCollectorMetric struct {
 }
 
 type NetstatCollector struct {
-	metricCollector
+	BaseCollector
 
 	config           NetstatCollectorConfig
 	aliasToCanonical map[string]string
@@ -71,7 +71,7 @@ func (m *NetstatCollector) Init(config json.RawMessage) error {
 	if err := m.setup(); err != nil {
 		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
 	}
-	m.lastTimestamp = time.Now()
+	m.lastTimestamp = hostfs.Now()
 
 	const (
 		fieldInterface = iota
@@ -106,11 +106,14 @@ func (m *NetstatCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	m.buildAliasMapping()
 
 	// Check access to net statistic file
-	file, err := os.Open(NETSTATFILE)
+	file, err := hostfs.Open(NETSTATFILE)
 	if err != nil {
 		return fmt.Errorf("%s Init(): failed to open netstat file \"%s\": %w", m.name, NETSTATFILE, err)
 	}
@@ -131,8 +134,9 @@ func (m *NetstatCollector) Init(config json.RawMessage) error {
 		raw := strings.Trim(f[0], ": ")
 		canonical := getCanonicalName(raw, m.aliasToCanonical)
 
-		// Check if device is a included device
-		if slices.Contains(m.config.IncludeDevices, canonical) {
+		// Check if device is a included device. Unlike the other collectors,
+		// netstat requires an include list and sends no devices without it.
+		if len(m.config.IncludeDevices) > 0 && !m.config.IsDeviceExcluded(canonical, raw) {
 			// Tag will contain original device name (raw).
 			tags := map[string]string{
 				"stype":    "network",
@@ -214,13 +218,13 @@ func (m *NetstatCollector) Read(interval time.Duration, output chan lp.CCMessage
 		return
 	}
 	// Current time stamp
-	now := time.Now()
+	now := hostfs.Now()
 	// time difference to last time stamp
 	timeDiff := now.Sub(m.lastTimestamp).Seconds()
 	// Save current timestamp
 	m.lastTimestamp = now
 
-	file, err := os.Open(NETSTATFILE)
+	file, err := hostfs.Open(NETSTATFILE)
 	if err != nil {
 		cclog.ComponentError(
 			m.name,
@@ -261,7 +265,7 @@ func (m *NetstatCollector) Read(interval time.Duration, output chan lp.CCMessage
 				if err != nil {
 					continue
 				}
-				if m.config.SendAbsoluteValues {
+				if m.config.SendAbsoluteValues && !m.config.IsMetricExcluded(metric.name) {
 					if y, err := lp.NewMetric(metric.name, metric.tags, metric.meta, v, now); err == nil {
 						output <- y
 					}
@@ -269,7 +273,7 @@ func (m *NetstatCollector) Read(interval time.Duration, output chan lp.CCMessage
 				if m.config.SendDerivedValues {
 					if metric.lastValue >= 0 {
 						rate := float64(v-metric.lastValue) / timeDiff
-						if y, err := lp.NewMetric(metric.name+"_bw", metric.tags, metric.meta_rates, rate, now); err == nil {
+						if y, err := lp.NewMetric(metric.name+"_bw", metric.tags, metric.meta_rates, rate, now); err == nil && !m.config.IsMetricExcluded(metric.name+"_bw") {
 							output <- y
 						}
 					}
@@ -280,6 +284,22 @@ func (m *NetstatCollector) Read(interval time.Duration, output chan lp.CCMessage
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *NetstatCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	derived := []string{"send_derived_values"}
+	return []mc.Metric{
+		{Name: "net_bytes_in", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Received bytes"},
+		{Name: "net_bytes_out", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Transmitted bytes"},
+		{Name: "net_pkts_in", Unit: "packets", Types: types, Kind: mc.Counter, Description: "Received packets"},
+		{Name: "net_pkts_out", Unit: "packets", Types: types, Kind: mc.Counter, Description: "Transmitted packets"},
+		{Name: "net_bytes_in_bw", Unit: "bytes/sec", Types: types, Kind: mc.Gauge, Description: "Receive bandwidth", Options: derived},
+		{Name: "net_bytes_out_bw", Unit: "bytes/sec", Types: types, Kind: mc.Gauge, Description: "Transmit bandwidth", Options: derived},
+		{Name: "net_pkts_in_bw", Unit: "packets/sec", Types: types, Kind: mc.Gauge, Description: "Receive packet rate", Options: derived},
+		{Name: "net_pkts_out_bw", Unit: "packets/sec", Types: types, Kind: mc.Gauge, Description: "Transmit packet rate", Options: derived},
+	}
+}
+
 func (m *NetstatCollector) Close() {
 	m.init = false
 }
@@ -11,16 +11,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"slices"
 
 	//	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // First part contains the code for the general NfsCollector.
@@ -34,19 +34,19 @@ type NfsCollectorData struct {
 }
 
 type nfsCollector struct {
-	metricCollector
+	BaseCollector
 
 	tags    map[string]string
 	version string
 	config  struct {
-		Nfsstats       string   `json:"nfsstat"`
-		ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
+		Nfsstats string `json:"nfsstat"`
+		MetricFilter
 	}
 	data map[string]NfsCollectorData
 }
 
 func (m *nfsCollector) updateStats() error {
-	cmd := exec.Command(m.config.Nfsstats, "-l", "--all")
+	cmd := hostfs.Command(m.config.Nfsstats, "-l", "--all")
 
 	buffer, err := cmd.Output()
 	if err != nil {
@@ -97,6 +97,9 @@ func (m *nfsCollector) MainInit(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.meta = map[string]string{
 		"source": m.name,
 		"group":  "NFS",
@@ -105,7 +108,7 @@ func (m *nfsCollector) MainInit(config json.RawMessage) error {
 		"type": "node",
 	}
 	// Check if nfsstat is in executable search path
-	_, err := exec.LookPath(m.config.Nfsstats)
+	_, err := hostfs.LookPath(m.config.Nfsstats)
 	if err != nil {
 		return fmt.Errorf("%s Init(): Failed to find nfsstat binary '%s': %w", m.name, m.config.Nfsstats, err)
 	}
@@ -122,7 +125,7 @@ func (m *nfsCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	if !m.init {
 		return
 	}
-	timestamp := time.Now()
+	timestamp := hostfs.Now()
 
 	if err := m.updateStats(); err != nil {
 		cclog.ComponentError(
@@ -142,7 +145,7 @@ func (m *nfsCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	}
 
 	for name, data := range m.data {
-		if slices.Contains(m.config.ExcludeMetrics, name) {
+		if m.config.IsMetricExcluded(fmt.Sprintf("%s_%s", prefix, name)) {
 			continue
 		}
 
@@ -186,3 +189,20 @@ func (m *Nfs4Collector) Init(config json.RawMessage) error {
 	}
 	return m.MainInit(config)
 }
+
+// nfsCatalog returns the metrics of the NFS collectors with the given prefix
+func nfsCatalog(prefix, version string) []mc.Metric {
+	return []mc.Metric{
+		{Name: prefix + "_*", Types: []string{"node"}, Kind: mc.Gauge, Description: "NFS " + version + " client operations of the type given by the suffix since the last read, e.g. " + prefix + "_read"},
+	}
+}
+
+// Catalog returns the metrics the collector can send
+func (m *Nfs3Collector) Catalog() []mc.Metric {
+	return nfsCatalog("nfs3", "v3")
+}
+
+// Catalog returns the metrics the collector can send
+func (m *Nfs4Collector) Catalog() []mc.Metric {
+	return nfsCatalog("nfs4", "v4")
+}
@@ -11,7 +11,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"regexp"
 	"slices"
 	"strconv"
@@ -19,21 +18,23 @@ import (
 	"time"
 
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // These are the fields we read from the JSON configuration
 type NfsIOStatCollectorConfig struct {
-	ExcludeMetrics          []string `json:"exclude_metrics,omitempty"`
-	ExcludeFilesystems      []string `json:"exclude_filesystem,omitempty"`
+	ExcludeFilesystems      []string `json:"exclude_filesystem,omitempty"` // Alias of exclude_devices
 	UseServerAddressAsSType bool     `json:"use_server_as_stype,omitempty"`
 	SendAbsoluteValues      bool     `json:"send_abs_values"`
 	SendDerivedValues       bool     `json:"send_derived_values"`
+	MetricFilter
 }
 
 // This contains all variables we need during execution and the variables
-// defined by metricCollector (name, init, ...)
+// defined by BaseCollector (name, init, ...)
 type NfsIOStatCollector struct {
-	metricCollector
+	BaseCollector
 
 	config        NfsIOStatCollectorConfig    // the configuration structure
 	meta          map[string]string           // default meta information
@@ -64,7 +65,7 @@ func resolve_regex_fields(s string, regex *regexp.Regexp) map[string]string {
 func (m *NfsIOStatCollector) readNfsiostats() map[string]map[string]int64 {
 	data := make(map[string]map[string]int64)
 	filename := "/proc/self/mountstats"
-	stats, err := os.ReadFile(filename)
+	stats, err := hostfs.ReadFile(filename)
 	if err != nil {
 		return data
 	}
@@ -75,7 +76,7 @@ func (m *NfsIOStatCollector) readNfsiostats() map[string]map[string]int64 {
 		// Is this a device line with mount point, remote target and NFS version?
 		dev := resolve_regex_fields(l, deviceRegex)
 		if len(dev) > 0 {
-			if !slices.Contains(m.config.ExcludeFilesystems, dev[m.key]) {
+			if !m.config.IsDeviceExcluded(dev["mntpoint"], dev["server"]) {
 				current = dev
 				if len(current["version"]) == 0 {
 					current["version"] = "3"
@@ -89,6 +90,7 @@ func (m *NfsIOStatCollector) readNfsiostats() map[string]map[string]int64 {
 			if len(bytes) > 0 {
 				data[current[m.key]] = make(map[string]int64)
 				for name, sval := range bytes {
+					// Excluded metrics may also be given without prefix
 					if !slices.Contains(m.config.ExcludeMetrics, name) {
 						val, err := strconv.ParseInt(sval, 10, 64)
 						if err == nil {
@@ -122,18 +124,23 @@ func (m *NfsIOStatCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	// exclude_filesystem is an alias of exclude_devices
+	m.config.ExcludeDevices = append(m.config.ExcludeDevices, m.config.ExcludeFilesystems...)
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.key = "mntpoint"
 	if m.config.UseServerAddressAsSType {
 		m.key = "server"
 	}
 	m.data = m.readNfsiostats()
-	m.lastTimestamp = time.Now()
+	m.lastTimestamp = hostfs.Now()
 	m.init = true
 	return nil
 }
 
 func (m *NfsIOStatCollector) Read(interval time.Duration, output chan lp.CCMessage) {
-	now := time.Now()
+	now := hostfs.Now()
 	timeDiff := now.Sub(m.lastTimestamp).Seconds()
 	m.lastTimestamp = now
 
@@ -144,7 +151,7 @@ func (m *NfsIOStatCollector) Read(interval time.Duration, output chan lp.CCMessa
 		// Was the mount point already present in the last iteration
 		if old, ok := m.data[mntpoint]; ok {
 			for name, newVal := range values {
-				if m.config.SendAbsoluteValues {
+				if m.config.SendAbsoluteValues && !m.config.IsMetricExcluded("nfsio_"+name) {
 					msg, err := lp.NewMetric("nfsio_"+name, m.tags, m.meta, newVal, now)
 					if err == nil {
 						msg.AddTag("stype", "filesystem")
@@ -152,7 +159,7 @@ func (m *NfsIOStatCollector) Read(interval time.Duration, output chan lp.CCMessa
 						output <- msg
 					}
 				}
-				if m.config.SendDerivedValues {
+				if m.config.SendDerivedValues && !m.config.IsMetricExcluded(fmt.Sprintf("nfsio_%s_bw", name)) {
 					rate := float64(newVal-old[name]) / timeDiff
 					msg, err := lp.NewMetric(fmt.Sprintf("nfsio_%s_bw", name), m.tags, m.meta, rate, now)
 					if err == nil {
@@ -188,6 +195,24 @@ func (m *NfsIOStatCollector) Read(interval time.Duration, output chan lp.CCMessa
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *NfsIOStatCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	derived := []string{"send_derived_values"}
+	return []mc.Metric{
+		{Name: "nfsio_nread", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Bytes transferred by normal read() calls"},
+		{Name: "nfsio_nwrite", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Bytes transferred by normal write() calls"},
+		{Name: "nfsio_oread", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Bytes transferred by read() calls with O_DIRECT"},
+		{Name: "nfsio_owrite", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Bytes transferred by write() calls with O_DIRECT"},
+		{Name: "nfsio_pageread", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Pages transferred by read() calls"},
+		{Name: "nfsio_pagewrite", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Pages transferred by write() calls"},
+		{Name: "nfsio_nfsread", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Bytes transferred for reading from the server"},
+		{Name: "nfsio_nfswrite", Unit: "bytes", Types: types, Kind: mc.Counter, Description: "Bytes transferred for writing to the server"},
+		{Name: "nfsio_page*_bw", Unit: "4K_pages/s", Types: types, Kind: mc.Gauge, Description: "Rate of the corresponding page metric", Options: derived},
+		{Name: "nfsio_*_bw", Unit: "bytes/sec", Types: types, Kind: mc.Gauge, Description: "Bandwidth of the corresponding byte metric", Options: derived},
+	}
+}
+
 func (m *NfsIOStatCollector) Close() {
 	// Unset flag
 	m.init = false
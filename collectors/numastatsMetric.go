@@ -5,7 +5,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -13,11 +12,14 @@ import (
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 type NUMAStatsCollectorConfig struct {
 	SendAbsoluteValues bool `json:"send_abs_values"`
 	SendDerivedValues  bool `json:"send_derived_values"`
+	MetricFilter
 }
 
 // Non-Uniform Memory Access (NUMA) policy hit/miss statistics
@@ -59,7 +61,7 @@ type NUMAStatsCollectorTopolgy struct {
 }
 
 type NUMAStatsCollector struct {
-	metricCollector
+	BaseCollector
 
 	topology      []NUMAStatsCollectorTopolgy
 	config        NUMAStatsCollectorConfig
@@ -90,11 +92,14 @@ func (m *NUMAStatsCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	// Loop for all NUMA node directories
 	base := "/sys/devices/system/node/node"
 	globPattern := base + "[0-9]*"
-	dirs, err := filepath.Glob(globPattern)
+	dirs, err := hostfs.Glob(globPattern)
 	if err != nil {
 		return fmt.Errorf("%s Init(): unable to glob files with pattern '%s'", m.name, globPattern)
 	}
@@ -127,7 +132,7 @@ func (m *NUMAStatsCollector) Read(interval time.Duration, output chan lp.CCMessa
 		return
 	}
 
-	now := time.Now()
+	now := hostfs.Now()
 	timeDiff := now.Sub(m.lastTimestamp).Seconds()
 	m.lastTimestamp = now
 
@@ -135,7 +140,7 @@ func (m *NUMAStatsCollector) Read(interval time.Duration, output chan lp.CCMessa
 		// Loop for all NUMA domains
 		t := &m.topology[i]
 
-		file, err := os.Open(t.file)
+		file, err := hostfs.Open(t.file)
 		if err != nil {
 			cclog.ComponentError(
 				m.name,
@@ -160,7 +165,7 @@ func (m *NUMAStatsCollector) Read(interval time.Duration, output chan lp.CCMessa
 				continue
 			}
 
-			if m.config.SendAbsoluteValues {
+			if m.config.SendAbsoluteValues && !m.config.IsMetricExcluded("numastats_"+key) {
 				msg, err := lp.NewMetric(
 					"numastats_"+key,
 					t.tagSet,
@@ -175,7 +180,7 @@ func (m *NUMAStatsCollector) Read(interval time.Duration, output chan lp.CCMessa
 
 			if m.config.SendDerivedValues {
 				prev, ok := t.previousValues[key]
-				if ok {
+				if ok && !m.config.IsMetricExcluded("numastats_"+key+"_rate") {
 					rate := float64(value-prev) / timeDiff
 					msg, err := lp.NewMetric(
 						"numastats_"+key+"_rate",
@@ -199,6 +204,21 @@ func (m *NUMAStatsCollector) Read(interval time.Duration, output chan lp.CCMessa
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *NUMAStatsCollector) Catalog() []mc.Metric {
+	types := []string{"memoryDomain"}
+	derived := []string{"send_derived_values"}
+	return []mc.Metric{
+		{Name: "numastats_numa_hit", Types: types, Kind: mc.Counter, Description: "Memory successfully allocated on this node as intended"},
+		{Name: "numastats_numa_miss", Types: types, Kind: mc.Counter, Description: "Memory allocated on this node despite the process preferring some different node"},
+		{Name: "numastats_numa_foreign", Types: types, Kind: mc.Counter, Description: "Memory intended for this node, but actually allocated on some different node"},
+		{Name: "numastats_local_node", Types: types, Kind: mc.Counter, Description: "Memory allocated on this node while a process was running on it"},
+		{Name: "numastats_other_node", Types: types, Kind: mc.Counter, Description: "Memory allocated on this node while a process was running on some other node"},
+		{Name: "numastats_interleave_hit", Types: types, Kind: mc.Counter, Description: "Interleaved memory successfully allocated on this node as intended"},
+		{Name: "numastats_*_rate", Types: types, Kind: mc.Gauge, Description: "Rate of the corresponding numastats_* metric per second", Options: derived},
+	}
+}
+
 func (m *NUMAStatsCollector) Close() {
 	m.init = false
 }
@@ -13,32 +13,32 @@ import (
 	"fmt"
 	"log"
 	"maps"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 )
 
 type NvidiaCollectorConfig struct {
-	ExcludeMetrics        []string `json:"exclude_metrics,omitempty"`
-	ExcludeDevices        []string `json:"exclude_devices,omitempty"`
-	AddPciInfoTag         bool     `json:"add_pci_info_tag,omitempty"`
-	UsePciInfoAsTypeId    bool     `json:"use_pci_info_as_type_id,omitempty"`
-	AddUuidMeta           bool     `json:"add_uuid_meta,omitempty"`
-	AddBoardNumberMeta    bool     `json:"add_board_number_meta,omitempty"`
-	AddSerialMeta         bool     `json:"add_serial_meta,omitempty"`
-	ProcessMigDevices     bool     `json:"process_mig_devices,omitempty"`
-	UseUuidForMigDevices  bool     `json:"use_uuid_for_mig_device,omitempty"`
-	UseSliceForMigDevices bool     `json:"use_slice_for_mig_device,omitempty"`
+	AddPciInfoTag         bool `json:"add_pci_info_tag,omitempty"`
+	UsePciInfoAsTypeId    bool `json:"use_pci_info_as_type_id,omitempty"`
+	AddUuidMeta           bool `json:"add_uuid_meta,omitempty"`
+	AddBoardNumberMeta    bool `json:"add_board_number_meta,omitempty"`
+	AddSerialMeta         bool `json:"add_serial_meta,omitempty"`
+	ProcessMigDevices     bool `json:"process_mig_devices,omitempty"`
+	UseUuidForMigDevices  bool `json:"use_uuid_for_mig_device,omitempty"`
+	UseSliceForMigDevices bool `json:"use_slice_for_mig_device,omitempty"`
+	AddAffinityTags       bool `json:"add_affinity_tags,omitempty"`
+	MetricFilter
 }
 
 type NvidiaCollectorDevice struct {
 	device              nvml.Device
-	excludeMetrics      map[string]bool
+	filter              *MetricFilter
 	tags                map[string]string
 	meta                map[string]string
 	lastEnergyReading   uint64
@@ -46,7 +46,7 @@ type NvidiaCollectorDevice struct {
 }
 
 type NvidiaCollector struct {
-	metricCollector
+	BaseCollector
 
 	config   NvidiaCollectorConfig
 	gpus     []NvidiaCollectorDevice
@@ -78,6 +78,9 @@ func (m *NvidiaCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.meta = map[string]string{
 		"source": m.name,
 		"group":  "Nvidia",
@@ -110,12 +113,7 @@ func (m *NvidiaCollector) Init(config json.RawMessage) error {
 	m.gpus = make([]NvidiaCollectorDevice, num_gpus)
 	for i := range num_gpus {
 
-		// Skip excluded devices by ID
 		str_i := strconv.Itoa(i)
-		if slices.Contains(m.config.ExcludeDevices, str_i) {
-			cclog.ComponentDebug(m.name, "Skipping excluded device", str_i)
-			continue
-		}
 
 		// Get device handle
 		device, ret := nvml.DeviceGetHandleByIndex(i)
@@ -139,8 +137,8 @@ func (m *NvidiaCollector) Init(config json.RawMessage) error {
 			pciInfo.Bus,
 			pciInfo.Device)
 
-		// Skip excluded devices specified by PCI ID
-		if slices.Contains(m.config.ExcludeDevices, pci_id) {
+		// Skip excluded devices specified by ID or PCI ID
+		if m.config.IsDeviceExcluded(str_i, pci_id) {
 			cclog.ComponentDebug(m.name, "Skipping excluded device", pci_id)
 			continue
 		}
@@ -175,6 +173,11 @@ func (m *NvidiaCollector) Init(config json.RawMessage) error {
 			g.tags["pci_identifier"] = pci_id
 		}
 
+		// Add NUMA domain and socket of the GPU
+		if m.config.AddAffinityTags {
+			addAffinityTags(g.tags, pciAffinityTags(pci_id))
+		}
+
 		g.meta = map[string]string{
 			"source": m.name,
 			"group":  "Nvidia",
@@ -205,11 +208,8 @@ func (m *NvidiaCollector) Init(config json.RawMessage) error {
 			}
 		}
 
-		// Add excluded metrics
-		g.excludeMetrics = map[string]bool{}
-		for _, e := range m.config.ExcludeMetrics {
-			g.excludeMetrics[e] = true
-		}
+		// Add metric filter
+		g.filter = &m.config.MetricFilter
 
 		// Increment the index for the next device
 		idx++
@@ -221,7 +221,7 @@ func (m *NvidiaCollector) Init(config json.RawMessage) error {
 }
 
 func readMemoryInfo(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_fb_mem_total"] || !device.excludeMetrics["nv_fb_mem_used"] || !device.excludeMetrics["nv_fb_mem_reserved"] {
+	if !device.filter.IsMetricExcluded("nv_fb_mem_total") || !device.filter.IsMetricExcluded("nv_fb_mem_used") || !device.filter.IsMetricExcluded("nv_fb_mem_reserved") {
 		var total uint64
 		var used uint64
 		var reserved uint64 = 0
@@ -236,7 +236,7 @@ func readMemoryInfo(device *NvidiaCollectorDevice, output chan lp.CCMessage) err
 		// Sum of Reserved and Allocated device memory (in bytes)
 		used = meminfo.Used
 
-		if !device.excludeMetrics["nv_fb_mem_total"] {
+		if !device.filter.IsMetricExcluded("nv_fb_mem_total") {
 			t := float64(total) / (1024 * 1024)
 			y, err := lp.NewMetric("nv_fb_mem_total", device.tags, device.meta, t, time.Now())
 			if err == nil {
@@ -245,7 +245,7 @@ func readMemoryInfo(device *NvidiaCollectorDevice, output chan lp.CCMessage) err
 			}
 		}
 
-		if !device.excludeMetrics["nv_fb_mem_used"] {
+		if !device.filter.IsMetricExcluded("nv_fb_mem_used") {
 			f := float64(used) / (1024 * 1024)
 			y, err := lp.NewMetric("nv_fb_mem_used", device.tags, device.meta, f, time.Now())
 			if err == nil {
@@ -254,7 +254,7 @@ func readMemoryInfo(device *NvidiaCollectorDevice, output chan lp.CCMessage) err
 			}
 		}
 
-		if v2 && !device.excludeMetrics["nv_fb_mem_reserved"] {
+		if v2 && !device.filter.IsMetricExcluded("nv_fb_mem_reserved") {
 			r := float64(reserved) / (1024 * 1024)
 			y, err := lp.NewMetric("nv_fb_mem_reserved", device.tags, device.meta, r, time.Now())
 			if err == nil {
@@ -267,13 +267,13 @@ func readMemoryInfo(device *NvidiaCollectorDevice, output chan lp.CCMessage) err
 }
 
 func readBarMemoryInfo(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_bar1_mem_total"] || !device.excludeMetrics["nv_bar1_mem_used"] {
+	if !device.filter.IsMetricExcluded("nv_bar1_mem_total") || !device.filter.IsMetricExcluded("nv_bar1_mem_used") {
 		meminfo, ret := nvml.DeviceGetBAR1MemoryInfo(device.device)
 		if ret != nvml.SUCCESS {
 			err := errors.New(nvml.ErrorString(ret))
 			return err
 		}
-		if !device.excludeMetrics["nv_bar1_mem_total"] {
+		if !device.filter.IsMetricExcluded("nv_bar1_mem_total") {
 			t := float64(meminfo.Bar1Total) / (1024 * 1024)
 			y, err := lp.NewMetric("nv_bar1_mem_total", device.tags, device.meta, t, time.Now())
 			if err == nil {
@@ -281,7 +281,7 @@ func readBarMemoryInfo(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 				output <- y
 			}
 		}
-		if !device.excludeMetrics["nv_bar1_mem_used"] {
+		if !device.filter.IsMetricExcluded("nv_bar1_mem_used") {
 			t := float64(meminfo.Bar1Used) / (1024 * 1024)
 			y, err := lp.NewMetric("nv_bar1_mem_used", device.tags, device.meta, t, time.Now())
 			if err == nil {
@@ -303,7 +303,7 @@ func readUtilization(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 		return nil
 	}
 
-	if !device.excludeMetrics["nv_util"] || !device.excludeMetrics["nv_mem_util"] {
+	if !device.filter.IsMetricExcluded("nv_util") || !device.filter.IsMetricExcluded("nv_mem_util") {
 		// Retrieves the current utilization rates for the device's major subsystems.
 		//
 		// Available utilization rates
@@ -316,14 +316,14 @@ func readUtilization(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 		// * On MIG-enabled GPUs, querying device utilization rates is not currently supported.
 		util, ret := nvml.DeviceGetUtilizationRates(device.device)
 		if ret == nvml.SUCCESS {
-			if !device.excludeMetrics["nv_util"] {
+			if !device.filter.IsMetricExcluded("nv_util") {
 				y, err := lp.NewMetric("nv_util", device.tags, device.meta, float64(util.Gpu), time.Now())
 				if err == nil {
 					y.AddMeta("unit", "%")
 					output <- y
 				}
 			}
-			if !device.excludeMetrics["nv_mem_util"] {
+			if !device.filter.IsMetricExcluded("nv_mem_util") {
 				y, err := lp.NewMetric("nv_mem_util", device.tags, device.meta, float64(util.Memory), time.Now())
 				if err == nil {
 					y.AddMeta("unit", "%")
@@ -336,7 +336,7 @@ func readUtilization(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 }
 
 func readTemp(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_temp"] {
+	if !device.filter.IsMetricExcluded("nv_temp") {
 		// Retrieves the current temperature readings for the device, in degrees C.
 		//
 		// Available temperature sensors:
@@ -355,7 +355,7 @@ func readTemp(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
 }
 
 func readFan(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_fan"] {
+	if !device.filter.IsMetricExcluded("nv_fan") {
 		// Retrieves the intended operating speed of the device's fan.
 		//
 		// Note: The reported speed is the intended fan speed.
@@ -378,7 +378,7 @@ func readFan(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
 }
 
 func readEccMode(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_ecc_mode"] {
+	if !device.filter.IsMetricExcluded("nv_ecc_mode") {
 		// Retrieves the current and pending ECC modes for the device.
 		//
 		// For Fermi or newer fully supported devices. Only applicable to devices with ECC.
@@ -413,7 +413,7 @@ func readEccMode(device *NvidiaCollectorDevice, output chan lp.CCMessage) error
 }
 
 func readPerfState(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_perf_state"] {
+	if !device.filter.IsMetricExcluded("nv_perf_state") {
 		// Retrieves the current performance state for the device.
 		//
 		// Allowed PStates:
@@ -433,7 +433,7 @@ func readPerfState(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 }
 
 func readPowerUsage(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_power_usage"] {
+	if !device.filter.IsMetricExcluded("nv_power_usage") {
 		// Retrieves power usage for this GPU in milliwatts and its associated circuitry (e.g. memory)
 		//
 		// On Fermi and Kepler GPUs the reading is accurate to within +/- 5% of current power draw.
@@ -464,7 +464,7 @@ func readEnergyConsumption(device *NvidiaCollectorDevice, output chan lp.CCMessa
 	// Retrieves total energy consumption for this GPU in millijoules (mJ) since the driver was last reloaded
 
 	// For Volta or newer fully supported devices.
-	if (!device.excludeMetrics["nv_energy"]) && (!device.excludeMetrics["nv_energy_abs"]) && (!device.excludeMetrics["nv_average_power"]) {
+	if (!device.filter.IsMetricExcluded("nv_energy")) && (!device.filter.IsMetricExcluded("nv_energy_abs")) && (!device.filter.IsMetricExcluded("nv_average_power")) {
 		now := time.Now()
 		mode, ret := nvml.DeviceGetPowerManagementMode(device.device)
 		if ret != nvml.SUCCESS {
@@ -474,7 +474,7 @@ func readEnergyConsumption(device *NvidiaCollectorDevice, output chan lp.CCMessa
 			energy, ret := nvml.DeviceGetTotalEnergyConsumption(device.device)
 			if ret == nvml.SUCCESS {
 				if device.lastEnergyReading != 0 {
-					if !device.excludeMetrics["nv_energy"] {
+					if !device.filter.IsMetricExcluded("nv_energy") {
 						y, err := lp.NewMetric(
 							"nv_energy",
 							device.tags,
@@ -486,7 +486,7 @@ func readEnergyConsumption(device *NvidiaCollectorDevice, output chan lp.CCMessa
 							output <- y
 						}
 					}
-					if !device.excludeMetrics["nv_average_power"] {
+					if !device.filter.IsMetricExcluded("nv_average_power") {
 
 						energyDiff := (energy - device.lastEnergyReading) / 1000
 						timeDiff := now.Sub(device.lastEnergyTimestamp)
@@ -497,7 +497,7 @@ func readEnergyConsumption(device *NvidiaCollectorDevice, output chan lp.CCMessa
 						}
 					}
 				}
-				if !device.excludeMetrics["nv_energy_abs"] {
+				if !device.filter.IsMetricExcluded("nv_energy_abs") {
 					y, err := lp.NewMetric("nv_energy_abs", device.tags, device.meta, energy/1000, now)
 					if err == nil {
 						y.AddMeta("unit", "Joules")
@@ -519,7 +519,7 @@ func readClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
 	// * CLOCK_GRAPHICS: Graphics clock domain.
 	// * CLOCK_SM: Streaming Multiprocessor clock domain.
 	// * CLOCK_MEM: Memory clock domain.
-	if !device.excludeMetrics["nv_graphics_clock"] {
+	if !device.filter.IsMetricExcluded("nv_graphics_clock") {
 		graphicsClock, ret := nvml.DeviceGetClockInfo(device.device, nvml.CLOCK_GRAPHICS)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_graphics_clock", device.tags, device.meta, float64(graphicsClock), time.Now())
@@ -530,7 +530,7 @@ func readClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
 		}
 	}
 
-	if !device.excludeMetrics["nv_sm_clock"] {
+	if !device.filter.IsMetricExcluded("nv_sm_clock") {
 		smCock, ret := nvml.DeviceGetClockInfo(device.device, nvml.CLOCK_SM)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_sm_clock", device.tags, device.meta, float64(smCock), time.Now())
@@ -541,7 +541,7 @@ func readClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
 		}
 	}
 
-	if !device.excludeMetrics["nv_mem_clock"] {
+	if !device.filter.IsMetricExcluded("nv_mem_clock") {
 		memClock, ret := nvml.DeviceGetClockInfo(device.device, nvml.CLOCK_MEM)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_mem_clock", device.tags, device.meta, float64(memClock), time.Now())
@@ -551,7 +551,7 @@ func readClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_video_clock"] {
+	if !device.filter.IsMetricExcluded("nv_video_clock") {
 		memClock, ret := nvml.DeviceGetClockInfo(device.device, nvml.CLOCK_VIDEO)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_video_clock", device.tags, device.meta, float64(memClock), time.Now())
@@ -576,7 +576,7 @@ func readMaxClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 	//
 	// Note:
 	/// On GPUs from Fermi family current P0 clocks (reported by nvmlDeviceGetClockInfo) can differ from max clocks by few MHz.
-	if !device.excludeMetrics["nv_max_graphics_clock"] {
+	if !device.filter.IsMetricExcluded("nv_max_graphics_clock") {
 		max_gclk, ret := nvml.DeviceGetMaxClockInfo(device.device, nvml.CLOCK_GRAPHICS)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_max_graphics_clock", device.tags, device.meta, float64(max_gclk), time.Now())
@@ -587,7 +587,7 @@ func readMaxClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 		}
 	}
 
-	if !device.excludeMetrics["nv_max_sm_clock"] {
+	if !device.filter.IsMetricExcluded("nv_max_sm_clock") {
 		maxSmClock, ret := nvml.DeviceGetMaxClockInfo(device.device, nvml.CLOCK_SM)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_max_sm_clock", device.tags, device.meta, float64(maxSmClock), time.Now())
@@ -598,7 +598,7 @@ func readMaxClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 		}
 	}
 
-	if !device.excludeMetrics["nv_max_mem_clock"] {
+	if !device.filter.IsMetricExcluded("nv_max_mem_clock") {
 		maxMemClock, ret := nvml.DeviceGetMaxClockInfo(device.device, nvml.CLOCK_MEM)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_max_mem_clock", device.tags, device.meta, float64(maxMemClock), time.Now())
@@ -609,7 +609,7 @@ func readMaxClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 		}
 	}
 
-	if !device.excludeMetrics["nv_max_video_clock"] {
+	if !device.filter.IsMetricExcluded("nv_max_video_clock") {
 		maxVideoClock, ret := nvml.DeviceGetMaxClockInfo(device.device, nvml.CLOCK_VIDEO)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_max_video_clock", device.tags, device.meta, float64(maxVideoClock), time.Now())
@@ -623,7 +623,7 @@ func readMaxClocks(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 }
 
 func readEccErrors(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_ecc_uncorrected_error"] {
+	if !device.filter.IsMetricExcluded("nv_ecc_uncorrected_error") {
 		// Retrieves the total ECC error counts for the device.
 		//
 		// For Fermi or newer fully supported devices.
@@ -641,7 +641,7 @@ func readEccErrors(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_ecc_corrected_error"] {
+	if !device.filter.IsMetricExcluded("nv_ecc_corrected_error") {
 		ecc_sb, ret := nvml.DeviceGetTotalEccErrors(device.device, nvml.MEMORY_ERROR_TYPE_CORRECTED, nvml.AGGREGATE_ECC)
 		if ret == nvml.SUCCESS {
 			y, err := lp.NewMetric("nv_ecc_corrected_error", device.tags, device.meta, float64(ecc_sb), time.Now())
@@ -654,7 +654,7 @@ func readEccErrors(device *NvidiaCollectorDevice, output chan lp.CCMessage) erro
 }
 
 func readPowerLimit(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_power_max_limit"] {
+	if !device.filter.IsMetricExcluded("nv_power_max_limit") {
 		// Retrieves the power management limit associated with this device.
 		//
 		// For Fermi or newer fully supported devices.
@@ -682,7 +682,7 @@ func readEncUtilization(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 	if isMig {
 		return nil
 	}
-	if !device.excludeMetrics["nv_encoder_util"] {
+	if !device.filter.IsMetricExcluded("nv_encoder_util") {
 		// Retrieves the current utilization and sampling size in microseconds for the Encoder
 		//
 		// For Kepler or newer fully supported devices.
@@ -709,7 +709,7 @@ func readDecUtilization(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 	if isMig {
 		return nil
 	}
-	if !device.excludeMetrics["nv_decoder_util"] {
+	if !device.filter.IsMetricExcluded("nv_decoder_util") {
 		// Retrieves the current utilization and sampling size in microseconds for the Encoder
 		//
 		// For Kepler or newer fully supported devices.
@@ -728,10 +728,10 @@ func readDecUtilization(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 }
 
 func readRemappedRows(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_remapped_rows_corrected"] ||
-		!device.excludeMetrics["nv_remapped_rows_uncorrected"] ||
-		!device.excludeMetrics["nv_remapped_rows_pending"] ||
-		!device.excludeMetrics["nv_remapped_rows_failure"] {
+	if !device.filter.IsMetricExcluded("nv_remapped_rows_corrected") ||
+		!device.filter.IsMetricExcluded("nv_remapped_rows_uncorrected") ||
+		!device.filter.IsMetricExcluded("nv_remapped_rows_pending") ||
+		!device.filter.IsMetricExcluded("nv_remapped_rows_failure") {
 		// Get number of remapped rows. The number of rows reported will be based on the cause of the remapping.
 		// isPending indicates whether or not there are pending remappings.
 		// A reset will be required to actually remap the row.
@@ -743,19 +743,19 @@ func readRemappedRows(device *NvidiaCollectorDevice, output chan lp.CCMessage) e
 		// Note: On MIG-enabled GPUs with active instances, querying the number of remapped rows is not supported
 		corrected, uncorrected, pending, failure, ret := nvml.DeviceGetRemappedRows(device.device)
 		if ret == nvml.SUCCESS {
-			if !device.excludeMetrics["nv_remapped_rows_corrected"] {
+			if !device.filter.IsMetricExcluded("nv_remapped_rows_corrected") {
 				y, err := lp.NewMetric("nv_remapped_rows_corrected", device.tags, device.meta, float64(corrected), time.Now())
 				if err == nil {
 					output <- y
 				}
 			}
-			if !device.excludeMetrics["nv_remapped_rows_uncorrected"] {
-				y, err := lp.NewMetric("nv_remapped_rows_corrected", device.tags, device.meta, float64(uncorrected), time.Now())
+			if !device.filter.IsMetricExcluded("nv_remapped_rows_uncorrected") {
+				y, err := lp.NewMetric("nv_remapped_rows_uncorrected", device.tags, device.meta, float64(uncorrected), time.Now())
 				if err == nil {
 					output <- y
 				}
 			}
-			if !device.excludeMetrics["nv_remapped_rows_pending"] {
+			if !device.filter.IsMetricExcluded("nv_remapped_rows_pending") {
 				p := 0
 				if pending {
 					p = 1
@@ -765,7 +765,7 @@ func readRemappedRows(device *NvidiaCollectorDevice, output chan lp.CCMessage) e
 					output <- y
 				}
 			}
-			if !device.excludeMetrics["nv_remapped_rows_failure"] {
+			if !device.filter.IsMetricExcluded("nv_remapped_rows_failure") {
 				f := 0
 				if failure {
 					f = 1
@@ -781,7 +781,7 @@ func readRemappedRows(device *NvidiaCollectorDevice, output chan lp.CCMessage) e
 }
 
 func readProcessCounts(device *NvidiaCollectorDevice, output chan lp.CCMessage) error {
-	if !device.excludeMetrics["nv_compute_processes"] {
+	if !device.filter.IsMetricExcluded("nv_compute_processes") {
 		// Get information about processes with a compute context on a device
 		//
 		// For Fermi &tm; or newer fully supported devices.
@@ -810,7 +810,7 @@ func readProcessCounts(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_graphics_processes"] {
+	if !device.filter.IsMetricExcluded("nv_graphics_processes") {
 		// Get information about processes with a graphics context on a device
 		//
 		// For Kepler &tm; or newer fully supported devices.
@@ -839,7 +839,7 @@ func readProcessCounts(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	// if !device.excludeMetrics["nv_mps_compute_processes"] {
+	// if !device.filter.IsMetricExcluded("nv_mps_compute_processes") {
 	// 	// Get information about processes with a MPS compute context on a device
 	// 	//
 	// 	// For Volta &tm; or newer fully supported devices.
@@ -886,7 +886,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 	//
 	// For Kepler  or newer fully supported devices.
 
-	if !device.excludeMetrics["nv_violation_power"] {
+	if !device.filter.IsMetricExcluded("nv_violation_power") {
 		// How long did power violations cause the GPU to be below application clocks
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_POWER)
 		if ret == nvml.SUCCESS {
@@ -898,7 +898,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_violation_thermal"] {
+	if !device.filter.IsMetricExcluded("nv_violation_thermal") {
 		// How long did thermal violations cause the GPU to be below application clocks
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_THERMAL)
 		if ret == nvml.SUCCESS {
@@ -910,7 +910,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_violation_sync_boost"] {
+	if !device.filter.IsMetricExcluded("nv_violation_sync_boost") {
 		// How long did sync boost cause the GPU to be below application clocks
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_SYNC_BOOST)
 		if ret == nvml.SUCCESS {
@@ -922,7 +922,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_violation_board_limit"] {
+	if !device.filter.IsMetricExcluded("nv_violation_board_limit") {
 		// How long did the board limit cause the GPU to be below application clocks
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_BOARD_LIMIT)
 		if ret == nvml.SUCCESS {
@@ -934,7 +934,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_violation_low_util"] {
+	if !device.filter.IsMetricExcluded("nv_violation_low_util") {
 		// How long did low utilization cause the GPU to be below application clocks
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_LOW_UTILIZATION)
 		if ret == nvml.SUCCESS {
@@ -946,7 +946,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_violation_reliability"] {
+	if !device.filter.IsMetricExcluded("nv_violation_reliability") {
 		// How long did the board reliability limit cause the GPU to be below application clocks
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_RELIABILITY)
 		if ret == nvml.SUCCESS {
@@ -958,7 +958,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_violation_below_app_clock"] {
+	if !device.filter.IsMetricExcluded("nv_violation_below_app_clock") {
 		// Total time the GPU was held below application clocks by any limiter (all of above)
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_TOTAL_APP_CLOCKS)
 		if ret == nvml.SUCCESS {
@@ -970,7 +970,7 @@ func readViolationStats(device *NvidiaCollectorDevice, output chan lp.CCMessage)
 			}
 		}
 	}
-	if !device.excludeMetrics["nv_violation_below_base_clock"] {
+	if !device.filter.IsMetricExcluded("nv_violation_below_base_clock") {
 		// Total time the GPU was held below base clocks
 		violTime, ret = nvml.DeviceGetViolationStatus(device.device, nvml.PERF_POLICY_TOTAL_BASE_CLOCKS)
 		if ret == nvml.SUCCESS {
@@ -1002,7 +1002,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 		state, ret := nvml.DeviceGetNvLinkState(device.device, i)
 		if ret == nvml.SUCCESS {
 			if state == nvml.FEATURE_ENABLED {
-				if !device.excludeMetrics["nv_nvlink_crc_errors"] {
+				if !device.filter.IsMetricExcluded("nv_nvlink_crc_errors") {
 					// Data link receive data CRC error counter
 					count, ret := nvml.DeviceGetNvLinkErrorCounter(device.device, i, nvml.NVLINK_ERROR_DL_CRC_DATA)
 					aggregate_crc_errors += count
@@ -1015,7 +1015,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 						}
 					}
 				}
-				if !device.excludeMetrics["nv_nvlink_ecc_errors"] {
+				if !device.filter.IsMetricExcluded("nv_nvlink_ecc_errors") {
 					// Data link receive data ECC error counter
 					count, ret := nvml.DeviceGetNvLinkErrorCounter(device.device, i, nvml.NVLINK_ERROR_DL_ECC_DATA)
 					aggregate_ecc_errors += count
@@ -1028,7 +1028,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 						}
 					}
 				}
-				if !device.excludeMetrics["nv_nvlink_replay_errors"] {
+				if !device.filter.IsMetricExcluded("nv_nvlink_replay_errors") {
 					// Data link transmit replay error counter
 					count, ret := nvml.DeviceGetNvLinkErrorCounter(device.device, i, nvml.NVLINK_ERROR_DL_REPLAY)
 					aggregate_replay_errors += count
@@ -1041,7 +1041,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 						}
 					}
 				}
-				if !device.excludeMetrics["nv_nvlink_recovery_errors"] {
+				if !device.filter.IsMetricExcluded("nv_nvlink_recovery_errors") {
 					// Data link transmit recovery error counter
 					count, ret := nvml.DeviceGetNvLinkErrorCounter(device.device, i, nvml.NVLINK_ERROR_DL_RECOVERY)
 					aggregate_recovery_errors += count
@@ -1054,7 +1054,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 						}
 					}
 				}
-				if !device.excludeMetrics["nv_nvlink_crc_flit_errors"] {
+				if !device.filter.IsMetricExcluded("nv_nvlink_crc_flit_errors") {
 					// Data link receive flow control digit CRC error counter
 					count, ret := nvml.DeviceGetNvLinkErrorCounter(device.device, i, nvml.NVLINK_ERROR_DL_CRC_FLIT)
 					aggregate_crc_flit_errors += count
@@ -1072,7 +1072,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 	}
 
 	// Export aggegated values
-	if !device.excludeMetrics["nv_nvlink_crc_errors"] {
+	if !device.filter.IsMetricExcluded("nv_nvlink_crc_errors") {
 		// Data link receive data CRC error counter
 		y, err := lp.NewMetric("nv_nvlink_crc_errors_sum", device.tags, device.meta, aggregate_crc_errors, time.Now())
 		if err == nil {
@@ -1080,7 +1080,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 			output <- y
 		}
 	}
-	if !device.excludeMetrics["nv_nvlink_ecc_errors"] {
+	if !device.filter.IsMetricExcluded("nv_nvlink_ecc_errors") {
 		// Data link receive data ECC error counter
 		y, err := lp.NewMetric("nv_nvlink_ecc_errors_sum", device.tags, device.meta, aggregate_ecc_errors, time.Now())
 		if err == nil {
@@ -1088,7 +1088,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 			output <- y
 		}
 	}
-	if !device.excludeMetrics["nv_nvlink_replay_errors"] {
+	if !device.filter.IsMetricExcluded("nv_nvlink_replay_errors") {
 		// Data link transmit replay error counter
 		y, err := lp.NewMetric("nv_nvlink_replay_errors_sum", device.tags, device.meta, aggregate_replay_errors, time.Now())
 		if err == nil {
@@ -1096,7 +1096,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 			output <- y
 		}
 	}
-	if !device.excludeMetrics["nv_nvlink_recovery_errors"] {
+	if !device.filter.IsMetricExcluded("nv_nvlink_recovery_errors") {
 		// Data link transmit recovery error counter
 		y, err := lp.NewMetric("nv_nvlink_recovery_errors_sum", device.tags, device.meta, aggregate_recovery_errors, time.Now())
 		if err == nil {
@@ -1104,7 +1104,7 @@ func readNVLinkStats(device *NvidiaCollectorDevice, output chan lp.CCMessage) er
 			output <- y
 		}
 	}
-	if !device.excludeMetrics["nv_nvlink_crc_flit_errors"] {
+	if !device.filter.IsMetricExcluded("nv_nvlink_crc_flit_errors") {
 		// Data link receive flow control digit CRC error counter
 		y, err := lp.NewMetric("nv_nvlink_crc_flit_errors_sum", device.tags, device.meta, aggregate_crc_flit_errors, time.Now())
 		if err == nil {
@@ -1252,16 +1252,11 @@ func (m *NvidiaCollector) Read(interval time.Duration, output chan lp.CCMessage)
 					continue
 				}
 
-				excludeMetrics := make(map[string]bool)
-				for _, metric := range m.config.ExcludeMetrics {
-					excludeMetrics[metric] = true
-				}
-
 				migDevice := NvidiaCollectorDevice{
-					device:         mdev,
-					tags:           map[string]string{},
-					meta:           map[string]string{},
-					excludeMetrics: excludeMetrics,
+					device: mdev,
+					tags:   map[string]string{},
+					meta:   map[string]string{},
+					filter: &m.config.MetricFilter,
 				}
 				maps.Copy(migDevice.tags, m.gpus[i].tags)
 				migDevice.tags["stype"] = "mig"
@@ -1301,6 +1296,61 @@ func (m *NvidiaCollector) Read(interval time.Duration, output chan lp.CCMessage)
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *NvidiaCollector) Catalog() []mc.Metric {
+	types := []string{"accelerator"}
+	return []mc.Metric{
+		{Name: "nv_util", Unit: "%", Types: types, Kind: mc.Gauge, Description: "GPU utilization"},
+		{Name: "nv_mem_util", Unit: "%", Types: types, Kind: mc.Gauge, Description: "Memory utilization"},
+		{Name: "nv_fb_mem_total", Unit: "MByte", Types: types, Kind: mc.Gauge, Description: "Total frame buffer memory"},
+		{Name: "nv_fb_mem_used", Unit: "MByte", Types: types, Kind: mc.Gauge, Description: "Used frame buffer memory"},
+		{Name: "nv_fb_mem_reserved", Unit: "MByte", Types: types, Kind: mc.Gauge, Description: "Frame buffer memory reserved by the driver"},
+		{Name: "nv_bar1_mem_total", Unit: "MByte", Types: types, Kind: mc.Gauge, Description: "Total BAR1 memory"},
+		{Name: "nv_bar1_mem_used", Unit: "MByte", Types: types, Kind: mc.Gauge, Description: "Used BAR1 memory"},
+		{Name: "nv_temp", Unit: "degC", Types: types, Kind: mc.Gauge, Description: "GPU temperature"},
+		{Name: "nv_fan", Unit: "%", Types: types, Kind: mc.Gauge, Description: "Fan speed"},
+		{Name: "nv_ecc_mode", Types: types, Kind: mc.Text, Description: "ECC mode: ON, OFF, UNKNOWN or N/A"},
+		{Name: "nv_perf_state", Types: types, Kind: mc.Text, Description: "Performance state P0 to P15"},
+		{Name: "nv_power_usage", Unit: "watts", Types: types, Kind: mc.Gauge, Description: "Power usage"},
+		{Name: "nv_energy", Unit: "Joules", Types: types, Kind: mc.Gauge, Description: "Energy consumed since the last read"},
+		{Name: "nv_energy_abs", Unit: "Joules", Types: types, Kind: mc.Counter, Description: "Energy consumed since the driver was loaded"},
+		{Name: "nv_average_power", Unit: "watts", Types: types, Kind: mc.Gauge, Description: "Average power since the last read"},
+		{Name: "nv_graphics_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "Graphics clock frequency"},
+		{Name: "nv_sm_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "SM clock frequency"},
+		{Name: "nv_mem_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "Memory clock frequency"},
+		{Name: "nv_video_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "Video clock frequency"},
+		{Name: "nv_max_graphics_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "Maximal graphics clock frequency"},
+		{Name: "nv_max_sm_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "Maximal SM clock frequency"},
+		{Name: "nv_max_mem_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "Maximal memory clock frequency"},
+		{Name: "nv_max_video_clock", Unit: "MHz", Types: types, Kind: mc.Gauge, Description: "Maximal video clock frequency"},
+		{Name: "nv_ecc_uncorrected_error", Types: types, Kind: mc.Counter, Description: "Uncorrected (double bit) ECC errors"},
+		{Name: "nv_ecc_corrected_error", Types: types, Kind: mc.Counter, Description: "Corrected (single bit) ECC errors"},
+		{Name: "nv_power_max_limit", Unit: "watts", Types: types, Kind: mc.Gauge, Description: "Power management limit"},
+		{Name: "nv_encoder_util", Unit: "%", Types: types, Kind: mc.Gauge, Description: "Encoder utilization"},
+		{Name: "nv_decoder_util", Unit: "%", Types: types, Kind: mc.Gauge, Description: "Decoder utilization"},
+		{Name: "nv_remapped_rows_corrected", Types: types, Kind: mc.Gauge, Description: "Rows remapped due to correctable errors"},
+		{Name: "nv_remapped_rows_uncorrected", Types: types, Kind: mc.Gauge, Description: "Rows remapped due to uncorrectable errors"},
+		{Name: "nv_remapped_rows_pending", Types: types, Kind: mc.Gauge, Description: "Whether a row remapping is pending"},
+		{Name: "nv_remapped_rows_failure", Types: types, Kind: mc.Gauge, Description: "Whether a row remapping failed"},
+		{Name: "nv_compute_processes", Types: types, Kind: mc.Gauge, Description: "Number of compute processes"},
+		{Name: "nv_graphics_processes", Types: types, Kind: mc.Gauge, Description: "Number of graphics processes"},
+		{Name: "nv_violation_power", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time throttled by the power limit"},
+		{Name: "nv_violation_thermal", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time throttled by the thermal limit"},
+		{Name: "nv_violation_sync_boost", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time throttled by sync boost"},
+		{Name: "nv_violation_board_limit", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time throttled by the board limit"},
+		{Name: "nv_violation_low_util", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time throttled due to low utilization"},
+		{Name: "nv_violation_reliability", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time throttled by board reliability limits"},
+		{Name: "nv_violation_below_app_clock", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time running below the application clocks"},
+		{Name: "nv_violation_below_base_clock", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Time running below the base clocks"},
+		{Name: "nv_nvlink_crc_errors", Types: types, Kind: mc.Counter, Description: "NVLink CRC data errors per link"},
+		{Name: "nv_nvlink_ecc_errors", Types: types, Kind: mc.Counter, Description: "NVLink ECC data errors per link"},
+		{Name: "nv_nvlink_replay_errors", Types: types, Kind: mc.Counter, Description: "NVLink replay errors per link"},
+		{Name: "nv_nvlink_recovery_errors", Types: types, Kind: mc.Counter, Description: "NVLink recovery errors per link"},
+		{Name: "nv_nvlink_crc_flit_errors", Types: types, Kind: mc.Counter, Description: "NVLink CRC flow control digit errors per link"},
+		{Name: "nv_nvlink_*_errors_sum", Types: types, Kind: mc.Counter, Description: "Sum of the corresponding NVLink errors of all links"},
+	}
+}
+
 func (m *NvidiaCollector) Close() {
 	if m.init {
 		if ret := nvml.Shutdown(); ret != nvml.SUCCESS {
@@ -0,0 +1,324 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+const PSI_BASE_PATH = `/proc/pressure`
+
+// Resources with pressure stall information
+var psiResources = []string{"cpu", "memory", "io", "irq"}
+
+type PSICollectorConfig struct {
+	Resources  []string `json:"resources,omitempty"`   // Resources to read, default all available
+	SlurmJobs  bool     `json:"slurm_jobs,omitempty"`  // Read the pressure of the Slurm job cgroups
+	CgroupBase string   `json:"cgroup_base,omitempty"` // Base directory of the Slurm job cgroups
+	MetricFilter
+}
+
+// psiLine is a line of a pressure file like
+// 'some avg10=0.00 avg60=0.00 avg300=0.00 total=0'
+type psiLine struct {
+	kind     string             // "some" or "full"
+	avg      map[string]float64 // Averages over 10, 60 and 300 seconds in percent
+	total    int64              // Total stall time in microseconds
+	hasTotal bool               // Line contains the total stall time
+}
+
+// PSICollector reads the pressure stall information (PSI) of the node and
+// optionally of the Slurm job cgroups.
+// See: https://docs.kernel.org/accounting/psi.html
+type PSICollector struct {
+	BaseCollector
+	config        PSICollectorConfig
+	resources     []string                    // Resources with a pressure file
+	cgroupBase    string                      // Base directory of the Slurm job cgroups
+	tags          map[string]string           // Tags of the node metrics
+	lastTotals    map[string]int64            // Last total stall times of the node by metric name
+	jobTotals     map[string]map[string]int64 // Last total stall times of the jobs by job directory and metric name
+	lastTimestamp time.Time
+}
+
+func (m *PSICollector) Init(config json.RawMessage) error {
+	m.name = "PSICollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "Pressure",
+		"unit":   "Percent",
+	}
+	m.tags = map[string]string{
+		"type": "node",
+	}
+
+	// Read configuration
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+	m.cgroupBase = defaultCgroupBase
+	if len(m.config.CgroupBase) > 0 {
+		m.cgroupBase = m.config.CgroupBase
+	}
+
+	// Check the pressure files of the resources
+	m.resources = make([]string, 0, len(psiResources))
+	if len(m.config.Resources) > 0 {
+		for _, r := range m.config.Resources {
+			if !slices.Contains(psiResources, r) {
+				return fmt.Errorf("%s Init(): unknown resource '%s'", m.name, r)
+			}
+			if _, err := hostfs.Stat(filepath.Join(PSI_BASE_PATH, r)); err != nil {
+				return fmt.Errorf("%s Init(): no pressure stall information for resource '%s': %w", m.name, r, err)
+			}
+			m.resources = append(m.resources, r)
+		}
+	} else {
+		for _, r := range psiResources {
+			if _, err := hostfs.Stat(filepath.Join(PSI_BASE_PATH, r)); err == nil {
+				m.resources = append(m.resources, r)
+			}
+		}
+	}
+	if len(m.resources) == 0 {
+		return fmt.Errorf("%s Init(): no pressure stall information found in '%s'", m.name, PSI_BASE_PATH)
+	}
+	if m.config.SlurmJobs {
+		if _, err := hostfs.Stat(m.cgroupBase); err != nil {
+			return fmt.Errorf("%s Init(): Slurm cgroup base directory: %w", m.name, err)
+		}
+	}
+
+	m.lastTotals = make(map[string]int64)
+	m.jobTotals = make(map[string]map[string]int64)
+	m.lastTimestamp = time.Time{}
+	m.init = true
+	return nil
+}
+
+// parsePressure parses the content of a pressure file
+func parsePressure(data []byte) ([]psiLine, error) {
+	lines := make([]psiLine, 0, 2)
+	for line := range strings.SplitSeq(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		l := psiLine{
+			kind: fields[0],
+			avg:  make(map[string]float64),
+		}
+		for _, f := range fields[1:] {
+			key, value, ok := strings.Cut(f, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid field '%s'", f)
+			}
+			if key == "total" {
+				t, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("failed to convert total='%s' to int64: %w", value, err)
+				}
+				l.total = t
+				l.hasTotal = true
+				continue
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s='%s' to float64: %w", key, value, err)
+			}
+			l.avg[key] = v
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// sendPressure sends the averages and the stall time of a pressure file for
+// all tag sets. The stall time is the share of the time since the last read
+// in which tasks were stalled.
+func (m *PSICollector) sendPressure(
+	prefix string,
+	lines []psiLine,
+	tagSets []map[string]string,
+	last map[string]int64,
+	totals map[string]int64,
+	timeDiff float64,
+	now time.Time,
+	output chan lp.CCMessage,
+) {
+	for _, l := range lines {
+		base := prefix + "_" + l.kind
+		for _, avg := range []string{"avg10", "avg60", "avg300"} {
+			value, ok := l.avg[avg]
+			if !ok || m.config.IsMetricExcluded(base+"_"+avg) {
+				continue
+			}
+			for _, tags := range tagSets {
+				if y, err := lp.NewMetric(base+"_"+avg, tags, m.meta, value, now); err == nil {
+					output <- y
+				}
+			}
+		}
+		if !l.hasTotal {
+			continue
+		}
+		name := base + "_stall"
+		totals[name] = l.total
+		prev, ok := last[name]
+		if !ok || l.total < prev || timeDiff <= 0 || m.config.IsMetricExcluded(name) {
+			continue
+		}
+		value := float64(l.total-prev) / (timeDiff * 1e4)
+		for _, tags := range tagSets {
+			if y, err := lp.NewMetric(name, tags, m.meta, value, now); err == nil {
+				output <- y
+			}
+		}
+	}
+}
+
+// readJobs sends the pressure of the Slurm jobs for the hardware threads of
+// the jobs
+func (m *PSICollector) readJobs(timeDiff float64, now time.Time, output chan lp.CCMessage) {
+	jobDirs, err := findSlurmJobDirs(m.cgroupBase)
+	if err != nil {
+		cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to read Slurm job directories: %v", err))
+		return
+	}
+	jobTotals := make(map[string]map[string]int64, len(jobDirs))
+	for _, jobDir := range jobDirs {
+		// Jobs may finish while reading their files
+		cpuset, err := hostfs.ReadFile(filepath.Join(jobDir, "cpuset.cpus.effective"))
+		if err != nil {
+			continue
+		}
+		cpus, err := ParseCPUs(strings.TrimSpace(string(cpuset)))
+		if err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to parse cpuset of %s: %v", jobDir, err))
+			continue
+		}
+		tagSets := make([]map[string]string, 0, len(cpus))
+		for _, cpu := range cpus {
+			tagSets = append(tagSets, map[string]string{"type": "hwthread", "type-id": strconv.Itoa(cpu)})
+		}
+		last := m.jobTotals[jobDir]
+		totals := make(map[string]int64)
+		for _, r := range m.resources {
+			data, err := hostfs.ReadFile(filepath.Join(jobDir, r+".pressure"))
+			if err != nil {
+				continue
+			}
+			lines, err := parsePressure(data)
+			if err != nil {
+				cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to parse %s pressure of %s: %v", r, jobDir, err))
+				continue
+			}
+			m.sendPressure("job_psi_"+r, lines, tagSets, last, totals, timeDiff, now, output)
+		}
+		jobTotals[jobDir] = totals
+	}
+	// Forget finished jobs
+	m.jobTotals = jobTotals
+}
+
+func (m *PSICollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+	now := hostfs.Now()
+	timeDiff := now.Sub(m.lastTimestamp).Seconds()
+	m.lastTimestamp = now
+
+	tagSets := []map[string]string{m.tags}
+	for _, r := range m.resources {
+		file := filepath.Join(PSI_BASE_PATH, r)
+		data, err := hostfs.ReadFile(file)
+		if err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to read file '%s': %v", file, err))
+			continue
+		}
+		lines, err := parsePressure(data)
+		if err != nil {
+			cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to parse file '%s': %v", file, err))
+			continue
+		}
+		m.sendPressure("psi_"+r, lines, tagSets, m.lastTotals, m.lastTotals, timeDiff, now, output)
+	}
+
+	if m.config.SlurmJobs {
+		m.readJobs(timeDiff, now, output)
+	}
+}
+
+// Catalog returns the metrics the collector can send
+func (m *PSICollector) Catalog() []mc.Metric {
+	resourceNames := map[string]string{"cpu": "CPU", "memory": "memory", "io": "I/O", "irq": "IRQ"}
+	kindNames := map[string]string{"some": "some tasks were", "full": "all non-idle tasks were"}
+	metrics := make([]mc.Metric, 0)
+	for _, scope := range []struct {
+		prefix  string
+		types   []string
+		options []string
+		desc    string
+	}{
+		{"psi", []string{"node"}, nil, ""},
+		{"job_psi", []string{"hwthread"}, []string{"slurm_jobs"}, " in the Slurm job running on the hardware thread"},
+	} {
+		for _, r := range psiResources {
+			if r == "irq" && scope.prefix == "job_psi" {
+				continue
+			}
+			kinds := []string{"some", "full"}
+			if r == "irq" {
+				kinds = []string{"full"}
+			}
+			for _, k := range kinds {
+				base := fmt.Sprintf("%s_%s_%s", scope.prefix, r, k)
+				desc := fmt.Sprintf("Share of time in which %s stalled on %s%s", kindNames[k], resourceNames[r], scope.desc)
+				for _, avg := range []string{"10", "60", "300"} {
+					metrics = append(metrics, mc.Metric{
+						Name: base + "_avg" + avg, Unit: "Percent", Types: scope.types, Kind: mc.Gauge,
+						Description: fmt.Sprintf("%s, averaged over %s seconds", desc, avg), Options: scope.options,
+					})
+				}
+				metrics = append(metrics, mc.Metric{
+					Name: base + "_stall", Unit: "Percent", Types: scope.types, Kind: mc.Gauge,
+					Description: desc + " since the last read", Options: scope.options,
+				})
+			}
+		}
+	}
+	return metrics
+}
+
+func (m *PSICollector) Close() {
+	m.init = false
+}
@@ -11,7 +11,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -19,6 +18,8 @@ import (
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // running average power limit (RAPL) monitoring attributes for a zone
@@ -34,15 +35,19 @@ type RAPLZoneInfo struct {
 }
 
 type RAPLCollector struct {
-	metricCollector
+	BaseCollector
 
 	config struct {
 		// Exclude IDs for RAPL zones, e.g.
 		// * 0 for zone 0
 		// * 0:1 for zone 0 subzone 1
+		// Alias of exclude_devices
 		ExcludeByID []string `json:"exclude_device_by_id,omitempty"`
 		// Exclude names for RAPL zones, e.g. psys, dram, core, uncore, package-0
+		// Alias of exclude_devices
 		ExcludeByName []string `json:"exclude_device_by_name,omitempty"`
+		// RAPL zones are identified by ID and name
+		MetricFilter
 	}
 	RAPLZoneInfo []RAPLZoneInfo
 	meta         map[string]string // default meta information
@@ -74,19 +79,11 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
-
-	// Configure excluded RAPL zones
-	isIDExcluded := make(map[string]bool)
-	if m.config.ExcludeByID != nil {
-		for _, ID := range m.config.ExcludeByID {
-			isIDExcluded[ID] = true
-		}
-	}
-	isNameExcluded := make(map[string]bool)
-	if m.config.ExcludeByName != nil {
-		for _, name := range m.config.ExcludeByName {
-			isNameExcluded[name] = true
-		}
+	// exclude_device_by_id and exclude_device_by_name are aliases of exclude_devices
+	m.config.ExcludeDevices = append(m.config.ExcludeDevices, m.config.ExcludeByID...)
+	m.config.ExcludeDevices = append(m.config.ExcludeDevices, m.config.ExcludeByName...)
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
 	}
 
 	// readZoneInfo reads RAPL monitoring attributes for a zone given by zonePath
@@ -103,7 +100,7 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 	) {
 		// zones name e.g. psys, dram, core, uncore, package-0
 		foundName := false
-		if v, err := os.ReadFile(
+		if v, err := hostfs.ReadFile(
 			filepath.Join(zonePath, "name")); err == nil {
 			foundName = true
 			z.name = strings.TrimSpace(string(v))
@@ -114,9 +111,9 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 
 		// current reading of the energy counter in micro joules
 		foundEnergy := false
-		if v, err := os.ReadFile(z.energyFilepath); err == nil {
+		if v, err := hostfs.ReadFile(z.energyFilepath); err == nil {
 			// timestamp when energy counter was read
-			z.energyTimestamp = time.Now()
+			z.energyTimestamp = hostfs.Now()
 			if i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64); err == nil {
 				foundEnergy = true
 				z.energy = i
@@ -125,7 +122,7 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 
 		// Range of the above energy counter in micro-joules
 		foundMaxEnergyRange := false
-		if v, err := os.ReadFile(
+		if v, err := hostfs.ReadFile(
 			filepath.Join(zonePath, "max_energy_range_uj")); err == nil {
 			if i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64); err == nil {
 				foundMaxEnergyRange = true
@@ -145,7 +142,7 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 
 	// Find all RAPL zones
 	zonePrefix := filepath.Join(controlTypePath, controlType+":")
-	zonesPath, err := filepath.Glob(zonePrefix + "*")
+	zonesPath, err := hostfs.Glob(zonePrefix + "*")
 	if err != nil || zonesPath == nil {
 		return fmt.Errorf("unable to find any zones under %s", controlTypePath)
 	}
@@ -154,8 +151,7 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 		zoneID := strings.TrimPrefix(zonePath, zonePrefix)
 		z := readZoneInfo(zonePath)
 		if z.ok &&
-			!isIDExcluded[zoneID] &&
-			!isNameExcluded[z.name] {
+			!m.config.IsDeviceExcluded(zoneID, z.name) {
 
 			// Add RAPL monitoring attributes for a zone
 			m.RAPLZoneInfo = append(
@@ -174,7 +170,7 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 
 		// find all sub zones for the given zone
 		subZonePrefix := filepath.Join(zonePath, controlType+":"+zoneID+":")
-		subZonesPath, err := filepath.Glob(subZonePrefix + "*")
+		subZonesPath, err := hostfs.Glob(subZonePrefix + "*")
 		if err != nil || subZonesPath == nil {
 			continue
 		}
@@ -184,8 +180,7 @@ func (m *RAPLCollector) Init(config json.RawMessage) error {
 			sz := readZoneInfo(subZonePath)
 			if len(zoneID) > 0 && len(z.name) > 0 &&
 				sz.ok &&
-				!isIDExcluded[zoneID+":"+subZoneID] &&
-				!isNameExcluded[sz.name] {
+				!m.config.IsDeviceExcluded(zoneID+":"+subZoneID, sz.name) {
 				m.RAPLZoneInfo = append(
 					m.RAPLZoneInfo,
 					RAPLZoneInfo{
@@ -225,8 +220,8 @@ func (m *RAPLCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 		p := &m.RAPLZoneInfo[i]
 
 		// Read current value of the energy counter in micro joules
-		if v, err := os.ReadFile(p.energyFilepath); err == nil {
-			energyTimestamp := time.Now()
+		if v, err := hostfs.ReadFile(p.energyFilepath); err == nil {
+			energyTimestamp := hostfs.Now()
 			if i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64); err == nil {
 				energy := i
 
@@ -243,7 +238,7 @@ func (m *RAPLCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 				averagePower := float64(energyDiff) / float64(timeDiff.Microseconds())
 
 				y, err := lp.NewMetric("rapl_average_power", p.tags, m.meta, averagePower, energyTimestamp)
-				if err == nil {
+				if err == nil && !m.config.IsMetricExcluded("rapl_average_power") {
 					output <- y
 				}
 
@@ -255,6 +250,13 @@ func (m *RAPLCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *RAPLCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "rapl_average_power", Unit: "Watt", Kind: mc.Gauge, Description: "Average power of a RAPL zone since the last read"},
+	}
+}
+
 // Close closes running average power limit (RAPL) metric collector
 func (m *RAPLCollector) Close() {
 	// Unset flag
@@ -11,33 +11,33 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"slices"
 	"strconv"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	"github.com/ClusterCockpit/go-rocm-smi/pkg/rocm_smi"
 )
 
 type RocmSmiCollectorConfig struct {
-	ExcludeMetrics     []string `json:"exclude_metrics,omitempty"`
-	ExcludeDevices     []string `json:"exclude_devices,omitempty"`
-	AddPciInfoTag      bool     `json:"add_pci_info_tag,omitempty"`
-	UsePciInfoAsTypeId bool     `json:"use_pci_info_as_type_id,omitempty"`
-	AddSerialMeta      bool     `json:"add_serial_meta,omitempty"`
+	AddPciInfoTag      bool `json:"add_pci_info_tag,omitempty"`
+	UsePciInfoAsTypeId bool `json:"use_pci_info_as_type_id,omitempty"`
+	AddSerialMeta      bool `json:"add_serial_meta,omitempty"`
+	AddAffinityTags    bool `json:"add_affinity_tags,omitempty"`
+	MetricFilter
 }
 
 type RocmSmiCollectorDevice struct {
-	device         rocm_smi.DeviceHandle
-	index          int
-	tags           map[string]string // default tags
-	meta           map[string]string // default meta information
-	excludeMetrics map[string]bool   // copy of exclude metrics from config
+	device rocm_smi.DeviceHandle
+	index  int
+	tags   map[string]string // default tags
+	meta   map[string]string // default meta information
+	filter *MetricFilter     // metric filter from config
 }
 
 type RocmSmiCollector struct {
-	metricCollector
+	BaseCollector
 
 	config  RocmSmiCollectorConfig // the configuration structure
 	devices []RocmSmiCollectorDevice
@@ -65,6 +65,9 @@ func (m *RocmSmiCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	ret := rocm_smi.Init()
 	if ret != rocm_smi.STATUS_SUCCESS {
@@ -80,9 +83,6 @@ func (m *RocmSmiCollector) Init(config json.RawMessage) error {
 
 	for i := range numDevs {
 		str_i := strconv.Itoa(i)
-		if slices.Contains(m.config.ExcludeDevices, str_i) {
-			continue
-		}
 		device, ret := rocm_smi.DeviceGetHandleByIndex(i)
 		if ret != rocm_smi.STATUS_SUCCESS {
 			return fmt.Errorf("%s Init(): failed to get get handle for GPU %d", m.name, i)
@@ -100,7 +100,7 @@ func (m *RocmSmiCollector) Init(config json.RawMessage) error {
 			pciInfo.Device,
 			pciInfo.Function)
 
-		if slices.Contains(m.config.ExcludeDevices, pciId) {
+		if m.config.IsDeviceExcluded(str_i, pciId) {
 			continue
 		}
 
@@ -121,6 +121,11 @@ func (m *RocmSmiCollector) Init(config json.RawMessage) error {
 			dev.tags["pci_identifier"] = pciId
 		}
 
+		// Add NUMA domain and socket of the GPU
+		if m.config.AddAffinityTags {
+			addAffinityTags(dev.tags, pciAffinityTags(pciId))
+		}
+
 		if m.config.AddSerialMeta {
 			serial, ret := rocm_smi.DeviceGetSerialNumber(device)
 			if ret != rocm_smi.STATUS_SUCCESS {
@@ -129,11 +134,8 @@ func (m *RocmSmiCollector) Init(config json.RawMessage) error {
 				dev.meta["serial"] = serial
 			}
 		}
-		// Add excluded metrics
-		dev.excludeMetrics = map[string]bool{}
-		for _, e := range m.config.ExcludeMetrics {
-			dev.excludeMetrics[e] = true
-		}
+		// Add metric filter
+		dev.filter = &m.config.MetricFilter
 		dev.index = i
 		m.devices = append(m.devices, dev)
 	}
@@ -156,109 +158,109 @@ func (m *RocmSmiCollector) Read(interval time.Duration, output chan lp.CCMessage
 			continue
 		}
 
-		if !dev.excludeMetrics["rocm_gfx_util"] {
+		if !dev.filter.IsMetricExcluded("rocm_gfx_util") {
 			value := metrics.Average_gfx_activity
 			if y, err := lp.NewMetric("rocm_gfx_util", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_umc_util"] {
+		if !dev.filter.IsMetricExcluded("rocm_umc_util") {
 			value := metrics.Average_umc_activity
 			if y, err := lp.NewMetric("rocm_umc_util", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_mm_util"] {
+		if !dev.filter.IsMetricExcluded("rocm_mm_util") {
 			value := metrics.Average_mm_activity
 			if y, err := lp.NewMetric("rocm_mm_util", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_avg_power"] {
+		if !dev.filter.IsMetricExcluded("rocm_avg_power") {
 			value := metrics.Average_socket_power
 			if y, err := lp.NewMetric("rocm_avg_power", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_temp_mem"] {
+		if !dev.filter.IsMetricExcluded("rocm_temp_mem") {
 			value := metrics.Temperature_mem
 			if y, err := lp.NewMetric("rocm_temp_mem", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_temp_hotspot"] {
+		if !dev.filter.IsMetricExcluded("rocm_temp_hotspot") {
 			value := metrics.Temperature_hotspot
 			if y, err := lp.NewMetric("rocm_temp_hotspot", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_temp_edge"] {
+		if !dev.filter.IsMetricExcluded("rocm_temp_edge") {
 			value := metrics.Temperature_edge
 			if y, err := lp.NewMetric("rocm_temp_edge", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_temp_vrgfx"] {
+		if !dev.filter.IsMetricExcluded("rocm_temp_vrgfx") {
 			value := metrics.Temperature_vrgfx
 			if y, err := lp.NewMetric("rocm_temp_vrgfx", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_temp_vrsoc"] {
+		if !dev.filter.IsMetricExcluded("rocm_temp_vrsoc") {
 			value := metrics.Temperature_vrsoc
 			if y, err := lp.NewMetric("rocm_temp_vrsoc", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_temp_vrmem"] {
+		if !dev.filter.IsMetricExcluded("rocm_temp_vrmem") {
 			value := metrics.Temperature_vrmem
 			if y, err := lp.NewMetric("rocm_temp_vrmem", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_gfx_clock"] {
+		if !dev.filter.IsMetricExcluded("rocm_gfx_clock") {
 			value := metrics.Average_gfxclk_frequency
 			if y, err := lp.NewMetric("rocm_gfx_clock", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_soc_clock"] {
+		if !dev.filter.IsMetricExcluded("rocm_soc_clock") {
 			value := metrics.Average_socclk_frequency
 			if y, err := lp.NewMetric("rocm_soc_clock", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_u_clock"] {
+		if !dev.filter.IsMetricExcluded("rocm_u_clock") {
 			value := metrics.Average_uclk_frequency
 			if y, err := lp.NewMetric("rocm_u_clock", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_v0_clock"] {
+		if !dev.filter.IsMetricExcluded("rocm_v0_clock") {
 			value := metrics.Average_vclk0_frequency
 			if y, err := lp.NewMetric("rocm_v0_clock", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_v1_clock"] {
+		if !dev.filter.IsMetricExcluded("rocm_v1_clock") {
 			value := metrics.Average_vclk1_frequency
 			if y, err := lp.NewMetric("rocm_v1_clock", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_d0_clock"] {
+		if !dev.filter.IsMetricExcluded("rocm_d0_clock") {
 			value := metrics.Average_dclk0_frequency
 			if y, err := lp.NewMetric("rocm_d0_clock", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_d1_clock"] {
+		if !dev.filter.IsMetricExcluded("rocm_d1_clock") {
 			value := metrics.Average_dclk1_frequency
 			if y, err := lp.NewMetric("rocm_d1_clock", dev.tags, dev.meta, value, timestamp); err == nil {
 				output <- y
 			}
 		}
-		if !dev.excludeMetrics["rocm_temp_hbm"] {
+		if !dev.filter.IsMetricExcluded("rocm_temp_hbm") {
 			for i := range rocm_smi.NUM_HBM_INSTANCES {
 				value := metrics.Temperature_hbm[i]
 				if y, err := lp.NewMetric("rocm_temp_hbm", dev.tags, dev.meta, value, timestamp); err == nil {
@@ -271,6 +273,31 @@ func (m *RocmSmiCollector) Read(interval time.Duration, output chan lp.CCMessage
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *RocmSmiCollector) Catalog() []mc.Metric {
+	types := []string{"accelerator"}
+	return []mc.Metric{
+		{Name: "rocm_gfx_util", Types: types, Kind: mc.Gauge, Description: "Graphics engine utilization in percent"},
+		{Name: "rocm_umc_util", Types: types, Kind: mc.Gauge, Description: "Memory controller utilization in percent"},
+		{Name: "rocm_mm_util", Types: types, Kind: mc.Gauge, Description: "Multimedia engine utilization in percent"},
+		{Name: "rocm_avg_power", Types: types, Kind: mc.Gauge, Description: "Average socket power in Watt"},
+		{Name: "rocm_temp_mem", Types: types, Kind: mc.Gauge, Description: "Memory temperature in degree Celsius"},
+		{Name: "rocm_temp_hotspot", Types: types, Kind: mc.Gauge, Description: "Hotspot temperature in degree Celsius"},
+		{Name: "rocm_temp_edge", Types: types, Kind: mc.Gauge, Description: "Edge temperature in degree Celsius"},
+		{Name: "rocm_temp_vrgfx", Types: types, Kind: mc.Gauge, Description: "Graphics voltage regulator temperature in degree Celsius"},
+		{Name: "rocm_temp_vrsoc", Types: types, Kind: mc.Gauge, Description: "SoC voltage regulator temperature in degree Celsius"},
+		{Name: "rocm_temp_vrmem", Types: types, Kind: mc.Gauge, Description: "Memory voltage regulator temperature in degree Celsius"},
+		{Name: "rocm_temp_hbm", Types: types, Kind: mc.Gauge, Description: "HBM temperature per slice in degree Celsius"},
+		{Name: "rocm_gfx_clock", Types: types, Kind: mc.Gauge, Description: "Average graphics clock frequency in MHz"},
+		{Name: "rocm_soc_clock", Types: types, Kind: mc.Gauge, Description: "Average SoC clock frequency in MHz"},
+		{Name: "rocm_u_clock", Types: types, Kind: mc.Gauge, Description: "Average memory controller clock frequency in MHz"},
+		{Name: "rocm_v0_clock", Types: types, Kind: mc.Gauge, Description: "Average VCLK0 clock frequency in MHz"},
+		{Name: "rocm_v1_clock", Types: types, Kind: mc.Gauge, Description: "Average VCLK1 clock frequency in MHz"},
+		{Name: "rocm_d0_clock", Types: types, Kind: mc.Gauge, Description: "Average DCLK0 clock frequency in MHz"},
+		{Name: "rocm_d1_clock", Types: types, Kind: mc.Gauge, Description: "Average DCLK1 clock frequency in MHz"},
+	}
+}
+
 // Close metric collector: close network connection, close files, close libraries, ...
 // Called once by the collector manager
 func (m *RocmSmiCollector) Close() {
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // These are the fields we read from the JSON configuration
@@ -22,9 +23,9 @@ type SampleCollectorConfig struct {
 }
 
 // This contains all variables we need during execution and the variables
-// defined by metricCollector (name, init, ...)
+// defined by BaseCollector (name, init, ...)
 type SampleCollector struct {
-	metricCollector
+	BaseCollector
 
 	config SampleCollectorConfig // the configuration structure
 	meta   map[string]string     // default meta information
@@ -108,6 +109,13 @@ func (m *SampleCollector) Read(interval time.Duration, output chan lp.CCMessage)
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *SampleCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "sample_metric", Types: []string{"node"}, Kind: mc.Gauge, Description: "Sample metric"},
+	}
+}
+
 // Close metric collector: close network connection, close files, close libraries, ...
 // Called once by the collector manager
 func (m *SampleCollector) Close() {
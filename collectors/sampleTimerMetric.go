@@ -16,6 +16,7 @@ import (
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // These are the fields we read from the JSON configuration
@@ -24,9 +25,9 @@ type SampleTimerCollectorConfig struct {
 }
 
 // This contains all variables we need during execution and the variables
-// defined by metricCollector (name, init, ...)
+// defined by BaseCollector (name, init, ...)
 type SampleTimerCollector struct {
-	metricCollector
+	BaseCollector
 
 	wg       sync.WaitGroup             // sync group for management
 	done     chan bool                  // channel for management
@@ -126,6 +127,13 @@ func (m *SampleTimerCollector) Read(interval time.Duration, output chan lp.CCMes
 	m.output = output
 }
 
+// Catalog returns the metrics the collector can send
+func (m *SampleTimerCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "sample_metric", Types: []string{"node"}, Kind: mc.Gauge, Description: "Sample metric"},
+	}
+}
+
 func (m *SampleTimerCollector) Close() {
 	// Send signal to the timer loop to stop it
 	m.done <- true
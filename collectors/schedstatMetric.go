@@ -12,26 +12,27 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const SCHEDSTATFILE = `/proc/schedstat`
 
 // These are the fields we read from the JSON configuration
 type SchedstatCollectorConfig struct {
-	ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
+	MetricFilter
 }
 
 // This contains all variables we need during execution and the variables
-// defined by metricCollector (name, init, ...)
+// defined by BaseCollector (name, init, ...)
 type SchedstatCollector struct {
-	metricCollector
+	BaseCollector
 
 	config        SchedstatCollectorConfig     // the configuration structure
 	lastTimestamp time.Time                    // Store time stamp of last tick to derive values
@@ -45,7 +46,7 @@ type SchedstatCollector struct {
 // See: metricCollector.go
 
 // Init initializes the sample collector
-// Called once by the collector manager
+// Called by the collector manager at startup and again after topology changes
 // All tags, meta data tags and metrics that do not change over the runtime should be set here
 func (m *SchedstatCollector) Init(config json.RawMessage) error {
 	// Always set the name early in Init() to use it in cclog.Component* functions
@@ -73,9 +74,12 @@ func (m *SchedstatCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): failed to decode JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	// Check input file
-	file, err := os.Open(SCHEDSTATFILE)
+	file, err := hostfs.Open(SCHEDSTATFILE)
 	if err != nil {
 		return fmt.Errorf("%s Init(): Failed opening scheduler statistics file \"%s\": %w", m.name, SCHEDSTATFILE, err)
 	}
@@ -107,7 +111,7 @@ func (m *SchedstatCollector) Init(config json.RawMessage) error {
 	}
 
 	// Save current timestamp
-	m.lastTimestamp = time.Now()
+	m.lastTimestamp = hostfs.Now()
 
 	// Set this flag only if everything is initialized properly, all required files exist, ...
 	m.init = true
@@ -127,6 +131,9 @@ func (m *SchedstatCollector) ParseProcLine(linefields []string, tags map[string]
 	m.olddata[linefields[0]]["waiting"] = waiting
 	value := l_running + l_waiting
 
+	if m.config.IsMetricExcluded("cpu_load_core") {
+		return
+	}
 	y, err := lp.NewMetric("cpu_load_core", tags, m.meta, value, now)
 	if err == nil {
 		// Send it to output channel
@@ -142,10 +149,10 @@ func (m *SchedstatCollector) Read(interval time.Duration, output chan lp.CCMessa
 	}
 
 	// timestamps
-	now := time.Now()
+	now := hostfs.Now()
 	tsdelta := now.Sub(m.lastTimestamp)
 
-	file, err := os.Open(SCHEDSTATFILE)
+	file, err := hostfs.Open(SCHEDSTATFILE)
 	if err != nil {
 		cclog.ComponentError(
 			m.name,
@@ -171,9 +178,23 @@ func (m *SchedstatCollector) Read(interval time.Duration, output chan lp.CCMessa
 	m.lastTimestamp = now
 }
 
+// Catalog returns the metrics the collector can send
+func (m *SchedstatCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "cpu_load_core", Types: []string{"hwthread"}, Kind: mc.Gauge, Description: "Time tasks were running or waiting on the hardware thread per second"},
+	}
+}
+
 // Close metric collector: close network connection, close files, close libraries, ...
-// Called once by the collector manager
+// Called by the collector manager at shutdown and before a re-initialization
+// after topology changes
 func (m *SchedstatCollector) Close() {
 	// Unset flag
 	m.init = false
 }
+
+// TopologyDependent marks the collector for re-initialization when hardware
+// threads go online or offline
+func (m *SchedstatCollector) TopologyDependent() bool {
+	return true
+}
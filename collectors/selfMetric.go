@@ -11,22 +11,37 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"runtime"
+	"slices"
 	"syscall"
 	"time"
 
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 type SelfCollectorConfig struct {
-	MemStats   bool `json:"read_mem_stats"`
-	GoRoutines bool `json:"read_goroutines"`
-	CgoCalls   bool `json:"read_cgo_calls"`
-	Rusage     bool `json:"read_rusage"`
+	MemStats    bool `json:"read_mem_stats"`
+	GoRoutines  bool `json:"read_goroutines"`
+	CgoCalls    bool `json:"read_cgo_calls"`
+	Rusage      bool `json:"read_rusage"`
+	RouterStats bool `json:"read_router_stats"`
+	MetricFilter
+}
+
+// routerStats returns the counters of the metric router. The collectors have no
+// access to the router, so the function is set with SetRouterStats().
+var routerStats func() map[string]uint64
+
+// SetRouterStats sets the function used by the self collector to read the
+// counters of the metric router like the number of messages evicted from the cache
+func SetRouterStats(stats func() map[string]uint64) {
+	routerStats = stats
 }
 
 type SelfCollector struct {
-	metricCollector
+	BaseCollector
 
 	config SelfCollectorConfig // the configuration structure
 	meta   map[string]string   // default meta information
@@ -54,6 +69,9 @@ func (m *SelfCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	m.init = true
 	return err
 }
@@ -66,49 +84,49 @@ func (m *SelfCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 		runtime.ReadMemStats(&memstats)
 
 		y, err := lp.NewMetric("total_alloc", m.tags, m.meta, memstats.TotalAlloc, timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("total_alloc") {
 			y.AddMeta("unit", "Bytes")
 			output <- y
 		}
 		y, err = lp.NewMetric("heap_alloc", m.tags, m.meta, memstats.HeapAlloc, timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("heap_alloc") {
 			y.AddMeta("unit", "Bytes")
 			output <- y
 		}
 		y, err = lp.NewMetric("heap_sys", m.tags, m.meta, memstats.HeapSys, timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("heap_sys") {
 			y.AddMeta("unit", "Bytes")
 			output <- y
 		}
 		y, err = lp.NewMetric("heap_idle", m.tags, m.meta, memstats.HeapIdle, timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("heap_idle") {
 			y.AddMeta("unit", "Bytes")
 			output <- y
 		}
 		y, err = lp.NewMetric("heap_inuse", m.tags, m.meta, memstats.HeapInuse, timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("heap_inuse") {
 			y.AddMeta("unit", "Bytes")
 			output <- y
 		}
 		y, err = lp.NewMetric("heap_released", m.tags, m.meta, memstats.HeapReleased, timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("heap_released") {
 			y.AddMeta("unit", "Bytes")
 			output <- y
 		}
 		y, err = lp.NewMetric("heap_objects", m.tags, m.meta, memstats.HeapObjects, timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("heap_objects") {
 			output <- y
 		}
 	}
 	if m.config.GoRoutines {
 		y, err := lp.NewMetric("num_goroutines", m.tags, m.meta, runtime.NumGoroutine(), timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("num_goroutines") {
 			output <- y
 		}
 	}
 	if m.config.CgoCalls {
 		y, err := lp.NewMetric("num_cgo_calls", m.tags, m.meta, runtime.NumCgoCall(), timestamp)
-		if err == nil {
+		if err == nil && !m.config.IsMetricExcluded("num_cgo_calls") {
 			output <- y
 		}
 	}
@@ -119,39 +137,77 @@ func (m *SelfCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 			sec, nsec := rusage.Utime.Unix()
 			t := float64(sec) + (float64(nsec) * 1e-9)
 			y, err := lp.NewMetric("rusage_user_time", m.tags, m.meta, t, timestamp)
-			if err == nil {
+			if err == nil && !m.config.IsMetricExcluded("rusage_user_time") {
 				y.AddMeta("unit", "seconds")
 				output <- y
 			}
 			sec, nsec = rusage.Stime.Unix()
 			t = float64(sec) + (float64(nsec) * 1e-9)
 			y, err = lp.NewMetric("rusage_system_time", m.tags, m.meta, t, timestamp)
-			if err == nil {
+			if err == nil && !m.config.IsMetricExcluded("rusage_system_time") {
 				y.AddMeta("unit", "seconds")
 				output <- y
 			}
 			y, err = lp.NewMetric("rusage_vol_ctx_switch", m.tags, m.meta, rusage.Nvcsw, timestamp)
-			if err == nil {
+			if err == nil && !m.config.IsMetricExcluded("rusage_vol_ctx_switch") {
 				output <- y
 			}
 			y, err = lp.NewMetric("rusage_invol_ctx_switch", m.tags, m.meta, rusage.Nivcsw, timestamp)
-			if err == nil {
+			if err == nil && !m.config.IsMetricExcluded("rusage_invol_ctx_switch") {
 				output <- y
 			}
 			y, err = lp.NewMetric("rusage_signals", m.tags, m.meta, rusage.Nsignals, timestamp)
-			if err == nil {
+			if err == nil && !m.config.IsMetricExcluded("rusage_signals") {
 				output <- y
 			}
 			y, err = lp.NewMetric("rusage_major_pgfaults", m.tags, m.meta, rusage.Majflt, timestamp)
-			if err == nil {
+			if err == nil && !m.config.IsMetricExcluded("rusage_major_pgfaults") {
 				output <- y
 			}
 			y, err = lp.NewMetric("rusage_minor_pgfaults", m.tags, m.meta, rusage.Minflt, timestamp)
-			if err == nil {
+			if err == nil && !m.config.IsMetricExcluded("rusage_minor_pgfaults") {
+				output <- y
+			}
+		}
+
+	}
+	if m.config.RouterStats && routerStats != nil {
+		stats := routerStats()
+		for _, name := range slices.Sorted(maps.Keys(stats)) {
+			y, err := lp.NewMetric(name, m.tags, m.meta, stats[name], timestamp)
+			if err == nil && !m.config.IsMetricExcluded(name) {
 				output <- y
 			}
 		}
+	}
+}
 
+// Catalog returns the metrics the collector can send
+func (m *SelfCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	memStats := []string{"read_mem_stats"}
+	rusage := []string{"read_rusage"}
+	router := []string{"read_router_stats"}
+	return []mc.Metric{
+		{Name: "total_alloc", Unit: "Bytes", Types: types, Kind: mc.Counter, Description: "Cumulative memory allocated for heap objects", Options: memStats},
+		{Name: "heap_alloc", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "Memory of allocated heap objects", Options: memStats},
+		{Name: "heap_sys", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "Heap memory obtained from the operating system", Options: memStats},
+		{Name: "heap_idle", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "Idle heap spans", Options: memStats},
+		{Name: "heap_inuse", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "In-use heap spans", Options: memStats},
+		{Name: "heap_released", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "Heap memory returned to the operating system", Options: memStats},
+		{Name: "heap_objects", Types: types, Kind: mc.Gauge, Description: "Number of allocated heap objects", Options: memStats},
+		{Name: "num_goroutines", Types: types, Kind: mc.Gauge, Description: "Number of goroutines", Options: []string{"read_goroutines"}},
+		{Name: "num_cgo_calls", Types: types, Kind: mc.Counter, Description: "Number of cgo calls", Options: []string{"read_cgo_calls"}},
+		{Name: "rusage_user_time", Unit: "seconds", Types: types, Kind: mc.Counter, Description: "User CPU time", Options: rusage},
+		{Name: "rusage_system_time", Unit: "seconds", Types: types, Kind: mc.Counter, Description: "System CPU time", Options: rusage},
+		{Name: "rusage_vol_ctx_switch", Types: types, Kind: mc.Counter, Description: "Voluntary context switches", Options: rusage},
+		{Name: "rusage_invol_ctx_switch", Types: types, Kind: mc.Counter, Description: "Involuntary context switches", Options: rusage},
+		{Name: "rusage_signals", Types: types, Kind: mc.Counter, Description: "Received signals", Options: rusage},
+		{Name: "rusage_major_pgfaults", Types: types, Kind: mc.Counter, Description: "Major page faults", Options: rusage},
+		{Name: "rusage_minor_pgfaults", Types: types, Kind: mc.Counter, Description: "Minor page faults", Options: rusage},
+		{Name: "router_cache_evicted", Types: types, Kind: mc.Counter, Description: "Messages not stored in the router cache because an interval reached cache_max_messages", Options: router},
+		{Name: "router_aggregation_skipped", Types: types, Kind: mc.Counter, Description: "Messages matching an interval aggregate that were skipped because of missing or invalid values", Options: router},
+		{Name: "router_aggregation_dropped", Types: types, Kind: mc.Counter, Description: "Derived messages of the interval aggregates dropped because the router input was full", Options: router},
 	}
 }
 
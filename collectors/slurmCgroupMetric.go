@@ -3,8 +3,6 @@ package collectors
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
 	"regexp"
@@ -14,6 +12,9 @@ import (
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 type SlurmJobData struct {
@@ -26,22 +27,21 @@ type SlurmJobData struct {
 }
 
 type SlurmCgroupsConfig struct {
-	CgroupBase     string   `json:"cgroup_base"`
-	ExcludeMetrics []string `json:"exclude_metrics,omitempty"`
-	UseSudo        bool     `json:"use_sudo,omitempty"`
+	CgroupBase string `json:"cgroup_base"`
+	UseSudo    bool   `json:"use_sudo,omitempty"`
+	MetricFilter
 }
 
 type SlurmCgroupCollector struct {
-	metricCollector
-
-	config         SlurmCgroupsConfig
-	meta           map[string]string
-	tags           map[string]string
-	allCPUs        []int
-	cpuUsed        map[int]bool
-	cgroupBase     string
-	excludeMetrics map[string]struct{}
-	useSudo        bool
+	BaseCollector
+
+	config     SlurmCgroupsConfig
+	meta       map[string]string
+	tags       map[string]string
+	allCPUs    []int
+	cpuUsed    map[int]bool
+	cgroupBase string
+	useSudo    bool
 }
 
 const defaultCgroupBase = "/sys/fs/cgroup/system.slice/slurmstepd.scope"
@@ -55,7 +55,13 @@ var (
 )
 
 func (m *SlurmCgroupCollector) findSlurmJobDirs() ([]string, error) {
-	entries, err := os.ReadDir(m.cgroupBase)
+	return findSlurmJobDirs(m.cgroupBase)
+}
+
+// findSlurmJobDirs returns the cgroup directories of the Slurm jobs below
+// cgroupBase
+func findSlurmJobDirs(cgroupBase string) ([]string, error) {
+	entries, err := hostfs.ReadDir(cgroupBase)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +76,7 @@ func (m *SlurmCgroupCollector) findSlurmJobDirs() ([]string, error) {
 		name := entry.Name()
 
 		if jobIDDirRE.MatchString(name) || sluidDirRE.MatchString(name) {
-			jobDirs = append(jobDirs, filepath.Join(m.cgroupBase, name))
+			jobDirs = append(jobDirs, filepath.Join(cgroupBase, name))
 		}
 	}
 
@@ -111,26 +117,25 @@ func ParseCPUs(cpuset string) ([]int, error) {
 	return result, nil
 }
 
+// GetAllCPUs returns the online hardware threads tracked by ccTopology
 func GetAllCPUs() ([]int, error) {
-	cpuOnline := "/sys/devices/system/cpu/online"
-	data, err := os.ReadFile(cpuOnline)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file \"%s\": %w", cpuOnline, err)
+	cpus := topo.HwthreadList()
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no online hardware threads found")
 	}
-	return ParseCPUs(strings.TrimSpace(string(data)))
+	return cpus, nil
 }
 
 func (m *SlurmCgroupCollector) isExcluded(metric string) bool {
-	_, found := m.excludeMetrics[metric]
-	return found
+	return m.config.IsMetricExcluded(metric)
 }
 
 func (m *SlurmCgroupCollector) readFile(path string) ([]byte, error) {
 	if m.useSudo {
-		cmd := exec.Command("sudo", "cat", path)
+		cmd := hostfs.Command("sudo", "cat", path)
 		return cmd.Output()
 	}
-	return os.ReadFile(path)
+	return hostfs.ReadFile(path)
 }
 
 func (m *SlurmCgroupCollector) Init(config json.RawMessage) error {
@@ -156,14 +161,13 @@ func (m *SlurmCgroupCollector) Init(config json.RawMessage) error {
 		if err = d.Decode(&m.config); err != nil {
 			return fmt.Errorf("%s Init(): Error reading JSON config: %w", m.name, err)
 		}
-		m.excludeMetrics = make(map[string]struct{})
-		for _, metric := range m.config.ExcludeMetrics {
-			m.excludeMetrics[metric] = struct{}{}
-		}
 		if m.config.CgroupBase != "" {
 			m.cgroupBase = m.config.CgroupBase
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	m.useSudo = m.config.UseSudo
 	if !m.useSudo {
@@ -263,7 +267,7 @@ func (m *SlurmCgroupCollector) ReadJobData(jobdir string) (SlurmJobData, error)
 }
 
 func (m *SlurmCgroupCollector) Read(interval time.Duration, output chan lp.CCMessage) {
-	timestamp := time.Now()
+	timestamp := hostfs.Now()
 
 	for k := range m.cpuUsed {
 		delete(m.cpuUsed, k)
@@ -438,6 +442,25 @@ func (m *SlurmCgroupCollector) Read(interval time.Duration, output chan lp.CCMes
 	}
 }
 
+// TopologyDependent marks the collector for re-initialization when hardware
+// threads go online or offline
+func (m *SlurmCgroupCollector) TopologyDependent() bool {
+	return true
+}
+
+// Catalog returns the metrics the collector can send. The values of a job
+// are distributed evenly across the hardware threads of the job.
+func (m *SlurmCgroupCollector) Catalog() []mc.Metric {
+	types := []string{"hwthread"}
+	return []mc.Metric{
+		{Name: "job_mem_used", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "Memory used by the job"},
+		{Name: "job_max_mem_used", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "Maximal memory used by the job"},
+		{Name: "job_mem_limit", Unit: "Bytes", Types: types, Kind: mc.Gauge, Description: "Memory limit of the job"},
+		{Name: "job_user_cpu", Unit: "%", Types: types, Kind: mc.Gauge, Description: "User CPU utilization of the job"},
+		{Name: "job_sys_cpu", Unit: "%", Types: types, Kind: mc.Gauge, Description: "System CPU utilization of the job"},
+	}
+}
+
 func (m *SlurmCgroupCollector) Close() {
 	m.init = false
 }
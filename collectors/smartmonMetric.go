@@ -4,22 +4,22 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os/exec"
-	"slices"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 type SmartMonCollectorConfig struct {
-	UseSudo        bool     `json:"use_sudo,omitempty"`
-	ExcludeDevices []string `json:"exclude_devices,omitempty"`
-	ExcludeMetrics []string `json:"excludeMetrics,omitempty"`
-	Devices        []struct {
+	UseSudo             bool     `json:"use_sudo,omitempty"`
+	ExcludeMetricsAlias []string `json:"excludeMetrics,omitempty"` // Alias of exclude_metrics
+	Devices             []struct {
 		Name string `json:"name"`
 		Type string `json:"type"`
 	} `json:"devices,omitempty"`
+	MetricFilter
 }
 
 type deviceT struct {
@@ -29,7 +29,7 @@ type deviceT struct {
 }
 
 type SmartMonCollector struct {
-	metricCollector
+	BaseCollector
 	config        SmartMonCollectorConfig // the configuration structure
 	meta          map[string]string       // default meta information
 	tags          map[string]string       // default tags
@@ -58,7 +58,7 @@ func (m *SmartMonCollector) getSmartmonDevices() error {
 	// Use configured devices
 	if len(m.config.Devices) > 0 {
 		for _, configDevice := range m.config.Devices {
-			if !slices.Contains(m.config.ExcludeDevices, configDevice.Name) {
+			if !m.config.IsDeviceExcluded(configDevice.Name) {
 				d := deviceT{
 					Name: configDevice.Name,
 					Type: configDevice.Type,
@@ -80,7 +80,7 @@ func (m *SmartMonCollector) getSmartmonDevices() error {
 		scanCmd = append(scanCmd, m.sudoCmd)
 	}
 	scanCmd = append(scanCmd, m.smartCtlCmd, "--scan", "--json=c")
-	command := exec.Command(scanCmd[0], scanCmd[1:]...)
+	command := hostfs.Command(scanCmd[0], scanCmd[1:]...)
 
 	stdout, err := command.Output()
 	if err != nil {
@@ -100,7 +100,7 @@ func (m *SmartMonCollector) getSmartmonDevices() error {
 
 	m.devices = make([]deviceT, 0)
 	for _, d := range scanOutput.Devices {
-		if !slices.Contains(m.config.ExcludeDevices, d.Name) {
+		if !m.config.IsDeviceExcluded(d.Name) {
 			if m.config.UseSudo {
 				d.queryCommand = append(d.queryCommand, m.sudoCmd)
 			}
@@ -136,51 +136,35 @@ func (m *SmartMonCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error reading config: %w", m.name, err)
 		}
 	}
-	for _, excludeMetric := range m.config.ExcludeMetrics {
-		switch excludeMetric {
-		case "smartmon_temp":
-			m.excludeMetric.temp = true
-		case "smartmon_percent_used":
-			m.excludeMetric.percentUsed = true
-		case "smartmon_avail_spare":
-			m.excludeMetric.availSpare = true
-		case "smartmon_data_units_read":
-			m.excludeMetric.dataUnitsRead = true
-		case "smartmon_data_units_write":
-			m.excludeMetric.dataUnitsWrite = true
-		case "smartmon_host_reads":
-			m.excludeMetric.hostReads = true
-		case "smartmon_host_writes":
-			m.excludeMetric.hostWrites = true
-		case "smartmon_power_cycles":
-			m.excludeMetric.powerCycles = true
-		case "smartmon_power_on":
-			m.excludeMetric.powerOn = true
-		case "smartmon_unsafe_shutdowns":
-			m.excludeMetric.UnsafeShutdowns = true
-		case "smartmon_media_errors":
-			m.excludeMetric.mediaErrors = true
-		case "smartmon_errlog_entries":
-			m.excludeMetric.errlogEntries = true
-		case "smartmon_warn_temp_time":
-			m.excludeMetric.warnTempTime = true
-		case "smartmon_crit_comp_time":
-			m.excludeMetric.critCompTime = true
-		default:
-			return fmt.Errorf("%s Init(): Unknown excluded metric: %s", m.name, excludeMetric)
-		}
-
+	// excludeMetrics is an alias of exclude_metrics
+	m.config.ExcludeMetrics = append(m.config.ExcludeMetrics, m.config.ExcludeMetricsAlias...)
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
 	}
+	m.excludeMetric.temp = m.config.IsMetricExcluded("smartmon_temp")
+	m.excludeMetric.percentUsed = m.config.IsMetricExcluded("smartmon_percent_used")
+	m.excludeMetric.availSpare = m.config.IsMetricExcluded("smartmon_avail_spare")
+	m.excludeMetric.dataUnitsRead = m.config.IsMetricExcluded("smartmon_data_units_read")
+	m.excludeMetric.dataUnitsWrite = m.config.IsMetricExcluded("smartmon_data_units_write")
+	m.excludeMetric.hostReads = m.config.IsMetricExcluded("smartmon_host_reads")
+	m.excludeMetric.hostWrites = m.config.IsMetricExcluded("smartmon_host_writes")
+	m.excludeMetric.powerCycles = m.config.IsMetricExcluded("smartmon_power_cycles")
+	m.excludeMetric.powerOn = m.config.IsMetricExcluded("smartmon_power_on")
+	m.excludeMetric.UnsafeShutdowns = m.config.IsMetricExcluded("smartmon_unsafe_shutdowns")
+	m.excludeMetric.mediaErrors = m.config.IsMetricExcluded("smartmon_media_errors")
+	m.excludeMetric.errlogEntries = m.config.IsMetricExcluded("smartmon_errlog_entries")
+	m.excludeMetric.warnTempTime = m.config.IsMetricExcluded("smartmon_warn_temp_time")
+	m.excludeMetric.critCompTime = m.config.IsMetricExcluded("smartmon_crit_comp_time")
 
 	// Check if sudo and smartctl are in search path
 	if m.config.UseSudo {
-		p, err := exec.LookPath("sudo")
+		p, err := hostfs.LookPath("sudo")
 		if err != nil {
 			return fmt.Errorf("%s Init(): No sudo command found in search path: %w", m.name, err)
 		}
 		m.sudoCmd = p
 	}
-	p, err := exec.LookPath("smartctl")
+	p, err := hostfs.LookPath("smartctl")
 	if err != nil {
 		return fmt.Errorf("%s Init(): No smartctl command found in search path: %w", m.name, err)
 	}
@@ -221,10 +205,10 @@ type SmartMonData struct {
 }
 
 func (m *SmartMonCollector) Read(interval time.Duration, output chan lp.CCMessage) {
-	timestamp := time.Now()
+	timestamp := hostfs.Now()
 	for _, d := range m.devices {
 		var data SmartMonData
-		command := exec.Command(d.queryCommand[0], d.queryCommand[1:]...)
+		command := hostfs.Command(d.queryCommand[0], d.queryCommand[1:]...)
 
 		stdout, err := command.Output()
 		if err != nil {
@@ -355,6 +339,27 @@ func (m *SmartMonCollector) Read(interval time.Duration, output chan lp.CCMessag
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *SmartMonCollector) Catalog() []mc.Metric {
+	types := []string{"node"}
+	return []mc.Metric{
+		{Name: "smartmon_temp", Unit: "degC", Types: types, Kind: mc.Gauge, Description: "Temperature of the device"},
+		{Name: "smartmon_avail_spare", Unit: "percent", Types: types, Kind: mc.Gauge, Description: "Remaining spare capacity"},
+		{Name: "smartmon_percent_used", Unit: "percent", Types: types, Kind: mc.Gauge, Description: "Estimate of the used life span"},
+		{Name: "smartmon_data_units_read", Types: types, Kind: mc.Counter, Description: "Read data units of 512000 bytes"},
+		{Name: "smartmon_data_units_write", Types: types, Kind: mc.Counter, Description: "Written data units of 512000 bytes"},
+		{Name: "smartmon_host_reads", Types: types, Kind: mc.Counter, Description: "Read commands"},
+		{Name: "smartmon_host_writes", Types: types, Kind: mc.Counter, Description: "Write commands"},
+		{Name: "smartmon_power_cycles", Types: types, Kind: mc.Counter, Description: "Power cycles"},
+		{Name: "smartmon_power_on", Unit: "sec", Types: types, Kind: mc.Counter, Description: "Power on time"},
+		{Name: "smartmon_unsafe_shutdowns", Types: types, Kind: mc.Counter, Description: "Unsafe shutdowns"},
+		{Name: "smartmon_media_errors", Types: types, Kind: mc.Counter, Description: "Unrecovered data integrity errors"},
+		{Name: "smartmon_errlog_entries", Types: types, Kind: mc.Counter, Description: "Entries in the error information log"},
+		{Name: "smartmon_warn_temp_time", Types: types, Kind: mc.Counter, Description: "Minutes above the warning temperature threshold"},
+		{Name: "smartmon_crit_comp_time", Types: types, Kind: mc.Counter, Description: "Minutes above the critical temperature threshold"},
+	}
+}
+
 func (m *SmartMonCollector) Close() {
 	m.init = false
 }
@@ -11,7 +11,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -19,6 +18,8 @@ import (
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 // See: https://www.kernel.org/doc/html/latest/hwmon/sysfs-interface.html
@@ -41,13 +42,14 @@ type TempCollectorSensor struct {
 }
 
 type TempCollector struct {
-	metricCollector
+	BaseCollector
 
 	config struct {
-		ExcludeMetrics     []string                     `json:"exclude_metrics"`
 		TagOverride        map[string]map[string]string `json:"tag_override"`
 		ReportMaxTemp      bool                         `json:"report_max_temperature"`
 		ReportCriticalTemp bool                         `json:"report_critical_temperature"`
+		// Devices are identified by hwmon name and directory, e.g. coretemp or hwmon1
+		MetricFilter
 	}
 	sensors []*TempCollectorSensor
 }
@@ -70,6 +72,9 @@ func (m *TempCollector) Init(config json.RawMessage) error {
 			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
 		}
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 
 	m.meta = map[string]string{
 		"source": m.name,
@@ -81,7 +86,7 @@ func (m *TempCollector) Init(config json.RawMessage) error {
 
 	// Find all temperature sensor files
 	globPattern := filepath.Join("/sys/class/hwmon", "*", "temp*_input")
-	inputFiles, err := filepath.Glob(globPattern)
+	inputFiles, err := hostfs.Glob(globPattern)
 	if err != nil {
 		return fmt.Errorf("%s Init(): unable to glob files with pattern '%s': %w", m.name, globPattern, err)
 	}
@@ -95,14 +100,14 @@ func (m *TempCollector) Init(config json.RawMessage) error {
 
 		// sensor name
 		nameFile := filepath.Join(filepath.Dir(file), "name")
-		name, err := os.ReadFile(nameFile)
+		name, err := hostfs.ReadFile(nameFile)
 		if err == nil {
 			sensor.name = strings.TrimSpace(string(name))
 		}
 
 		// sensor label
 		labelFile := strings.TrimSuffix(file, "_input") + "_label"
-		label, err := os.ReadFile(labelFile)
+		label, err := hostfs.ReadFile(labelFile)
 		if err == nil {
 			sensor.label = strings.TrimSpace(string(label))
 		}
@@ -128,8 +133,13 @@ func (m *TempCollector) Init(config json.RawMessage) error {
 			sensor.metricName = "temp_" + sensor.metricName
 		}
 
+		// Skip excluded devices
+		if m.config.IsDeviceExcluded(sensor.name, filepath.Base(filepath.Dir(file))) {
+			continue
+		}
+
 		// Sensor file
-		_, err = os.ReadFile(file)
+		_, err = hostfs.ReadFile(file)
 		if err != nil {
 			continue
 		}
@@ -151,7 +161,7 @@ func (m *TempCollector) Init(config json.RawMessage) error {
 		// max temperature
 		if m.config.ReportMaxTemp {
 			maxTempFile := strings.TrimSuffix(file, "_input") + "_max"
-			if buffer, err := os.ReadFile(maxTempFile); err == nil {
+			if buffer, err := hostfs.ReadFile(maxTempFile); err == nil {
 				if x, err := strconv.ParseInt(strings.TrimSpace(string(buffer)), 10, 64); err == nil {
 					sensor.maxTempName = strings.Replace(sensor.metricName, "temp", "max_temp", 1)
 					sensor.maxTemp = x / 1000
@@ -162,7 +172,7 @@ func (m *TempCollector) Init(config json.RawMessage) error {
 		// critical temperature
 		if m.config.ReportCriticalTemp {
 			criticalTempFile := strings.TrimSuffix(file, "_input") + "_crit"
-			if buffer, err := os.ReadFile(criticalTempFile); err == nil {
+			if buffer, err := hostfs.ReadFile(criticalTempFile); err == nil {
 				if x, err := strconv.ParseInt(strings.TrimSpace(string(buffer)), 10, 64); err == nil {
 					sensor.critTempName = strings.Replace(sensor.metricName, "temp", "crit_temp", 1)
 					sensor.critTemp = x / 1000
@@ -186,7 +196,7 @@ func (m *TempCollector) Init(config json.RawMessage) error {
 func (m *TempCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 	for _, sensor := range m.sensors {
 		// Read sensor file
-		buffer, err := os.ReadFile(sensor.file)
+		buffer, err := hostfs.ReadFile(sensor.file)
 		if err != nil {
 			cclog.ComponentError(
 				m.name,
@@ -201,29 +211,39 @@ func (m *TempCollector) Read(interval time.Duration, output chan lp.CCMessage) {
 			continue
 		}
 		x /= 1000
-		y, err := lp.NewMetric(sensor.metricName, sensor.tags, m.meta, x, time.Now())
-		if err == nil {
+		y, err := lp.NewMetric(sensor.metricName, sensor.tags, m.meta, x, hostfs.Now())
+		if err == nil && !m.config.IsMetricExcluded(sensor.metricName) {
 			output <- y
 		}
 
 		// max temperature
 		if m.config.ReportMaxTemp && sensor.maxTemp != 0 {
-			y, err := lp.NewMetric(sensor.maxTempName, sensor.tags, m.meta, sensor.maxTemp, time.Now())
-			if err == nil {
+			y, err := lp.NewMetric(sensor.maxTempName, sensor.tags, m.meta, sensor.maxTemp, hostfs.Now())
+			if err == nil && !m.config.IsMetricExcluded(sensor.maxTempName) {
 				output <- y
 			}
 		}
 
 		// critical temperature
 		if m.config.ReportCriticalTemp && sensor.critTemp != 0 {
-			y, err := lp.NewMetric(sensor.critTempName, sensor.tags, m.meta, sensor.critTemp, time.Now())
-			if err == nil {
+			y, err := lp.NewMetric(sensor.critTempName, sensor.tags, m.meta, sensor.critTemp, hostfs.Now())
+			if err == nil && !m.config.IsMetricExcluded(sensor.critTempName) {
 				output <- y
 			}
 		}
 	}
 }
 
+// Catalog returns the metrics the collector can send. The metric names are
+// derived from the names and labels of the sensors, e.g. temp_package_id_0.
+func (m *TempCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "*temp*", Unit: "degC", Kind: mc.Gauge, Description: "Temperature of a hwmon sensor"},
+		{Name: "*max_temp*", Unit: "degC", Kind: mc.Gauge, Description: "Maximal temperature of a hwmon sensor", Options: []string{"report_max_temperature"}},
+		{Name: "*crit_temp*", Unit: "degC", Kind: mc.Gauge, Description: "Critical temperature of a hwmon sensor", Options: []string{"report_critical_temperature"}},
+	}
+}
+
 func (m *TempCollector) Close() {
 	m.init = false
 }
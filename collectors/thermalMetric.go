@@ -0,0 +1,279 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+// See: https://www.kernel.org/doc/html/latest/driver-api/thermal/sysfs-api.html
+// /sys/class/thermal/thermal_zone*/type -> x86_pkg_temp
+// /sys/class/thermal/thermal_zone*/temp -> 45000 = 45.0°C
+// /sys/class/thermal/thermal_zone*/trip_point_*_temp -> 100000 = 100.0°C
+// /sys/class/thermal/thermal_zone*/trip_point_*_type -> critical
+// /sys/class/thermal/cooling_device*/type -> Processor
+// /sys/class/thermal/cooling_device*/cur_state -> 0
+// /sys/class/thermal/cooling_device*/max_state -> 3
+
+const THERMAL_BASE_PATH = `/sys/class/thermal`
+
+type ThermalCollectorTripPoint struct {
+	file string
+	tags map[string]string
+}
+
+type ThermalCollectorZone struct {
+	tempFile   string
+	tags       map[string]string
+	tripPoints []ThermalCollectorTripPoint
+}
+
+type ThermalCollectorCoolingDevice struct {
+	curStateFile string
+	maxStateFile string
+	tags         map[string]string
+}
+
+// ThermalCollector reads the temperatures and trip points of the thermal
+// zones and the states of the cooling devices
+type ThermalCollector struct {
+	BaseCollector
+
+	config struct {
+		TagOverride        map[string]map[string]string `json:"tag_override"`
+		SendTripPoints     bool                         `json:"send_trip_points"`
+		SendCoolingDevices bool                         `json:"send_cooling_devices"`
+		// Devices are identified by directory and type, e.g. thermal_zone0 or x86_pkg_temp
+		MetricFilter
+	}
+	zones          []ThermalCollectorZone
+	coolingDevices []ThermalCollectorCoolingDevice
+}
+
+// deviceTags returns the tags of a thermal zone or cooling device
+func (m *ThermalCollector) deviceTags(dir, deviceType string) map[string]string {
+	tags := map[string]string{
+		"type": "node",
+	}
+
+	// Apply tag override configuration
+	device := filepath.Base(dir)
+	if newtags, ok := m.config.TagOverride[device]; ok {
+		tags = maps.Clone(newtags)
+	}
+	tags["device"] = device
+	tags["device_type"] = deviceType
+	return tags
+}
+
+func (m *ThermalCollector) Init(config json.RawMessage) error {
+	// Check if already initialized
+	if m.init {
+		return nil
+	}
+
+	m.name = "ThermalCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.config.SendTripPoints = true
+	m.config.SendCoolingDevices = true
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "Thermal",
+	}
+
+	// Thermal zones
+	globPattern := filepath.Join(THERMAL_BASE_PATH, "thermal_zone[0-9]*")
+	dirs, err := hostfs.Glob(globPattern)
+	if err != nil {
+		return fmt.Errorf("%s Init(): unable to glob files with pattern '%s': %w", m.name, globPattern, err)
+	}
+	m.zones = make([]ThermalCollectorZone, 0, len(dirs))
+	for _, dir := range dirs {
+		buffer, err := hostfs.ReadFile(filepath.Join(dir, "type"))
+		if err != nil {
+			continue
+		}
+		zoneType := strings.TrimSpace(string(buffer))
+		if m.config.IsDeviceExcluded(filepath.Base(dir), zoneType) {
+			continue
+		}
+
+		// Skip disabled zones without a temperature
+		tempFile := filepath.Join(dir, "temp")
+		if _, err := readInt64File(tempFile); err != nil {
+			continue
+		}
+
+		zone := ThermalCollectorZone{
+			tempFile:   tempFile,
+			tags:       m.deviceTags(dir, zoneType),
+			tripPoints: make([]ThermalCollectorTripPoint, 0),
+		}
+		if m.config.SendTripPoints {
+			tripFiles, _ := hostfs.Glob(filepath.Join(dir, "trip_point_[0-9]*_temp"))
+			for _, file := range tripFiles {
+				tags := maps.Clone(zone.tags)
+				tags["trip_point"] = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "trip_point_"), "_temp")
+				if buffer, err := hostfs.ReadFile(strings.TrimSuffix(file, "_temp") + "_type"); err == nil {
+					tags["trip_type"] = strings.TrimSpace(string(buffer))
+				}
+				zone.tripPoints = append(zone.tripPoints, ThermalCollectorTripPoint{file: file, tags: tags})
+			}
+		}
+		m.zones = append(m.zones, zone)
+	}
+
+	// Cooling devices
+	m.coolingDevices = make([]ThermalCollectorCoolingDevice, 0)
+	if m.config.SendCoolingDevices {
+		globPattern := filepath.Join(THERMAL_BASE_PATH, "cooling_device[0-9]*")
+		dirs, err := hostfs.Glob(globPattern)
+		if err != nil {
+			return fmt.Errorf("%s Init(): unable to glob files with pattern '%s': %w", m.name, globPattern, err)
+		}
+		for _, dir := range dirs {
+			buffer, err := hostfs.ReadFile(filepath.Join(dir, "type"))
+			if err != nil {
+				continue
+			}
+			deviceType := strings.TrimSpace(string(buffer))
+			if m.config.IsDeviceExcluded(filepath.Base(dir), deviceType) {
+				continue
+			}
+			m.coolingDevices = append(m.coolingDevices,
+				ThermalCollectorCoolingDevice{
+					curStateFile: filepath.Join(dir, "cur_state"),
+					maxStateFile: filepath.Join(dir, "max_state"),
+					tags:         m.deviceTags(dir, deviceType),
+				})
+		}
+	}
+
+	if len(m.zones) == 0 && len(m.coolingDevices) == 0 {
+		return fmt.Errorf("%s Init(): no thermal zones or cooling devices found in '%s'", m.name, THERMAL_BASE_PATH)
+	}
+
+	// Finished initialization
+	m.init = true
+	return nil
+}
+
+// sendTemperature reads a temperature file in millidegree Celsius and sends
+// the temperature
+func (m *ThermalCollector) sendTemperature(
+	name string,
+	file string,
+	tags map[string]string,
+	now time.Time,
+	output chan lp.CCMessage,
+) {
+	if m.config.IsMetricExcluded(name) {
+		return
+	}
+	x, err := readInt64File(file)
+	if err != nil {
+		cclog.ComponentError(
+			m.name,
+			fmt.Sprintf("Read(): Failed to read file '%s': %v", file, err))
+		return
+	}
+	if y, err := lp.NewMetric(name, tags, m.meta, float64(x)/1000, now); err == nil {
+		y.AddMeta("unit", "degC")
+		output <- y
+	}
+}
+
+func (m *ThermalCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+
+	now := hostfs.Now()
+	for i := range m.zones {
+		zone := &m.zones[i]
+		m.sendTemperature("thermal_zone_temp", zone.tempFile, zone.tags, now, output)
+		for _, trip := range zone.tripPoints {
+			m.sendTemperature("thermal_zone_trip_temp", trip.file, trip.tags, now, output)
+		}
+	}
+
+	for i := range m.coolingDevices {
+		dev := &m.coolingDevices[i]
+		curState, err := readInt64File(dev.curStateFile)
+		if err != nil {
+			cclog.ComponentError(
+				m.name,
+				fmt.Sprintf("Read(): Failed to read file '%s': %v", dev.curStateFile, err))
+			continue
+		}
+		maxState, err := readInt64File(dev.maxStateFile)
+		if err != nil {
+			cclog.ComponentError(
+				m.name,
+				fmt.Sprintf("Read(): Failed to read file '%s': %v", dev.maxStateFile, err))
+			continue
+		}
+		if !m.config.IsMetricExcluded("cooling_device_cur_state") {
+			if y, err := lp.NewMetric("cooling_device_cur_state", dev.tags, m.meta, curState, now); err == nil {
+				output <- y
+			}
+		}
+		if !m.config.IsMetricExcluded("cooling_device_max_state") {
+			if y, err := lp.NewMetric("cooling_device_max_state", dev.tags, m.meta, maxState, now); err == nil {
+				output <- y
+			}
+		}
+		if maxState > 0 && !m.config.IsMetricExcluded("cooling_device_usage") {
+			usage := 100 * float64(curState) / float64(maxState)
+			if y, err := lp.NewMetric("cooling_device_usage", dev.tags, m.meta, usage, now); err == nil {
+				y.AddMeta("unit", "Percent")
+				output <- y
+			}
+		}
+	}
+}
+
+// Catalog returns the metrics the collector can send
+func (m *ThermalCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "thermal_zone_temp", Unit: "degC", Kind: mc.Gauge, Description: "Temperature of a thermal zone"},
+		{Name: "thermal_zone_trip_temp", Unit: "degC", Kind: mc.Gauge, Description: "Temperature of a trip point of a thermal zone"},
+		{Name: "cooling_device_cur_state", Kind: mc.Gauge, Description: "Current state of a cooling device, 0 is no cooling"},
+		{Name: "cooling_device_max_state", Kind: mc.Gauge, Description: "Maximal state of a cooling device"},
+		{Name: "cooling_device_usage", Unit: "Percent", Kind: mc.Gauge, Description: "Current state of a cooling device relative to the maximal state"},
+	}
+}
+
+func (m *ThermalCollector) Close() {
+	m.init = false
+}
@@ -0,0 +1,106 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+// TopologyCollector sends the topology of the node as event message in JSON
+// format at startup and whenever hardware threads go online or offline
+type TopologyCollector struct {
+	BaseCollector
+	tags       map[string]string
+	generation uint64 // Topology generation sent last
+	sent       bool   // Whether the topology was sent at all
+	config     struct {
+		// Resend the topology with this interval, e.g. for receivers started later
+		ResendInterval string `json:"resend_interval,omitempty"`
+	}
+	resendInterval time.Duration
+	lastSent       time.Time
+}
+
+func (m *TopologyCollector) Init(config json.RawMessage) error {
+	m.name = "TopologyCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.config.ResendInterval = ""
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	m.resendInterval = 0
+	if len(m.config.ResendInterval) > 0 {
+		t, err := time.ParseDuration(m.config.ResendInterval)
+		if err != nil {
+			return fmt.Errorf("%s Init(): Failed to parse resend_interval '%s': %w", m.name, m.config.ResendInterval, err)
+		}
+		m.resendInterval = t
+	}
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "Topology",
+	}
+	m.tags = map[string]string{"type": "node"}
+	m.sent = false
+	m.init = true
+	return nil
+}
+
+func (m *TopologyCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+	now := time.Now()
+	generation := topo.Generation()
+	resend := m.resendInterval > 0 && now.Sub(m.lastSent) >= m.resendInterval
+	if m.sent && generation == m.generation && !resend {
+		return
+	}
+
+	t := topo.Export()
+	payload, err := json.Marshal(t)
+	if err != nil {
+		cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to encode topology: %v", err))
+		return
+	}
+	y, err := lp.NewEvent("topology", m.tags, m.meta, string(payload), now)
+	if err != nil {
+		cclog.ComponentError(m.name, fmt.Sprintf("Read(): Failed to create topology event: %v", err))
+		return
+	}
+	output <- y
+	m.generation = t.Generation
+	m.sent = true
+	m.lastSent = now
+}
+
+// Catalog returns the metrics the collector can send
+func (m *TopologyCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "topology", Types: []string{"node"}, Kind: mc.Event, Description: "Topology of the node in JSON format"},
+	}
+}
+
+func (m *TopologyCollector) Close() {
+	m.init = false
+}
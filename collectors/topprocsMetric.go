@@ -11,12 +11,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
 
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 )
 
 const (
@@ -26,10 +27,11 @@ const (
 
 type TopProcsCollectorConfig struct {
 	Num_procs int `json:"num_procs"`
+	MetricFilter
 }
 
 type TopProcsCollector struct {
-	metricCollector
+	BaseCollector
 
 	tags   map[string]string
 	config TopProcsCollectorConfig
@@ -55,13 +57,16 @@ func (m *TopProcsCollector) Init(config json.RawMessage) error {
 	} else {
 		m.config.Num_procs = int(DEFAULT_NUM_PROCS)
 	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
 	if m.config.Num_procs <= 0 || m.config.Num_procs > MAX_NUM_PROCS {
 		return fmt.Errorf("num_procs option must be set in 'topprocs' config (range: 1-%d)", MAX_NUM_PROCS)
 	}
 	if err := m.setup(); err != nil {
 		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
 	}
-	command := exec.Command("ps", "-Ao", "comm", "--sort=-pcpu")
+	command := hostfs.Command("ps", "-Ao", "comm", "--sort=-pcpu")
 	_, err = command.Output()
 	if err != nil {
 		return fmt.Errorf("%s Init(): failed to get output from command: %w", m.name, err)
@@ -74,7 +79,7 @@ func (m *TopProcsCollector) Read(interval time.Duration, output chan lp.CCMessag
 	if !m.init {
 		return
 	}
-	command := exec.Command("ps", "-Ao", "comm", "--sort=-pcpu")
+	command := hostfs.Command("ps", "-Ao", "comm", "--sort=-pcpu")
 	stdout, err := command.Output()
 	if err != nil {
 		cclog.ComponentError(
@@ -86,12 +91,22 @@ func (m *TopProcsCollector) Read(interval time.Duration, output chan lp.CCMessag
 	lines := strings.Split(string(stdout), "\n")
 	for i := 1; i < m.config.Num_procs+1; i++ {
 		name := fmt.Sprintf("topproc%d", i)
-		if y, err := lp.NewMetric(name, m.tags, m.meta, lines[i], time.Now()); err == nil {
+		if m.config.IsMetricExcluded(name) {
+			continue
+		}
+		if y, err := lp.NewMetric(name, m.tags, m.meta, lines[i], hostfs.Now()); err == nil {
 			output <- y
 		}
 	}
 }
 
+// Catalog returns the metrics the collector can send
+func (m *TopProcsCollector) Catalog() []mc.Metric {
+	return []mc.Metric{
+		{Name: "topproc*", Types: []string{"node"}, Kind: mc.Text, Description: "Command name of the process with the n-th highest CPU utilization, n = 1 to num_procs"},
+	}
+}
+
 func (m *TopProcsCollector) Close() {
 	m.init = false
 }
@@ -0,0 +1,231 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package collectors
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
+)
+
+const VMSTATFILE = `/proc/vmstat`
+
+// Counters read by default and their descriptions
+var vmstatDefaultCounters = []struct {
+	name string
+	desc string
+}{
+	{"pgfault", "Page faults"},
+	{"pgmajfault", "Major page faults requiring I/O"},
+	{"pswpin", "Pages swapped in"},
+	{"pswpout", "Pages swapped out"},
+	{"numa_hint_faults", "NUMA hinting faults of the automatic NUMA balancing"},
+	{"numa_pages_migrated", "Pages migrated by the automatic NUMA balancing"},
+	{"thp_fault_alloc", "Transparent huge pages allocated on page faults"},
+	{"thp_collapse_alloc", "Transparent huge pages allocated by collapsing pages"},
+	{"compact_stall", "Direct memory compactions stalling allocations"},
+	{"oom_kill", "Processes killed by the OOM killer"},
+	{"allocstall", "Direct memory reclaims stalling allocations"},
+}
+
+type VmstatCollectorConfig struct {
+	Counters           []string `json:"counters,omitempty"` // Counters of /proc/vmstat to read
+	SendAbsoluteValues bool     `json:"send_abs_values"`
+	SendDerivedValues  bool     `json:"send_derived_values"`
+	MetricFilter
+}
+
+// VmstatCollector reads counters of the virtual memory subsystem from
+// /proc/vmstat
+type VmstatCollector struct {
+	BaseCollector
+	config         VmstatCollectorConfig
+	counters       []string // Counters to read
+	tags           map[string]string
+	previousValues map[string]int64 // Values of the last read by counter
+	lastTimestamp  time.Time
+}
+
+func (m *VmstatCollector) Init(config json.RawMessage) error {
+	m.name = "VmstatCollector"
+	m.parallel = true
+	if err := m.setup(); err != nil {
+		return fmt.Errorf("%s Init(): setup() call failed: %w", m.name, err)
+	}
+	m.meta = map[string]string{
+		"source": m.name,
+		"group":  "Memory",
+	}
+	m.tags = map[string]string{
+		"type": "node",
+	}
+
+	m.config.SendAbsoluteValues = true
+	m.config.SendDerivedValues = true
+	if len(config) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&m.config); err != nil {
+			return fmt.Errorf("%s Init(): Error decoding JSON config: %w", m.name, err)
+		}
+	}
+	if err := m.config.MetricFilter.Init(); err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+
+	m.counters = m.config.Counters
+	if len(m.counters) == 0 {
+		for _, c := range vmstatDefaultCounters {
+			m.counters = append(m.counters, c.name)
+		}
+	}
+
+	// Check that the counters are available
+	values, err := m.readVmstat()
+	if err != nil {
+		return fmt.Errorf("%s Init(): %w", m.name, err)
+	}
+	for _, c := range m.counters {
+		if _, ok := values[c]; !ok {
+			cclog.ComponentWarn(m.name, fmt.Sprintf("Init(): counter '%s' not found in %s", c, VMSTATFILE))
+		}
+	}
+
+	m.previousValues = make(map[string]int64)
+	m.lastTimestamp = time.Time{}
+	m.init = true
+	return nil
+}
+
+// readVmstat reads the configured counters from /proc/vmstat. A counter
+// missing in the file is the sum of the counters with the counter name as
+// prefix, e.g. 'allocstall' is the sum of 'allocstall_normal',
+// 'allocstall_movable', ...
+func (m *VmstatCollector) readVmstat() (map[string]int64, error) {
+	file, err := hostfs.Open(VMSTATFILE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s': %w", VMSTATFILE, err)
+	}
+	defer file.Close()
+
+	all := make(map[string]int64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		all[key] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file '%s': %w", VMSTATFILE, err)
+	}
+
+	values := make(map[string]int64, len(m.counters))
+	for _, c := range m.counters {
+		if v, ok := all[c]; ok {
+			values[c] = v
+			continue
+		}
+		found := false
+		var sum int64
+		for key, v := range all {
+			if strings.HasPrefix(key, c+"_") {
+				sum += v
+				found = true
+			}
+		}
+		if found {
+			values[c] = sum
+		}
+	}
+	return values, nil
+}
+
+func (m *VmstatCollector) Read(interval time.Duration, output chan lp.CCMessage) {
+	if !m.init {
+		return
+	}
+
+	now := hostfs.Now()
+	timeDiff := now.Sub(m.lastTimestamp).Seconds()
+	m.lastTimestamp = now
+
+	values, err := m.readVmstat()
+	if err != nil {
+		cclog.ComponentError(m.name, fmt.Sprintf("Read(): %v", err))
+		return
+	}
+
+	for _, c := range m.counters {
+		value, ok := values[c]
+		if !ok {
+			continue
+		}
+		name := "vmstat_" + c
+		if m.config.SendAbsoluteValues && !m.config.IsMetricExcluded(name) {
+			if y, err := lp.NewMetric(name, m.tags, m.meta, value, now); err == nil {
+				output <- y
+			}
+		}
+		if m.config.SendDerivedValues {
+			prev, ok := m.previousValues[c]
+			if ok && value >= prev && !m.config.IsMetricExcluded(name+"_rate") {
+				rate := float64(value-prev) / timeDiff
+				if y, err := lp.NewMetric(name+"_rate", m.tags, m.meta, rate, now); err == nil {
+					output <- y
+				}
+			}
+			m.previousValues[c] = value
+		}
+	}
+}
+
+// Catalog returns the metrics the collector can send. Before
+// initialization, these are the metrics of the default counters.
+func (m *VmstatCollector) Catalog() []mc.Metric {
+	counters := m.counters
+	if !m.init {
+		counters = nil
+		for _, c := range vmstatDefaultCounters {
+			counters = append(counters, c.name)
+		}
+	}
+	types := []string{"node"}
+	metrics := make([]mc.Metric, 0, 2*len(counters))
+	for _, c := range counters {
+		desc := fmt.Sprintf("Counter '%s' of %s", c, VMSTATFILE)
+		for _, d := range vmstatDefaultCounters {
+			if d.name == c {
+				desc = d.desc
+			}
+		}
+		metrics = append(metrics,
+			mc.Metric{Name: "vmstat_" + c, Types: types, Kind: mc.Counter, Description: desc, Options: []string{"send_abs_values"}},
+			mc.Metric{Name: "vmstat_" + c + "_rate", Types: types, Kind: mc.Gauge, Description: desc + " per second", Options: []string{"send_derived_values"}},
+		)
+	}
+	return metrics
+}
+
+func (m *VmstatCollector) Close() {
+	m.init = false
+}
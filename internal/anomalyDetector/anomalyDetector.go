@@ -0,0 +1,377 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package anomalyDetector
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	agg "github.com/ClusterCockpit/cc-metric-collector/internal/metricAggregator"
+)
+
+const (
+	DEFAULT_MAX_SERIES = 10000
+	DEFAULT_ALPHA      = 0.1
+	DEFAULT_WINDOW     = 30
+	DEFAULT_THRESHOLD  = 3.0
+
+	// Scale factor to estimate the standard deviation of normal distributed
+	// values from the median absolute deviation (MAD)
+	madToSigma = 1.4826
+)
+
+// Configuration of an anomaly detection
+type AnomalyDetectionConfig struct {
+	Name      string  `json:"name"`                // Name of the detection, used to keep the state across reconfigurations
+	Condition string  `json:"if"`                  // Condition selecting the metrics for the detection
+	Method    string  `json:"method,omitempty"`    // Estimator: 'ewma' (default) or 'mad'
+	Alpha     float64 `json:"alpha,omitempty"`     // Smoothing factor for EWMA mean and variance (default 0.1)
+	Window    int     `json:"window,omitempty"`    // Number of samples for median and MAD (default 30)
+	Threshold float64 `json:"threshold,omitempty"` // Deviation in standard deviations (k sigma) that is reported as anomaly (default 3)
+	Warmup    int     `json:"warmup,omitempty"`    // Number of samples before scores are reported (default: window or 1/alpha)
+	Output    string  `json:"output,omitempty"`    // What to send: 'event' (default), 'score' or 'both'
+}
+
+// Anomaly detector configuration in the metric router
+type AnomalyDetectorConfig struct {
+	MaxSeries  int                      `json:"max_series,omitempty"` // Maximal number of series with state (default 10000)
+	Detections []AnomalyDetectionConfig `json:"detections"`           // List of anomaly detections
+}
+
+// State of a single series (metric name + tags) for one detection
+type seriesState struct {
+	key     string
+	method  string
+	count   int
+	mean    float64   // EWMA mean
+	vari    float64   // EWMA variance
+	window  []float64 // ring buffer with the last samples for median and MAD
+	next    int       // next index in ring buffer
+	element *list.Element
+}
+
+// The state of all series is kept outside of the detector instances. When the
+// router is reconfigured and creates a new detector, detections with the same
+// name continue with the existing state. The number of series is bounded, the
+// least recently updated series are evicted first.
+var states = struct {
+	series    map[string]*seriesState
+	lru       *list.List
+	maxSeries int
+	evicted   uint64
+	mutex     sync.Mutex
+}{
+	series:    make(map[string]*seriesState),
+	lru:       list.New(),
+	maxSeries: DEFAULT_MAX_SERIES,
+}
+
+type anomalyDetector struct {
+	detections []*AnomalyDetectionConfig
+}
+
+type AnomalyDetector interface {
+	Init(config AnomalyDetectorConfig) error
+	AddDetection(config AnomalyDetectionConfig) error
+	Process(m lp.CCMessage) []lp.CCMessage
+}
+
+func (d *anomalyDetector) Init(config AnomalyDetectorConfig) error {
+	d.detections = make([]*AnomalyDetectionConfig, 0)
+
+	states.mutex.Lock()
+	states.maxSeries = DEFAULT_MAX_SERIES
+	if config.MaxSeries > 0 {
+		states.maxSeries = config.MaxSeries
+	}
+	evictSeries()
+	states.mutex.Unlock()
+
+	for _, c := range config.Detections {
+		if err := d.AddDetection(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *anomalyDetector) AddDetection(config AnomalyDetectionConfig) error {
+	if len(config.Name) == 0 {
+		return fmt.Errorf("AnomalyDetector: detection requires a name")
+	}
+	if len(config.Condition) == 0 {
+		return fmt.Errorf("AnomalyDetector: detection '%s' requires an if condition", config.Name)
+	}
+	c := config
+	switch c.Method {
+	case "":
+		c.Method = "ewma"
+	case "ewma", "mad":
+	default:
+		return fmt.Errorf("AnomalyDetector: detection '%s' has unknown method '%s'", c.Name, c.Method)
+	}
+	if c.Alpha <= 0 || c.Alpha > 1 {
+		c.Alpha = DEFAULT_ALPHA
+	}
+	if c.Window <= 0 {
+		c.Window = DEFAULT_WINDOW
+	}
+	if c.Threshold <= 0 {
+		c.Threshold = DEFAULT_THRESHOLD
+	}
+	if c.Warmup <= 0 {
+		if c.Method == "mad" {
+			c.Warmup = c.Window
+		} else {
+			c.Warmup = int(math.Ceil(1 / c.Alpha))
+		}
+	}
+	switch c.Output {
+	case "":
+		c.Output = "event"
+	case "event", "score", "both":
+	default:
+		return fmt.Errorf("AnomalyDetector: detection '%s' has unknown output '%s'", c.Name, c.Output)
+	}
+	// Check condition
+	if err := agg.CheckCondition(c.Condition); err != nil {
+		return fmt.Errorf("AnomalyDetector: detection '%s' has invalid condition '%s': %w", c.Name, c.Condition, err)
+	}
+
+	for i, other := range d.detections {
+		if other.Name == c.Name {
+			d.detections[i] = &c
+			return nil
+		}
+	}
+	d.detections = append(d.detections, &c)
+	return nil
+}
+
+// getParamMap returns the variables for the evaluation of a condition
+func getParamMap(point lp.CCMessage) map[string]any {
+	params := make(map[string]any)
+	params["metric"] = point
+	params["name"] = point.Name()
+	for key, value := range point.Tags() {
+		params[key] = value
+	}
+	for key, value := range point.Meta() {
+		params[key] = value
+	}
+	maps.Copy(params, point.Fields())
+	params["timestamp"] = point.Time()
+	return params
+}
+
+// seriesKey identifies a series by detection name, metric name and tags
+func seriesKey(detection string, point lp.CCMessage) string {
+	tags := point.Tags()
+	keys := slices.Sorted(maps.Keys(tags))
+	var b strings.Builder
+	b.WriteString(detection)
+	b.WriteString("|")
+	b.WriteString(point.Name())
+	for _, k := range keys {
+		b.WriteString(",")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
+
+// evictSeries removes the least recently updated series until the number of
+// series is below the limit. It requires the states.mutex to be locked.
+func evictSeries() {
+	for len(states.series) > states.maxSeries {
+		e := states.lru.Back()
+		if e == nil {
+			return
+		}
+		s := states.lru.Remove(e).(*seriesState)
+		delete(states.series, s.key)
+		states.evicted++
+		if states.evicted == 1 {
+			cclog.ComponentWarnf("AnomalyDetector", "Reached maximal number of %d series, evicting least recently updated series", states.maxSeries)
+		}
+	}
+}
+
+// getSeries returns the state of a series. The state is created or reset if the
+// method of the detection changed. It requires the states.mutex to be locked.
+func getSeries(key string, c *AnomalyDetectionConfig) *seriesState {
+	s, ok := states.series[key]
+	if ok && s.method == c.Method && (c.Method != "mad" || len(s.window) == c.Window) {
+		states.lru.MoveToFront(s.element)
+		return s
+	}
+	if ok {
+		states.lru.Remove(s.element)
+	}
+	s = &seriesState{
+		key:    key,
+		method: c.Method,
+	}
+	if c.Method == "mad" {
+		s.window = make([]float64, c.Window)
+	}
+	s.element = states.lru.PushFront(s)
+	states.series[key] = s
+	evictSeries()
+	return s
+}
+
+// median returns the median of the values. The values are sorted in place.
+func median(values []float64) float64 {
+	slices.Sort(values)
+	n := len(values)
+	if n%2 == 0 {
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+	return values[n/2]
+}
+
+// update adds a sample to the series state and returns the deviation of the
+// sample from the state before the update in standard deviations. The score is
+// not valid during the warmup phase or if the series has no variation.
+func (s *seriesState) update(value float64, c *AnomalyDetectionConfig) (score float64, valid bool) {
+	var center, sigma float64
+	switch c.Method {
+	case "mad":
+		n := min(s.count, len(s.window))
+		if n > 0 {
+			values := slices.Clone(s.window[:n])
+			center = median(values)
+			for i := range values {
+				values[i] = math.Abs(values[i] - center)
+			}
+			sigma = madToSigma * median(values)
+		}
+		s.window[s.next] = value
+		s.next = (s.next + 1) % len(s.window)
+	default:
+		center = s.mean
+		sigma = math.Sqrt(s.vari)
+		if s.count == 0 {
+			s.mean = value
+		} else {
+			// Incremental update of exponentially weighted mean and variance
+			diff := value - s.mean
+			incr := c.Alpha * diff
+			s.mean += incr
+			s.vari = (1 - c.Alpha) * (s.vari + diff*incr)
+		}
+	}
+	s.count++
+
+	if s.count <= c.Warmup || sigma == 0 {
+		return 0, false
+	}
+	return (value - center) / sigma, true
+}
+
+// Process feeds the value of a metric to all matching detections and returns
+// the anomaly scores and events derived from it
+func (d *anomalyDetector) Process(m lp.CCMessage) []lp.CCMessage {
+	out := make([]lp.CCMessage, 0)
+	if len(d.detections) == 0 || m == nil {
+		return out
+	}
+	v, ok := m.GetField("value")
+	if !ok {
+		return out
+	}
+	var value float64
+	switch x := v.(type) {
+	case float64:
+		value = x
+	case float32:
+		value = float64(x)
+	case int64:
+		value = float64(x)
+	case int:
+		value = float64(x)
+	case uint64:
+		value = float64(x)
+	default:
+		return out
+	}
+	// A single NaN or infinite value would spoil the state of the series for good
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return out
+	}
+
+	var params map[string]any
+	for _, c := range d.detections {
+		if params == nil {
+			params = getParamMap(m)
+		}
+		matches, err := agg.EvalBoolCondition(c.Condition, params)
+		if err != nil {
+			cclog.ComponentError("AnomalyDetector", err.Error())
+			continue
+		}
+		if !matches {
+			continue
+		}
+
+		states.mutex.Lock()
+		s := getSeries(seriesKey(c.Name, m), c)
+		score, valid := s.update(value, c)
+		states.mutex.Unlock()
+		if !valid {
+			continue
+		}
+
+		meta := map[string]string{
+			"source":    "AnomalyDetector",
+			"detection": c.Name,
+		}
+		if c.Output == "score" || c.Output == "both" {
+			y, err := lp.NewMessage(m.Name()+"_anomaly_score", m.Tags(), meta, map[string]any{"value": score}, m.Time())
+			if err == nil {
+				out = append(out, y)
+			}
+		}
+		if (c.Output == "event" || c.Output == "both") && math.Abs(score) > c.Threshold {
+			event, err := json.Marshal(map[string]any{
+				"detection": c.Name,
+				"metric":    m.Name(),
+				"value":     value,
+				"score":     score,
+				"threshold": c.Threshold,
+			})
+			if err != nil {
+				continue
+			}
+			y, err := lp.NewEvent("anomaly", m.Tags(), meta, string(event), m.Time())
+			if err == nil {
+				out = append(out, y)
+			}
+		}
+	}
+	return out
+}
+
+func New(config AnomalyDetectorConfig) (AnomalyDetector, error) {
+	d := new(anomalyDetector)
+	err := d.Init(config)
+	if err != nil {
+		return nil, err
+	}
+	return d, err
+}
@@ -0,0 +1,320 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package anomalyDetector
+
+import (
+	"container/list"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+)
+
+func TestMain(m *testing.M) {
+	cclog.Init("err", false)
+	os.Exit(m.Run())
+}
+
+// resetStates removes the global state of all series
+func resetStates(t *testing.T) {
+	t.Helper()
+	states.mutex.Lock()
+	defer states.mutex.Unlock()
+	states.series = make(map[string]*seriesState)
+	states.lru = list.New()
+	states.maxSeries = DEFAULT_MAX_SERIES
+	states.evicted = 0
+}
+
+// baseline returns n samples varying around 10
+func baseline(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = []float64{10, 11, 9}[i%3]
+	}
+	return values
+}
+
+// process sends a value of the metric cpu_load with the given host tag through
+// the detector and returns the anomaly scores and events
+func process(t *testing.T, d AnomalyDetector, host string, value float64) []lp.CCMessage {
+	t.Helper()
+	m, err := lp.NewMetric("cpu_load", map[string]string{"type": "node", "hostname": host}, map[string]string{}, value, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.Process(m)
+}
+
+// countEvents returns the number of anomaly events and scores in the messages
+func countEvents(out []lp.CCMessage) (events int, scores int) {
+	for _, m := range out {
+		switch {
+		case m.IsEvent():
+			events++
+		case m.Name() == "cpu_load_anomaly_score":
+			scores++
+		}
+	}
+	return events, scores
+}
+
+func TestEstimatorWarmup(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    AnomalyDetectionConfig
+		samples   []float64
+		wantValid []bool
+	}{
+		{
+			name:      "ewma warmup",
+			config:    AnomalyDetectionConfig{Method: "ewma", Alpha: 0.5, Warmup: 3},
+			samples:   []float64{10, 12, 10, 12, 10},
+			wantValid: []bool{false, false, false, true, true},
+		},
+		{
+			name:      "ewma without variation",
+			config:    AnomalyDetectionConfig{Method: "ewma", Alpha: 0.5, Warmup: 1},
+			samples:   []float64{10, 10, 10, 10},
+			wantValid: []bool{false, false, false, false},
+		},
+		{
+			name:      "mad warmup",
+			config:    AnomalyDetectionConfig{Method: "mad", Window: 4, Warmup: 4},
+			samples:   []float64{10, 12, 10, 12, 10, 12},
+			wantValid: []bool{false, false, false, false, true, true},
+		},
+		{
+			name:      "mad without variation",
+			config:    AnomalyDetectionConfig{Method: "mad", Window: 3, Warmup: 1},
+			samples:   []float64{10, 10, 10, 10},
+			wantValid: []bool{false, false, false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &seriesState{method: tt.config.Method}
+			if tt.config.Method == "mad" {
+				s.window = make([]float64, tt.config.Window)
+			}
+			for i, v := range tt.samples {
+				if _, valid := s.update(v, &tt.config); valid != tt.wantValid[i] {
+					t.Errorf("sample %d: got valid=%v, want %v", i, valid, tt.wantValid[i])
+				}
+			}
+		})
+	}
+}
+
+func TestThresholdCrossing(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		value      float64
+		wantEvents int
+	}{
+		{"ewma spike", "ewma", 100, 1},
+		{"ewma drop", "ewma", -100, 1},
+		{"ewma within threshold", "ewma", 10.5, 0},
+		{"mad spike", "mad", 100, 1},
+		{"mad drop", "mad", -100, 1},
+		{"mad within threshold", "mad", 10.5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStates(t)
+			d, err := New(AnomalyDetectorConfig{
+				Detections: []AnomalyDetectionConfig{{
+					Name:      "load",
+					Condition: `name == "cpu_load"`,
+					Method:    tt.method,
+					Window:    10,
+					Output:    "both",
+				}},
+			})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			for _, v := range baseline(30) {
+				if events, _ := countEvents(process(t, d, "host1", v)); events > 0 {
+					t.Fatalf("got event for baseline value %v", v)
+				}
+			}
+			events, scores := countEvents(process(t, d, "host1", tt.value))
+			if events != tt.wantEvents {
+				t.Errorf("got %d events, want %d", events, tt.wantEvents)
+			}
+			if scores != 1 {
+				t.Errorf("got %d scores, want 1", scores)
+			}
+		})
+	}
+}
+
+func TestSeriesEviction(t *testing.T) {
+	tests := []struct {
+		name        string
+		hosts       []string // hosts in order of their updates
+		wantSeries  []string
+		wantEvicted uint64
+	}{
+		{"below limit", []string{"a", "b"}, []string{"a", "b"}, 0},
+		{"least recently created", []string{"a", "b", "c"}, []string{"b", "c"}, 1},
+		{"least recently updated", []string{"a", "b", "a", "c"}, []string{"a", "c"}, 1},
+		{"re-created after eviction", []string{"a", "b", "c", "d", "b"}, []string{"b", "d"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStates(t)
+			d, err := New(AnomalyDetectorConfig{
+				MaxSeries: 2,
+				Detections: []AnomalyDetectionConfig{{
+					Name:      "load",
+					Condition: `name == "cpu_load"`,
+				}},
+			})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			for _, host := range tt.hosts {
+				process(t, d, host, 10)
+			}
+			states.mutex.Lock()
+			defer states.mutex.Unlock()
+			if len(states.series) != len(tt.wantSeries) {
+				t.Errorf("got %d series, want %d", len(states.series), len(tt.wantSeries))
+			}
+			for _, host := range tt.wantSeries {
+				key := "load|cpu_load,hostname=" + host + ",type=node"
+				if _, ok := states.series[key]; !ok {
+					t.Errorf("series of host %s was evicted", host)
+				}
+			}
+			if states.evicted != tt.wantEvicted {
+				t.Errorf("got %d evicted series, want %d", states.evicted, tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestStateAcrossRecreation(t *testing.T) {
+	tests := []struct {
+		name       string
+		recreated  AnomalyDetectionConfig
+		wantEvents int
+	}{
+		{
+			name:       "same detection",
+			recreated:  AnomalyDetectionConfig{Name: "load", Condition: `name == "cpu_load"`},
+			wantEvents: 1,
+		},
+		{
+			name:       "changed threshold",
+			recreated:  AnomalyDetectionConfig{Name: "load", Condition: `name == "cpu_load"`, Threshold: 5},
+			wantEvents: 1,
+		},
+		{
+			// A new name starts with a new state in the warmup phase
+			name:       "renamed detection",
+			recreated:  AnomalyDetectionConfig{Name: "load2", Condition: `name == "cpu_load"`},
+			wantEvents: 0,
+		},
+		{
+			// A changed method resets the state
+			name:       "changed method",
+			recreated:  AnomalyDetectionConfig{Name: "load", Condition: `name == "cpu_load"`, Method: "mad"},
+			wantEvents: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStates(t)
+			d, err := New(AnomalyDetectorConfig{
+				Detections: []AnomalyDetectionConfig{{Name: "load", Condition: `name == "cpu_load"`}},
+			})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			for _, v := range baseline(30) {
+				process(t, d, "host1", v)
+			}
+
+			// Re-create the detector like the router after a reconfiguration
+			d, err = New(AnomalyDetectorConfig{Detections: []AnomalyDetectionConfig{tt.recreated}})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			events, _ := countEvents(process(t, d, "host1", 100))
+			if events != tt.wantEvents {
+				t.Errorf("got %d events, want %d", events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestNonFiniteValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		value  float64
+	}{
+		{"ewma NaN", "ewma", math.NaN()},
+		{"ewma +Inf", "ewma", math.Inf(1)},
+		{"ewma -Inf", "ewma", math.Inf(-1)},
+		{"mad NaN", "mad", math.NaN()},
+		{"mad +Inf", "mad", math.Inf(1)},
+		{"mad -Inf", "mad", math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStates(t)
+			d, err := New(AnomalyDetectorConfig{
+				Detections: []AnomalyDetectionConfig{{
+					Name:      "load",
+					Condition: `name == "cpu_load"`,
+					Method:    tt.method,
+					Window:    10,
+					Output:    "both",
+				}},
+			})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			for _, v := range baseline(30) {
+				process(t, d, "host1", v)
+			}
+			// NewMetric() rejects non-finite values, but AddField() does not check them
+			m, err := lp.NewMetric("cpu_load", map[string]string{"type": "node", "hostname": "host1"}, map[string]string{}, 0.0, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.AddField("value", tt.value)
+			if out := d.Process(m); len(out) > 0 {
+				t.Errorf("got %d messages for value %v, want none", len(out), tt.value)
+			}
+
+			// The series is not affected by the skipped value
+			out := process(t, d, "host1", 100)
+			events, scores := countEvents(out)
+			if events != 1 || scores != 1 {
+				t.Fatalf("got %d events and %d scores after value %v, want 1 and 1", events, scores, tt.value)
+			}
+			for _, m := range out {
+				if m.IsEvent() {
+					continue
+				}
+				if score, _ := m.GetField("value"); math.IsNaN(score.(float64)) {
+					t.Errorf("got score NaN after value %v", tt.value)
+				}
+			}
+		})
+	}
+}
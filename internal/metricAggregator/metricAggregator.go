@@ -13,6 +13,7 @@ import (
 	"maps"
 	"math"
 	"os"
+	"regexp"
 	"slices"
 	"strings"
 	"sync"
@@ -27,13 +28,17 @@ import (
 )
 
 type MetricAggregatorIntervalConfig struct {
-	Name      string            `json:"name"`     // Metric name for the new metric
-	Function  string            `json:"function"` // Function to apply on the metric
-	Condition string            `json:"if"`       // Condition for applying function
-	Tags      map[string]string `json:"tags"`     // Tags for the new metric
-	Meta      map[string]string `json:"meta"`     // Meta information for the new metric
-	gvalCond  gval.Evaluable
-	gvalFunc  gval.Evaluable
+	Name             string            `json:"name"`                        // Metric name for the new metric
+	Function         string            `json:"function"`                    // Function to apply on the metric
+	Condition        string            `json:"if"`                          // Condition for applying function
+	Tags             map[string]string `json:"tags"`                        // Tags for the new metric
+	Meta             map[string]string `json:"meta"`                        // Meta information for the new metric
+	PreserveIntegers bool              `json:"preserve_integers,omitempty"` // Provide integer values as integers if all matching values are integers
+	gvalCond         gval.Evaluable
+	gvalFunc         gval.Evaluable
+	skipped          uint64   // Number of matching messages skipped because of missing or invalid values
+	dropped          uint64   // Number of derived messages dropped because the output channel was full
+	names            []string // Metric names required by the condition, nil if the condition may match any name
 }
 
 type metricAggregator struct {
@@ -41,13 +46,26 @@ type metricAggregator struct {
 	constants map[string]any
 	language  gval.Language
 	output    chan lp.CCMessage
+	// Metric names referenced by the conditions of the aggregations and the
+	// aggregations whose conditions may match any metric name. They are updated
+	// when aggregations are added or deleted, so Matches() does not evaluate the
+	// conditions for each message.
+	names   map[string]struct{}
+	anyName []*MetricAggregatorIntervalConfig
+	// Protects the skipped and dropped counters of the aggregations, they are
+	// written by the cache goroutine and read by other goroutines
+	counterMutex sync.Mutex
 }
 
 type MetricAggregator interface {
-	AddAggregation(name, function, condition string, tags, meta map[string]string) error
+	AddAggregation(config MetricAggregatorIntervalConfig) error
 	DeleteAggregation(name string) error
 	Init(output chan lp.CCMessage) error
 	Eval(starttime time.Time, endtime time.Time, metrics []lp.CCMessage)
+	Derive(starttime time.Time, endtime time.Time, metrics []lp.CCMessage) []lp.CCMessage
+	SkippedMessages() map[string]uint64
+	DroppedMessages() map[string]uint64
+	Matches(metric lp.CCMessage) bool
 }
 
 var metricCacheLanguage = gval.NewLanguage(
@@ -65,12 +83,24 @@ var metricCacheLanguage = gval.NewLanguage(
 	gval.Function("getCpuSocket", getCpuSocketFunc),
 	gval.Function("getCpuNuma", getCpuNumaDomainFunc),
 	gval.Function("getCpuDie", getCpuDieFunc),
+	gval.Function("getCpuL3", getCpuL3DomainFunc),
+	gval.Function("getCpuCoreType", getCpuCoreTypeFunc),
+	gval.Function("getCpuCacheSize", getCpuCacheSizeFunc),
 	gval.Function("getSockCpuList", getCpuListOfSocketFunc),
 	gval.Function("getNumaCpuList", getCpuListOfNumaDomainFunc),
 	gval.Function("getDieCpuList", getCpuListOfDieFunc),
 	gval.Function("getCoreCpuList", getCpuListOfCoreFunc),
+	gval.Function("getL3CpuList", getCpuListOfL3DomainFunc),
+	gval.Function("getCoreTypeCpuList", getCpuListOfCoreTypeFunc),
+	gval.Function("getNumaMemory", getNumaMemoryFunc),
+	gval.Function("getNumaDistance", getNumaDistanceFunc),
+	gval.Function("getPciCpuList", getCpuListOfPciDeviceFunc),
+	gval.Function("getPciNuma", getPciDeviceNumaDomainFunc),
+	gval.Function("getPciSocket", getPciDeviceSocketFunc),
 	gval.Function("getCpuList", getCpuListOfNode),
 	gval.Function("getCpuListOfType", getCpuListOfType),
+	gval.Function("parentOf", parentOfFunc),
+	gval.Function("rollup", rollupFunc),
 )
 
 var language gval.Language = gval.NewLanguage(
@@ -89,6 +119,7 @@ func (c *metricAggregator) Init(output chan lp.CCMessage) error {
 	c.output = output
 	c.functions = make([]*MetricAggregatorIntervalConfig, 0)
 	c.constants = make(map[string]any)
+	c.updateNames()
 
 	// add constants like hostname, numSockets, ... to constants list
 	// Set hostname
@@ -122,96 +153,147 @@ func (c *metricAggregator) Init(output chan lp.CCMessage) error {
 	return nil
 }
 
+// Eval evaluates the aggregations on the metrics of an interval and sends the
+// derived messages to the output channel without blocking. Messages that do not
+// fit into the channel are dropped and counted.
 func (c *metricAggregator) Eval(starttime time.Time, endtime time.Time, metrics []lp.CCMessage) {
+	c.evaluate(starttime, endtime, metrics, func(f *MetricAggregatorIntervalConfig, results []lp.CCMessage) {
+		dropped := 0
+		for _, m := range results {
+			cclog.ComponentDebugf("MetricCache", "SEND %s", m.ToLineProtocol(nil))
+			select {
+			case c.output <- m:
+			default:
+				dropped++
+			}
+		}
+
+		// Report dropped messages, the first occurrence as warning, later ones only for debugging
+		if dropped > 0 {
+			c.counterMutex.Lock()
+			if f.dropped == 0 {
+				cclog.ComponentWarnf("MetricCache", "SEND %s dropped %d derived messages because the output channel is full", f.Name, dropped)
+			} else {
+				cclog.ComponentDebugf("MetricCache", "SEND %s dropped %d derived messages because the output channel is full", f.Name, dropped)
+			}
+			f.dropped += uint64(dropped)
+			c.counterMutex.Unlock()
+		}
+	})
+}
+
+// Derive evaluates the aggregations on the metrics of an interval and returns
+// all derived messages instead of sending them to the output channel
+func (c *metricAggregator) Derive(starttime time.Time, endtime time.Time, metrics []lp.CCMessage) []lp.CCMessage {
+	out := make([]lp.CCMessage, 0)
+	c.evaluate(starttime, endtime, metrics, func(f *MetricAggregatorIntervalConfig, results []lp.CCMessage) {
+		out = append(out, results...)
+	})
+	return out
+}
+
+// evaluate evaluates the aggregations on the metrics of an interval and calls
+// send with the derived messages of each aggregation
+func (c *metricAggregator) evaluate(
+	starttime time.Time,
+	endtime time.Time,
+	metrics []lp.CCMessage,
+	send func(f *MetricAggregatorIntervalConfig, results []lp.CCMessage),
+) {
 	vars := make(map[string]any)
 	maps.Copy(vars, c.constants)
 	vars["starttime"] = starttime
 	vars["endtime"] = endtime
 	for _, f := range c.functions {
 		cclog.ComponentDebugf("MetricCache", "COLLECT %s COND '%s'", f.Name, f.Condition)
-		var valuesFloat64 []float64
-		var valuesFloat32 []float32
-		var valuesInt []int
-		var valuesInt32 []int32
-		var valuesInt64 []int64
-		var valuesBool []bool
+		// All values are collected as float64. Integer values are additionally collected
+		// as int64 to provide them as integers if the aggregation requests it
+		valuesFloat64 := make([]float64, 0)
+		valuesInt64 := make([]int64, 0)
+		onlyIntegers := true
+		skipped := 0
 		matches := make([]lp.CCMessage, 0)
 		for _, m := range metrics {
+			if m == nil {
+				continue
+			}
 			vars["metric"] = m
 			value, err := f.gvalCond.EvalBool(context.Background(), vars)
 			if err != nil {
 				cclog.ComponentErrorf("MetricCache", "COLLECT %s COND '%s' : %s", f.Name, f.Condition, err.Error())
 				continue
 			}
-			if value {
-				v, valid := m.GetField("value")
-				if valid {
-					switch x := v.(type) {
-					case float64:
-						valuesFloat64 = append(valuesFloat64, x)
-					case float32:
-						valuesFloat32 = append(valuesFloat32, x)
-					case int:
-						valuesInt = append(valuesInt, x)
-					case int32:
-						valuesInt32 = append(valuesInt32, x)
-					case int64:
-						valuesInt64 = append(valuesInt64, x)
-					case bool:
-						valuesBool = append(valuesBool, x)
-					default:
-						cclog.ComponentErrorf("MetricCache", "COLLECT ADD VALUE %v FAILED", v)
-					}
+			if !value {
+				continue
+			}
+			v, valid := m.GetField("value")
+			if !valid {
+				skipped++
+				continue
+			}
+			switch x := v.(type) {
+			case float64:
+				valuesFloat64 = append(valuesFloat64, x)
+				onlyIntegers = false
+			case float32:
+				valuesFloat64 = append(valuesFloat64, float64(x))
+				onlyIntegers = false
+			case int:
+				valuesFloat64 = append(valuesFloat64, float64(x))
+				valuesInt64 = append(valuesInt64, int64(x))
+			case int32:
+				valuesFloat64 = append(valuesFloat64, float64(x))
+				valuesInt64 = append(valuesInt64, int64(x))
+			case int64:
+				valuesFloat64 = append(valuesFloat64, float64(x))
+				valuesInt64 = append(valuesInt64, x)
+			case uint:
+				valuesFloat64 = append(valuesFloat64, float64(x))
+				valuesInt64 = append(valuesInt64, int64(x))
+			case uint32:
+				valuesFloat64 = append(valuesFloat64, float64(x))
+				valuesInt64 = append(valuesInt64, int64(x))
+			case uint64:
+				valuesFloat64 = append(valuesFloat64, float64(x))
+				if x <= math.MaxInt64 {
+					valuesInt64 = append(valuesInt64, int64(x))
+				} else {
+					onlyIntegers = false
 				}
-				matches = append(matches, m)
+			case bool:
+				// Booleans count as 1 (true) and 0 (false), so sum(values) counts the true values
+				var b int64
+				if x {
+					b = 1
+				}
+				valuesFloat64 = append(valuesFloat64, float64(b))
+				valuesInt64 = append(valuesInt64, b)
+			default:
+				cclog.ComponentDebugf("MetricCache", "COLLECT %s SKIP VALUE %v OF TYPE %T", f.Name, v, v)
+				skipped++
+				continue
 			}
+			matches = append(matches, m)
 		}
 		delete(vars, "metric")
 
-		// Check, that only values of one type were collected
-		countValueTypes := 0
-		if len(valuesFloat64) > 0 {
-			countValueTypes++
-		}
-		if len(valuesFloat32) > 0 {
-			countValueTypes++
-		}
-		if len(valuesInt) > 0 {
-			countValueTypes++
-		}
-		if len(valuesInt32) > 0 {
-			countValueTypes++
-		}
-		if len(valuesInt64) > 0 {
-			countValueTypes++
-		}
-		if len(valuesBool) > 0 {
-			countValueTypes++
-		}
-		if countValueTypes > 1 {
-			cclog.ComponentError("MetricCache", "Collected values of different types")
+		// Report skipped messages, the first occurrence as warning, later ones only for debugging
+		if skipped > 0 {
+			c.counterMutex.Lock()
+			if f.skipped == 0 {
+				cclog.ComponentWarnf("MetricCache", "COLLECT %s skipped %d matching messages without valid value", f.Name, skipped)
+			} else {
+				cclog.ComponentDebugf("MetricCache", "COLLECT %s skipped %d matching messages without valid value", f.Name, skipped)
+			}
+			f.skipped += uint64(skipped)
+			c.counterMutex.Unlock()
 		}
 
-		var len_values int
-		switch {
-		case len(valuesFloat64) > 0:
-			vars["values"] = valuesFloat64
-			len_values = len(valuesFloat64)
-		case len(valuesFloat32) > 0:
-			vars["values"] = valuesFloat32
-			len_values = len(valuesFloat32)
-		case len(valuesInt) > 0:
-			vars["values"] = valuesInt
-			len_values = len(valuesInt)
-		case len(valuesInt32) > 0:
-			vars["values"] = valuesInt32
-			len_values = len(valuesInt32)
-		case len(valuesInt64) > 0:
+		len_values := len(valuesFloat64)
+		if f.PreserveIntegers && onlyIntegers {
 			vars["values"] = valuesInt64
-			len_values = len(valuesInt64)
-		case len(valuesBool) > 0:
-			vars["values"] = valuesBool
-			len_values = len(valuesBool)
+		} else {
+			vars["values"] = valuesFloat64
 		}
 		cclog.ComponentDebugf("MetricCache", "EVALUATE %s METRICS %d CALC '%s'", f.Name, len_values, f.Function)
 
@@ -220,7 +302,7 @@ func (c *metricAggregator) Eval(starttime time.Time, endtime time.Time, metrics
 			value, err := gval.Evaluate(f.Function, vars, c.language)
 			if err != nil {
 				cclog.ComponentErrorf("MetricCache", "EVALUATE %s METRICS %d CALC '%s': %s", f.Name, len_values, f.Function, err.Error())
-				break
+				continue
 			}
 
 			copy_tags := func(tags map[string]string, metrics []lp.CCMessage) map[string]string {
@@ -260,39 +342,46 @@ func (c *metricAggregator) Eval(starttime time.Time, endtime time.Time, metrics
 			tags := copy_tags(f.Tags, matches)
 			meta := copy_meta(f.Meta, matches)
 
-			var m lp.CCMessage
+			results := make([]lp.CCMessage, 0, 1)
 			switch t := value.(type) {
-			case float64:
-				m, err = lp.NewMessage(f.Name, tags, meta, map[string]any{"value": t}, starttime)
-			case float32:
-				m, err = lp.NewMessage(f.Name, tags, meta, map[string]any{"value": t}, starttime)
-			case int:
-				m, err = lp.NewMessage(f.Name, tags, meta, map[string]any{"value": t}, starttime)
-			case int64:
-				m, err = lp.NewMessage(f.Name, tags, meta, map[string]any{"value": t}, starttime)
-			case string:
-				m, err = lp.NewMessage(f.Name, tags, meta, map[string]any{"value": t}, starttime)
-			default:
-				cclog.ComponentErrorf("MetricCache", "Gval returned invalid type %s skipping metric %s", t, f.Name)
-			}
-			if err != nil {
-				cclog.ComponentErrorf("MetricCache", "Cannot create metric from Gval result %v: %s", value, err.Error())
-			}
-			cclog.ComponentDebugf("MetricCache", "SEND %s", m.ToLineProtocol(nil))
-			select {
-			case c.output <- m:
+			case float64, float32, int, int32, int64, uint, uint32, uint64, bool, string:
+				m, err := lp.NewMessage(f.Name, tags, meta, map[string]any{"value": t}, starttime)
+				if err != nil {
+					cclog.ComponentErrorf("MetricCache", "Cannot create metric from Gval result %v: %s", value, err.Error())
+					continue
+				}
+				results = append(results, m)
+			case rollupResult:
+				// One metric for each entity of the topology type
+				for _, id := range slices.Sorted(maps.Keys(t.Values)) {
+					entityTags := maps.Clone(tags)
+					entityTags["type"] = t.Type
+					if t.Type == "node" {
+						delete(entityTags, "type-id")
+					} else {
+						entityTags["type-id"] = id
+					}
+					m, err := lp.NewMessage(f.Name, entityTags, meta, map[string]any{"value": t.Values[id]}, starttime)
+					if err != nil {
+						cclog.ComponentErrorf("MetricCache", "Cannot create metric from Gval result %v: %s", t.Values[id], err.Error())
+						continue
+					}
+					results = append(results, m)
+				}
 			default:
+				cclog.ComponentErrorf("MetricCache", "Gval returned invalid type %T skipping metric %s", t, f.Name)
+				continue
 			}
-
+			send(f, results)
 		}
 	}
 }
 
-func (c *metricAggregator) AddAggregation(name, function, condition string, tags, meta map[string]string) error {
+func (c *metricAggregator) AddAggregation(config MetricAggregatorIntervalConfig) error {
 	// Since "" cannot be used inside of JSON strings, we use '' and replace them here because gval does not like ''
 	// but wants ""
-	newfunc := strings.ReplaceAll(function, "'", "\"")
-	newcond := strings.ReplaceAll(condition, "'", "\"")
+	newfunc := strings.ReplaceAll(config.Function, "'", "\"")
+	newcond := strings.ReplaceAll(config.Condition, "'", "\"")
 	gvalCond, err := gval.Full(metricCacheLanguage).NewEvaluable(newcond)
 	if err != nil {
 		cclog.ComponentErrorf("MetricAggregator", "Cannot add aggregation, invalid if condition '%s': %s", newcond, err.Error())
@@ -304,27 +393,32 @@ func (c *metricAggregator) AddAggregation(name, function, condition string, tags
 		return err
 	}
 	for _, agg := range c.functions {
-		if agg.Name == name {
-			agg.Name = name
+		if agg.Name == config.Name {
 			agg.Condition = newcond
 			agg.Function = newfunc
-			agg.Tags = tags
-			agg.Meta = meta
+			agg.Tags = config.Tags
+			agg.Meta = config.Meta
+			agg.PreserveIntegers = config.PreserveIntegers
 			agg.gvalCond = gvalCond
 			agg.gvalFunc = gvalFunc
+			agg.names = conditionNames(newcond)
+			c.updateNames()
 			return nil
 		}
 	}
 	agg := &MetricAggregatorIntervalConfig{
-		Name:      name,
-		Condition: newcond,
-		gvalCond:  gvalCond,
-		Function:  newfunc,
-		gvalFunc:  gvalFunc,
-		Tags:      tags,
-		Meta:      meta,
+		Name:             config.Name,
+		Condition:        newcond,
+		gvalCond:         gvalCond,
+		Function:         newfunc,
+		gvalFunc:         gvalFunc,
+		Tags:             config.Tags,
+		Meta:             config.Meta,
+		PreserveIntegers: config.PreserveIntegers,
+		names:            conditionNames(newcond),
 	}
 	c.functions = append(c.functions, agg)
+	c.updateNames()
 	return nil
 }
 
@@ -340,9 +434,153 @@ func (c *metricAggregator) DeleteAggregation(name string) error {
 	copy(c.functions[i:], c.functions[i+1:])
 	c.functions[len(c.functions)-1] = nil
 	c.functions = c.functions[:len(c.functions)-1]
+	c.updateNames()
 	return nil
 }
 
+// conditionNameRegex matches a comparison of the metric name with a string
+var conditionNameRegex = regexp.MustCompile(`^(?:metric\.Name\(\)\s*==\s*(?:"([^"]*)"|'([^']*)')|(?:"([^"]*)"|'([^']*)')\s*==\s*metric\.Name\(\))$`)
+
+// trimParentheses removes parentheses enclosing the whole expression
+func trimParentheses(expr string) string {
+	for {
+		expr = strings.TrimSpace(expr)
+		if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+			return expr
+		}
+		// The opening parenthesis must be closed by the last character
+		if _, ok := splitTopLevel(expr[1 : len(expr)-1]); !ok {
+			return expr
+		}
+		expr = expr[1 : len(expr)-1]
+	}
+}
+
+// splitTopLevel splits an expression at the '&&' operators outside of
+// parentheses and string literals. It returns nil if the expression contains
+// an operator with lower precedence than '&&' like '||' or '?:' outside of
+// parentheses, and false if the parentheses or quotes are unbalanced.
+func splitTopLevel(expr string) ([]string, bool) {
+	terms := make([]string, 0)
+	depth := 0
+	start := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+		case depth > 0:
+		case strings.HasPrefix(expr[i:], "&&"):
+			terms = append(terms, expr[start:i])
+			i++
+			start = i + 1
+		case strings.HasPrefix(expr[i:], "||"), c == '?', c == ':':
+			return nil, true
+		}
+	}
+	if depth != 0 || quote != 0 {
+		return nil, false
+	}
+	return append(terms, expr[start:]), true
+}
+
+// conditionNames returns the metric names a condition requires. Only conditions
+// that are a comparison of the metric name with a string or a '&&' chain with
+// such comparisons are analyzed, so a metric has to have one of the names to
+// match. For all other conditions, e.g. with negations, nil is returned.
+func conditionNames(condition string) []string {
+	terms, _ := splitTopLevel(trimParentheses(condition))
+	var names []string
+	for _, term := range terms {
+		term = trimParentheses(term)
+		if match := conditionNameRegex.FindStringSubmatch(term); match != nil {
+			names = append(names, match[1]+match[2]+match[3]+match[4])
+		} else if term != condition {
+			// Parentheses may contain another '&&' chain
+			names = append(names, conditionNames(term)...)
+		}
+	}
+	return names
+}
+
+// updateNames collects the metric names referenced by the aggregations and the
+// aggregations that may match any metric name
+func (c *metricAggregator) updateNames() {
+	c.names = make(map[string]struct{})
+	c.anyName = make([]*MetricAggregatorIntervalConfig, 0)
+	for _, f := range c.functions {
+		if len(f.names) == 0 {
+			c.anyName = append(c.anyName, f)
+			continue
+		}
+		for _, name := range f.names {
+			c.names[name] = struct{}{}
+		}
+	}
+}
+
+// Matches checks whether a metric may match the condition of any aggregation.
+// Metrics with a name referenced by the conditions match without evaluating the
+// conditions, they are checked again in Eval(). The conditions that may match
+// any name are evaluated. If a condition cannot be evaluated, the metric is
+// considered as matching.
+func (c *metricAggregator) Matches(metric lp.CCMessage) bool {
+	if _, ok := c.names[metric.Name()]; ok {
+		return true
+	}
+	if len(c.anyName) == 0 {
+		return false
+	}
+	vars := make(map[string]any, len(c.constants)+1)
+	maps.Copy(vars, c.constants)
+	vars["metric"] = metric
+	for _, f := range c.anyName {
+		value, err := f.gvalCond.EvalBool(context.Background(), vars)
+		if err != nil || value {
+			return true
+		}
+	}
+	return false
+}
+
+// SkippedMessages returns for each aggregation the number of matching messages that were
+// skipped because they had no value or a value of an unsupported type
+func (c *metricAggregator) SkippedMessages() map[string]uint64 {
+	c.counterMutex.Lock()
+	defer c.counterMutex.Unlock()
+	skipped := make(map[string]uint64, len(c.functions))
+	for _, f := range c.functions {
+		skipped[f.Name] = f.skipped
+	}
+	return skipped
+}
+
+// DroppedMessages returns for each aggregation the number of derived messages that
+// were dropped because the output channel was full
+func (c *metricAggregator) DroppedMessages() map[string]uint64 {
+	c.counterMutex.Lock()
+	defer c.counterMutex.Unlock()
+	dropped := make(map[string]uint64, len(c.functions))
+	for _, f := range c.functions {
+		dropped[f.Name] = f.dropped
+	}
+	return dropped
+}
+
 func (c *metricAggregator) AddConstant(name string, value any) {
 	c.constants[name] = value
 }
@@ -355,7 +593,8 @@ func (c *metricAggregator) AddFunction(name string, function func(args ...any) (
 	c.language = gval.NewLanguage(c.language, gval.Function(name, function))
 }
 
-func EvalBoolCondition(condition string, params map[string]any) (bool, error) {
+// getEvaluable returns the compiled condition. The compiled conditions are cached
+func getEvaluable(condition string) (gval.Evaluable, error) {
 	evaluables.mutex.Lock()
 	evaluable, ok := evaluables.mapping[condition]
 	evaluables.mutex.Unlock()
@@ -366,32 +605,34 @@ func EvalBoolCondition(condition string, params map[string]any) (bool, error) {
 		var err error
 		evaluable, err = language.NewEvaluable(newcond)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 		evaluables.mutex.Lock()
 		evaluables.mapping[condition] = evaluable
 		evaluables.mutex.Unlock()
 	}
+	return evaluable, nil
+}
+
+// CheckCondition checks whether a condition can be compiled
+func CheckCondition(condition string) error {
+	_, err := getEvaluable(condition)
+	return err
+}
+
+func EvalBoolCondition(condition string, params map[string]any) (bool, error) {
+	evaluable, err := getEvaluable(condition)
+	if err != nil {
+		return false, err
+	}
 	value, err := evaluable.EvalBool(context.Background(), params)
 	return value, err
 }
 
 func EvalFloat64Condition(condition string, params map[string]float64) (float64, error) {
-	evaluables.mutex.Lock()
-	evaluable, ok := evaluables.mapping[condition]
-	evaluables.mutex.Unlock()
-	if !ok {
-		newcond := strings.ReplaceAll(
-			strings.ReplaceAll(
-				condition, "'", "\""), "%", "\\")
-		var err error
-		evaluable, err = language.NewEvaluable(newcond)
-		if err != nil {
-			return math.NaN(), err
-		}
-		evaluables.mutex.Lock()
-		evaluables.mapping[condition] = evaluable
-		evaluables.mutex.Unlock()
+	evaluable, err := getEvaluable(condition)
+	if err != nil {
+		return math.NaN(), err
 	}
 	value, err := evaluable.EvalFloat64(context.Background(), params)
 	return value, err
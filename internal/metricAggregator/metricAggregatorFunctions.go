@@ -14,7 +14,9 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
 	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
 )
 
@@ -22,7 +24,7 @@ import (
  * Arithmetic functions on value arrays
  */
 
-func sumAnyType[T float64 | float32 | int | int32 | int64](values []T) (T, error) {
+func sumAnyType[T float64 | float32 | int | int32 | int64 | uint64](values []T) (T, error) {
 	if len(values) == 0 {
 		return 0.0, errors.New("sum function requires at least one argument")
 	}
@@ -47,14 +49,16 @@ func sumfunc(args any) (any, error) {
 		return sumAnyType(values)
 	case []int32:
 		return sumAnyType(values)
+	case []uint64:
+		return sumAnyType(values)
 	default:
-		err = errors.New("function 'sum' only on list of values (float64, float32, int, int32, int64)")
+		err = errors.New("function 'sum' only on list of values (float64, float32, int, int32, int64, uint64)")
 	}
 
 	return 0.0, err
 }
 
-func minAnyType[T float64 | float32 | int | int32 | int64](values []T) (T, error) {
+func minAnyType[T float64 | float32 | int | int32 | int64 | uint64](values []T) (T, error) {
 	if len(values) == 0 {
 		return 0.0, errors.New("min function requires at least one argument")
 	}
@@ -74,12 +78,14 @@ func minfunc(args any) (any, error) {
 		return minAnyType(values)
 	case []int32:
 		return minAnyType(values)
+	case []uint64:
+		return minAnyType(values)
 	default:
-		return 0.0, errors.New("function 'min' only on list of values (float64, float32, int, int32, int64)")
+		return 0.0, errors.New("function 'min' only on list of values (float64, float32, int, int32, int64, uint64)")
 	}
 }
 
-func avgAnyType[T float64 | float32 | int | int32 | int64](values []T) (float64, error) {
+func avgAnyType[T float64 | float32 | int | int32 | int64 | uint64](values []T) (float64, error) {
 	if len(values) == 0 {
 		return 0.0, errors.New("average function requires at least one argument")
 	}
@@ -100,12 +106,14 @@ func avgfunc(args any) (any, error) {
 		return avgAnyType(values)
 	case []int32:
 		return avgAnyType(values)
+	case []uint64:
+		return avgAnyType(values)
 	default:
-		return 0.0, errors.New("function 'average' only on list of values (float64, float32, int, int32, int64)")
+		return 0.0, errors.New("function 'average' only on list of values (float64, float32, int, int32, int64, uint64)")
 	}
 }
 
-func maxAnyType[T float64 | float32 | int | int32 | int64](values []T) (T, error) {
+func maxAnyType[T float64 | float32 | int | int32 | int64 | uint64](values []T) (T, error) {
 	if len(values) == 0 {
 		return 0.0, errors.New("max function requires at least one argument")
 	}
@@ -125,18 +133,22 @@ func maxfunc(args any) (any, error) {
 		return maxAnyType(values)
 	case []int32:
 		return maxAnyType(values)
+	case []uint64:
+		return maxAnyType(values)
 	default:
-		return 0.0, errors.New("function 'max' only on list of values (float64, float32, int, int32, int64)")
+		return 0.0, errors.New("function 'max' only on list of values (float64, float32, int, int32, int64, uint64)")
 	}
 }
 
-func medianAnyType[T float64 | float32 | int | int32 | int64](values []T) (T, error) {
+func medianAnyType[T float64 | float32 | int | int32 | int64 | uint64](values []T) (T, error) {
 	if len(values) == 0 {
 		return 0.0, errors.New("median function requires at least one argument")
 	}
+	// sort a copy to keep the order of the values for other functions
+	values = slices.Clone(values)
 	slices.Sort(values)
 	var median T
-	if midPoint := len(values) % 2; midPoint == 0 {
+	if midPoint := len(values) / 2; len(values)%2 == 0 {
 		median = (values[midPoint-1] + values[midPoint]) / 2
 	} else {
 		median = values[midPoint]
@@ -157,8 +169,10 @@ func medianfunc(args any) (any, error) {
 		return medianAnyType(values)
 	case []int32:
 		return medianAnyType(values)
+	case []uint64:
+		return medianAnyType(values)
 	default:
-		return 0.0, errors.New("function 'median' only on list of values (float64, float32, int, int32, int64)")
+		return 0.0, errors.New("function 'median' only on list of values (float64, float32, int, int32, int64, uint64)")
 	}
 }
 
@@ -180,7 +194,11 @@ func lenfunc(args any) (any, error) {
 		length = len(values)
 	case []int32:
 		length = len(values)
-	case float64, float32, int, int64:
+	case []uint64:
+		length = len(values)
+	case []bool:
+		length = len(values)
+	case float64, float32, int, int64, uint64, bool:
 		err = errors.New("function 'len' can only be applied on arrays and strings")
 	case string:
 		length = len(values)
@@ -279,6 +297,119 @@ func getCpuDieFunc(args any) (any, error) {
 	return -1, errors.New("function 'getCpuDie' accepts only an 'int' cpuid")
 }
 
+// topologyId converts a numeric argument of a topology function to an int.
+// Numbers in expressions are float64 values.
+func topologyId(arg any) (int, bool) {
+	switch x := arg.(type) {
+	case int:
+		return x, true
+	case int64:
+		return int(x), true
+	case float64:
+		if x == float64(int(x)) {
+			return int(x), true
+		}
+	case string:
+		if n, err := strconv.Atoi(x); err == nil {
+			return n, true
+		}
+	}
+	return -1, false
+}
+
+// for a given cpuid, it returns the id of the L3 cache
+func getCpuL3DomainFunc(args any) (any, error) {
+	if cpuid, ok := topologyId(args); ok {
+		return topo.GetHwthreadL3Domain(cpuid), nil
+	}
+	return -1, errors.New("function 'getCpuL3' accepts only an 'int' cpuid")
+}
+
+// for a given cpuid, it returns the core type (0: performance, 1: efficiency)
+func getCpuCoreTypeFunc(args any) (any, error) {
+	if cpuid, ok := topologyId(args); ok {
+		return topo.GetHwthreadCoreType(cpuid), nil
+	}
+	return -1, errors.New("function 'getCpuCoreType' accepts only an 'int' cpuid")
+}
+
+// for a given id of an L3 cache, it returns the list of cpuids
+func getCpuListOfL3DomainFunc(args any) (any, error) {
+	if id, ok := topologyId(args); ok {
+		return topo.GetL3DomainHwthreads(id), nil
+	}
+	return []int{}, nil
+}
+
+// for a given core type, it returns the list of cpuids
+func getCpuListOfCoreTypeFunc(args any) (any, error) {
+	if id, ok := topologyId(args); ok {
+		return topo.GetCoreTypeHwthreads(id), nil
+	}
+	return []int{}, nil
+}
+
+// for a given cpuid and cache level, it returns the size of the data or unified cache in bytes
+func getCpuCacheSizeFunc(args ...any) (any, error) {
+	if len(args) != 2 {
+		return int64(-1), errors.New("function 'getCpuCacheSize' requires a cpuid and a cache level")
+	}
+	cpuid, ok1 := topologyId(args[0])
+	level, ok2 := topologyId(args[1])
+	if !ok1 || !ok2 {
+		return int64(-1), errors.New("function 'getCpuCacheSize' accepts only 'int' cpuid and cache level")
+	}
+	return topo.GetHwthreadCacheSize(cpuid, level), nil
+}
+
+// for a given id of a NUMA domain, it returns the memory size in bytes
+func getNumaMemoryFunc(args any) (any, error) {
+	if id, ok := topologyId(args); ok {
+		return topo.GetNumaNodeMemory(id), nil
+	}
+	return int64(-1), errors.New("function 'getNumaMemory' accepts only an 'int' NUMA domain id")
+}
+
+// for two ids of NUMA domains, it returns the distance between them
+func getNumaDistanceFunc(args ...any) (any, error) {
+	if len(args) != 2 {
+		return -1, errors.New("function 'getNumaDistance' requires two NUMA domain ids")
+	}
+	from, ok1 := topologyId(args[0])
+	to, ok2 := topologyId(args[1])
+	if !ok1 || !ok2 {
+		return -1, errors.New("function 'getNumaDistance' accepts only 'int' NUMA domain ids")
+	}
+	return topo.GetNumaDistance(from, to), nil
+}
+
+// for a given PCI address of a device like a GPU, it returns the list of local cpuids
+func getCpuListOfPciDeviceFunc(args any) (any, error) {
+	switch address := args.(type) {
+	case string:
+		return topo.GetPciDeviceLocalCPUs(address), nil
+	}
+	return []int{}, errors.New("function 'getPciCpuList' accepts only a 'string' PCI address")
+}
+
+// for a given PCI address of a device like a GPU, it returns the id of the NUMA domain
+func getPciDeviceNumaDomainFunc(args any) (any, error) {
+	switch address := args.(type) {
+	case string:
+		return topo.GetPciDeviceNumaDomain(address), nil
+	}
+	return -1, errors.New("function 'getPciNuma' accepts only a 'string' PCI address")
+}
+
+// for a given PCI address of a device like a GPU, it returns the id of the CPU socket
+func getPciDeviceSocketFunc(args any) (any, error) {
+	switch address := args.(type) {
+	case string:
+		return topo.GetPciDeviceSocket(address), nil
+	}
+	return -1, errors.New("function 'getPciSocket' accepts only a 'string' PCI address")
+}
+
 // for a given core id, it returns the list of cpuids
 func getCpuListOfCoreFunc(args any) (any, error) {
 	cpulist := make([]int, 0)
@@ -344,34 +475,303 @@ func getCpuListOfNode() (any, error) {
 // since there is no access to the metric data in the function, is should be called like
 // `getCpuListOfType()`
 func getCpuListOfType(args ...any) (any, error) {
-	cpulist := make([]int, 0)
-	switch typ := args[0].(type) {
+	if len(args) != 2 {
+		return []int{}, errors.New("function 'getCpuListOfType' requires the type and the type-id")
+	}
+	typ, ok := args[0].(string)
+	if !ok {
+		return []int{}, errors.New("function 'getCpuListOfType' requires the type as string")
+	}
+	if typ == "numadomain" {
+		typ = "memoryDomain"
+	}
+	var id string
+	switch x := args[1].(type) {
 	case string:
-		switch typ {
-		case "node":
-			return topo.HwthreadList(), nil
-		case "socket":
-			return getCpuListOfSocketFunc(args[1])
-		case "numadomain":
-			return getCpuListOfNumaDomainFunc(args[1])
-		case "core":
-			return getCpuListOfCoreFunc(args[1])
-		case "hwthread":
-			var cpu int
-
-			switch id := args[1].(type) {
-			case string:
-				_, err := fmt.Scanf(id, "%d", &cpu)
-				if err == nil {
-					cpulist = append(cpulist, cpu)
-				}
-			case int:
-				cpulist = append(cpulist, id)
-			case int64:
-				cpulist = append(cpulist, int(id))
+		id = x
+	case int, int64, float64:
+		id = fmt.Sprint(x)
+	default:
+		return []int{}, errors.New("no valid args type and type-id")
+	}
+	cpulist, err := hwthreadsOfType(typ, id)
+	if err != nil {
+		return []int{}, err
+	}
+	return cpulist, nil
+}
+
+/*
+ * Hierarchical topology functions
+ */
+
+// Result of a rollup: one value per entity of the topology type. The MetricAggregator
+// creates a metric for each entity with the tags 'type' and 'type-id'
+type rollupResult struct {
+	Type   string
+	Values map[string]any
+}
+
+// coreOfHwthread returns the ID used for the core of a hardware thread. Like in the
+// LIKWID collector, it is the ID of the first hardware thread of the core because the
+// core IDs in sysfs are only unique within a socket
+func coreOfHwthread(hwt topo.HwthreadEntry) int {
+	if len(hwt.CoreCPUsList) > 0 {
+		return hwt.CoreCPUsList[0]
+	}
+	return hwt.CpuID
+}
+
+// hwthreadsOfType returns the hardware threads of a topology entity given by the type
+// and type-id tags of a metric. Accelerators are mapped to their local hardware threads
+// or the hardware threads of their NUMA domain, so the type-id must be the PCI address.
+func hwthreadsOfType(typ string, id string) ([]int, error) {
+	if typ == "node" {
+		return topo.HwthreadList(), nil
+	}
+	if typ == "accelerator" {
+		if cpulist := topo.GetPciDeviceLocalCPUs(id); len(cpulist) > 0 {
+			return cpulist, nil
+		}
+		numaDomain := topo.GetPciDeviceNumaDomain(id)
+		if numaDomain < 0 {
+			return nil, fmt.Errorf("no NUMA domain for accelerator '%s' (type-id must be a PCI address)", id)
+		}
+		return topo.GetNumaDomainHwthreads(numaDomain), nil
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type-id '%s' for type '%s'", id, typ)
+	}
+	var cpulist []int
+	switch typ {
+	case "hwthread":
+		if slices.Contains(topo.HwthreadList(), n) {
+			cpulist = []int{n}
+		}
+	case "core":
+		for _, c := range topo.CpuData() {
+			if coreOfHwthread(c) == n {
+				cpulist = append(cpulist, c.CpuID)
+			}
+		}
+		// Fall back to the socket local core IDs from sysfs
+		if len(cpulist) == 0 {
+			cpulist = topo.GetCoreHwthreads(n)
+		}
+	case "socket", "die", "memoryDomain", "l3Domain", "coreType":
+		cpulist = topo.GetTypeHwthreads(typ, n)
+	default:
+		return nil, fmt.Errorf("unknown topology type '%s'", typ)
+	}
+	if len(cpulist) == 0 {
+		return nil, fmt.Errorf("no hardware threads for type '%s' with type-id '%s'", typ, id)
+	}
+	return cpulist, nil
+}
+
+// hwthreadParents caches for each target type the type-id of the parent entity of
+// every hardware thread. The cache is rebuilt when the topology generation changes,
+// so parentId does not scan the topology for every message.
+var hwthreadParents = struct {
+	mutex      sync.Mutex
+	generation uint64
+	parents    map[string]map[int]int // target type -> hardware thread -> parent type-id
+}{}
+
+// parentOfHwthread returns the type-id of the entity of the target type that
+// contains the hardware thread
+func parentOfHwthread(cpu int, target string) (int, error) {
+	hwthreadParents.mutex.Lock()
+	defer hwthreadParents.mutex.Unlock()
+
+	if generation := topo.Generation(); hwthreadParents.parents == nil || hwthreadParents.generation != generation {
+		hwthreadParents.generation = generation
+		hwthreadParents.parents = make(map[string]map[int]int)
+	}
+	parents, ok := hwthreadParents.parents[target]
+	if !ok {
+		cpuData := topo.CpuData()
+		parents = make(map[int]int, len(cpuData))
+		for _, c := range cpuData {
+			if target == "core" {
+				parents[c.CpuID] = coreOfHwthread(c)
+				continue
+			}
+			p, err := topo.GetTypeId(c, target)
+			if err != nil {
+				return -1, err
 			}
+			parents[c.CpuID] = p
+		}
+		hwthreadParents.parents[target] = parents
+	}
+	p, ok := parents[cpu]
+	if !ok {
+		return -1, fmt.Errorf("unknown hardware thread %d", cpu)
+	}
+	return p, nil
+}
 
+// parentId returns the type-id of the entity of the target type that contains the
+// entity given by type and type-id. An error is returned if the entity is not part
+// of a single entity of the target type, e.g. a socket metric has no parent core.
+func parentId(typ string, id string, target string) (string, error) {
+	if typ == "numadomain" {
+		typ = "memoryDomain"
+	}
+	if target == "numadomain" || target == "numa" {
+		target = "memoryDomain"
+	}
+	switch {
+	case typ == target:
+		return id, nil
+	case target == "node":
+		return "0", nil
+	case target == "accelerator":
+		return "", fmt.Errorf("type '%s' has no parent of type 'accelerator'", typ)
+	}
+	cpulist, err := hwthreadsOfType(typ, id)
+	if err != nil {
+		return "", err
+	}
+	parent := -1
+	for _, cpu := range cpulist {
+		p, err := parentOfHwthread(cpu, target)
+		if err != nil {
+			return "", err
+		}
+		if parent >= 0 && p != parent {
+			return "", fmt.Errorf("type '%s' with type-id '%s' spans multiple entities of type '%s'", typ, id, target)
+		}
+		parent = p
+	}
+	return strconv.Itoa(parent), nil
+}
+
+// metricTypeAndId returns the type and type-id tags of a metric. Metrics without type
+// are node metrics
+func metricTypeAndId(m lp.CCMessage) (string, string) {
+	typ, ok := m.GetTag("type")
+	if !ok {
+		return "node", "0"
+	}
+	id, _ := m.GetTag("type-id")
+	return typ, id
+}
+
+// for a given metric and topology type, it returns the type-id of the entity containing
+// the metric, e.g. parentOf(metric, "socket") for a hwthread metric returns the socket
+func parentOfFunc(args ...any) (any, error) {
+	if len(args) != 2 {
+		return -1, errors.New("function 'parentOf' requires a metric and a topology type")
+	}
+	m, ok := args[0].(lp.CCMessage)
+	if !ok {
+		return -1, errors.New("function 'parentOf' requires a metric as first argument")
+	}
+	target, ok := args[1].(string)
+	if !ok {
+		return -1, errors.New("function 'parentOf' requires a topology type as second argument")
+	}
+	typ, id := metricTypeAndId(m)
+	parent, err := parentId(typ, id, target)
+	if err != nil {
+		return -1, err
+	}
+	if n, err := strconv.Atoi(parent); err == nil {
+		return n, nil
+	}
+	return parent, nil
+}
+
+// Aggregate the values of metrics per entity of a topology type, e.g.
+// rollup(metrics, "socket", "sum") sums up the values of all hwthread and core metrics
+// per socket. Metrics which are not part of a single entity of the topology type are
+// skipped.
+func rollupFunc(args ...any) (any, error) {
+	if len(args) != 3 {
+		return nil, errors.New("function 'rollup' requires metrics, a topology type and a function name")
+	}
+	metrics, ok := args[0].([]lp.CCMessage)
+	if !ok {
+		return nil, errors.New("function 'rollup' requires a list of metrics as first argument")
+	}
+	target, ok := args[1].(string)
+	if !ok {
+		return nil, errors.New("function 'rollup' requires a topology type as second argument")
+	}
+	fname, ok := args[2].(string)
+	if !ok {
+		return nil, errors.New("function 'rollup' requires a function name as third argument")
+	}
+	var f func(args any) (any, error)
+	switch fname {
+	case "sum":
+		f = sumfunc
+	case "min":
+		f = minfunc
+	case "max":
+		f = maxfunc
+	case "avg", "mean":
+		f = avgfunc
+	case "median":
+		f = medianfunc
+	case "len":
+		f = lenfunc
+	default:
+		return nil, fmt.Errorf("function 'rollup' does not support function '%s'", fname)
+	}
+	if target == "numadomain" || target == "numa" {
+		target = "memoryDomain"
+	}
+
+	groups := make(map[string][]float64)
+	for _, m := range metrics {
+		v, ok := m.GetField("value")
+		if !ok {
+			continue
+		}
+		var value float64
+		switch x := v.(type) {
+		case float64:
+			value = x
+		case float32:
+			value = float64(x)
+		case int:
+			value = float64(x)
+		case int64:
+			value = float64(x)
+		case uint64:
+			value = float64(x)
+		case bool:
+			if x {
+				value = 1
+			}
+		default:
+			continue
+		}
+		typ, id := metricTypeAndId(m)
+		parent, err := parentId(typ, id, target)
+		if err != nil {
+			continue
+		}
+		groups[parent] = append(groups[parent], value)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("function 'rollup' found no metrics for type '%s'", target)
+	}
+
+	result := rollupResult{
+		Type:   target,
+		Values: make(map[string]any, len(groups)),
+	}
+	for id, values := range groups {
+		value, err := f(values)
+		if err != nil {
+			return nil, err
 		}
+		result.Values[id] = value
 	}
-	return cpulist, errors.New("no valid args type and type-id")
+	return result, nil
 }
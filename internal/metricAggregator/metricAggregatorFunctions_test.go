@@ -0,0 +1,118 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package metricAggregator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	topo "github.com/ClusterCockpit/cc-metric-collector/pkg/ccTopology"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  any
+		want    any
+		wantErr bool
+	}{
+		{"single value", []float64{4}, 4.0, false},
+		{"odd length", []float64{5, 1, 3}, 3.0, false},
+		{"odd length unsorted", []float64{9, 7, 1, 3, 5}, 5.0, false},
+		{"even length", []float64{4, 1, 3, 2}, 2.5, false},
+		{"even length of six", []float64{6, 5, 4, 3, 2, 1}, 3.5, false},
+		{"int64", []int64{7, 1, 5}, int64(5), false},
+		{"uint64 even length", []uint64{8, 2, 4, 6}, uint64(5), false},
+		{"empty", []float64{}, nil, true},
+		{"no list", 1.0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := medianfunc(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("medianfunc() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("medianfunc() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+// The values are shared with the other functions of an aggregation, so
+// median must not sort them in place
+func TestMedianKeepsOrder(t *testing.T) {
+	values := []float64{3, 1, 2}
+	if _, err := medianfunc(values); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{3, 1, 2}; !slices.Equal(values, want) {
+		t.Errorf("values changed to %v, want %v", values, want)
+	}
+}
+
+// writeTwoSocketSysfs creates a sysfs tree of a node with two sockets of two
+// cores each without SMT. die_id is 0 on both sockets like on most x86 nodes.
+func writeTwoSocketSysfs(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	write := func(path string, value string) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("devices/system/cpu/online", "0-3")
+	for cpu := range 4 {
+		base := fmt.Sprintf("devices/system/cpu/cpu%d", cpu)
+		socket := cpu / 2
+		write(base+"/topology/physical_package_id", fmt.Sprint(socket))
+		write(base+"/topology/die_id", "0")
+		write(base+"/topology/core_id", fmt.Sprint(cpu%2))
+		write(base+"/topology/core_cpus_list", fmt.Sprint(cpu))
+		if err := os.MkdirAll(filepath.Join(root, base, fmt.Sprintf("node%d", socket)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// The dies of both sockets are different entities for the topology functions
+func TestDieOfTwoSockets(t *testing.T) {
+	if err := hostfs.Init(hostfs.HostfsConfig{SysRoot: writeTwoSocketSysfs(t)}); err != nil {
+		t.Fatal(err)
+	}
+	topo.Init()
+	defer func() {
+		if err := hostfs.Init(hostfs.HostfsConfig{}); err != nil {
+			t.Error(err)
+		}
+		topo.Init()
+	}()
+
+	for cpu, want := range []string{"0", "0", "1", "1"} {
+		if got, err := parentId("hwthread", fmt.Sprint(cpu), "die"); err != nil || got != want {
+			t.Errorf("parentId(hwthread, %d, die) = %s, %v, want %s", cpu, got, err, want)
+		}
+	}
+	if got, err := parentId("socket", "1", "die"); err != nil || got != "1" {
+		t.Errorf("parentId(socket, 1, die) = %s, %v, want 1", got, err)
+	}
+	for die, want := range [][]int{{0, 1}, {2, 3}} {
+		if got, err := hwthreadsOfType("die", fmt.Sprint(die)); err != nil || !slices.Equal(got, want) {
+			t.Errorf("hwthreadsOfType(die, %d) = %v, %v, want %v", die, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package metricAggregator
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+)
+
+func TestEvalBoolCondition(t *testing.T) {
+	params := map[string]any{
+		"name":  "temp_core_12",
+		"type":  "hwthread",
+		"value": 42.5,
+		"count": 3,
+	}
+	tests := []struct {
+		condition string
+		want      bool
+		wantErr   bool
+	}{
+		{`name == "temp_core_12"`, true, false},
+		{`name == "temp_core_1"`, false, false},
+		{`name != "temp_core_1"`, true, false},
+		{`match("temp_core_[0-9]+", name)`, true, false},
+		{`match("^cpu_", name)`, false, false},
+		{`type == "hwthread" && value > 40`, true, false},
+		{`type == "socket" || value < 40`, false, false},
+		{`value >= 42.5 && count == 3`, true, false},
+		// 'in' checks for substrings
+		{`"core" in name`, true, false},
+		{`"socket" in name`, false, false},
+		{`!(type == "node")`, true, false},
+		{`name ==`, false, true},
+		{`undefinedFunction(name)`, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			got, err := EvalBoolCondition(tt.condition, params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBoolCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("EvalBoolCondition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionNames(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      []string
+	}{
+		{`metric.Name() == "flops_any"`, []string{"flops_any"}},
+		{`"flops_any" == metric.Name()`, []string{"flops_any"}},
+		{`metric.Name() == "mem_bw" && parentOf(metric, "socket") == 0`, []string{"mem_bw"}},
+		{`metric.Name() == "a" && metric.Name() == "b"`, []string{"a", "b"}},
+		{`metric.Name() == "a" && metric.Tags()["type"] != "node"`, []string{"a"}},
+		{`metric.Name() == 'a'`, []string{"a"}},
+		{`'a' == metric.Name() && metric.Tags()['type'] == 'socket'`, []string{"a"}},
+		{`(metric.Name() == "a")`, []string{"a"}},
+		{`(metric.Name() == "a" && value > 0) && (metric.Tags()["type"] == "node")`, []string{"a"}},
+		{`metric.Name() == "a" && metric.Tags()["x"] == "b || c"`, []string{"a"}},
+		{`metric.Name() == "a && b"`, []string{"a && b"}},
+		// Conditions that may match any name
+		{`metric.Name() == "a" || metric.Name() == "b"`, nil},
+		{`metric.Name() == "a" && value > 0 || value < 0`, nil},
+		{`!(metric.Name() == "a")`, nil},
+		{`!(metric.Name() == "a" && value > 0)`, nil},
+		{`(metric.Name() == "a") == false`, nil},
+		{`(metric.Name() == "a" && value > 0) == false`, nil},
+		{`metric.Name() == "a" == false`, nil},
+		{`metric.Name() == "a" ? false : true`, nil},
+		{`metric.Name() == "a" && value > 0 ? false : true`, nil},
+		{`metric.Name() != "a"`, nil},
+		{`metric.Name() == "a" + "b"`, nil},
+		{`match("temp_core_%d+", metric.Name())`, nil},
+		{`true`, nil},
+		{`(metric.Name() == "a"`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			if got := conditionNames(tt.condition); !slices.Equal(got, tt.want) {
+				t.Errorf("conditionNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Metrics matching a condition must pass the name filter of the aggregator
+func TestMatchesNegatedCondition(t *testing.T) {
+	tests := []struct {
+		condition string
+		name      string
+		want      bool
+	}{
+		{`metric.Name() == 'a'`, "a", true},
+		{`metric.Name() == 'a'`, "b", false},
+		{`(metric.Name() == 'a') == false`, "b", true},
+		{`metric.Name() == 'a' ? false : true`, "b", true},
+		{`metric.Name() == 'a' && true ? false : true`, "b", true},
+		{`!(metric.Name() == 'a')`, "b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			a, err := NewAggregator(make(chan lp.CCMessage, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := a.AddAggregation(MetricAggregatorIntervalConfig{
+				Name:      "test_agg",
+				Condition: tt.condition,
+				Function:  "sum(values)",
+			}); err != nil {
+				t.Fatalf("AddAggregation() failed: %v", err)
+			}
+			m, err := lp.NewMetric(tt.name, map[string]string{"type": "node"}, nil, 1.0, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Matches(m); got != tt.want {
+				t.Errorf("Matches(%s) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -24,21 +24,25 @@ type metricCachePeriod struct {
 	stopstamp   time.Time
 	numMetrics  int
 	sizeMetrics int
+	numEvicted  int // number of messages not stored because the period was full
 	metrics     []lp.CCMessage
 }
 
 // Metric cache data structure
 type metricCache struct {
-	numPeriods int
-	curPeriod  int
-	lock       sync.Mutex
-	intervals  []*metricCachePeriod
-	wg         *sync.WaitGroup
-	ticker     mct.MultiChanTicker
-	tickchan   chan time.Time
-	done       chan bool
-	output     chan lp.CCMessage
-	aggEngine  agg.MetricAggregator
+	numPeriods  int
+	curPeriod   int
+	lock        sync.Mutex
+	intervals   []*metricCachePeriod
+	wg          *sync.WaitGroup
+	ticker      mct.MultiChanTicker
+	tickchan    chan time.Time
+	done        chan bool
+	output      chan lp.CCMessage
+	aggEngine   agg.MetricAggregator
+	filter      string // condition for messages to store, if empty the conditions of the aggregations are used
+	maxMessages int    // maximal number of messages per period, 0 for no limit
+	evicted     uint64 // total number of messages not stored because a period was full
 }
 
 type MetricCache interface {
@@ -46,8 +50,13 @@ type MetricCache interface {
 	Start()
 	Add(metric lp.CCMessage)
 	GetPeriod(index int) (time.Time, time.Time, []lp.CCMessage)
-	AddAggregation(name, function, condition string, tags, meta map[string]string) error
+	AddAggregation(config agg.MetricAggregatorIntervalConfig) error
 	DeleteAggregation(name string) error
+	SetFilter(condition string) error
+	SetMaxMessages(maxMessages int)
+	EvictedMessages() uint64
+	SkippedMessages() map[string]uint64
+	DroppedMessages() map[string]uint64
 	Close()
 }
 
@@ -58,6 +67,7 @@ func (c *metricCache) Init(output chan lp.CCMessage, ticker mct.MultiChanTicker,
 	c.ticker = ticker
 	c.numPeriods = numPeriods
 	c.output = output
+	// The intervals list holds the current interval and the numPeriods finished intervals
 	c.intervals = make([]*metricCachePeriod, 0)
 	for i := 0; i < c.numPeriods+1; i++ {
 		p := new(metricCachePeriod)
@@ -66,6 +76,7 @@ func (c *metricCache) Init(output chan lp.CCMessage, ticker mct.MultiChanTicker,
 		p.metrics = make([]lp.CCMessage, 0)
 		c.intervals = append(c.intervals, p)
 	}
+	c.setStart(time.Now())
 
 	// Create a new aggregation engine. No separate goroutine at the moment
 	// The code is executed by the MetricCache goroutine
@@ -81,26 +92,13 @@ func (c *metricCache) Init(output chan lp.CCMessage, ticker mct.MultiChanTicker,
 func (c *metricCache) Start() {
 	c.tickchan = make(chan time.Time)
 	c.ticker.AddChannel(c.tickchan)
+	c.setStart(time.Now())
 	// Router cache is done
 	done := func() {
 		cclog.ComponentDebug("MetricCache", "DONE")
 		close(c.done)
 	}
 
-	// Rotate cache interval
-	rotate := func(timestamp time.Time) int {
-		oldPeriod := c.curPeriod
-		c.curPeriod = oldPeriod + 1
-		if c.curPeriod >= c.numPeriods {
-			c.curPeriod = 0
-		}
-		c.intervals[oldPeriod].numMetrics = 0
-		c.intervals[oldPeriod].stopstamp = timestamp
-		c.intervals[c.curPeriod].startstamp = timestamp
-		c.intervals[c.curPeriod].stopstamp = timestamp
-		return oldPeriod
-	}
-
 	c.wg.Go(func() {
 		for {
 			select {
@@ -108,73 +106,185 @@ func (c *metricCache) Start() {
 				done()
 				return
 			case tick := <-c.tickchan:
-				c.lock.Lock()
-				old := rotate(tick)
-				// Get the last period and evaluate aggregation metrics
-				starttime, endtime, metrics := c.GetPeriod(old)
-				c.lock.Unlock()
-				if len(metrics) > 0 {
-					c.aggEngine.Eval(starttime, endtime, metrics)
-				} else {
-					// This message is also printed in the first interval after startup
-					cclog.ComponentDebug("MetricCache", "EMPTY INTERVAL?")
-				}
+				c.tick(tick)
 			}
 		}
 	})
 	cclog.ComponentDebug("MetricCache", "START")
 }
 
+// setStart sets the start of the current interval
+func (c *metricCache) setStart(timestamp time.Time) {
+	c.lock.Lock()
+	c.intervals[c.curPeriod].startstamp = timestamp
+	c.intervals[c.curPeriod].stopstamp = timestamp
+	c.lock.Unlock()
+}
+
+// rotate switches to the next cache interval and returns the index of the previous one.
+// It requires the lock to be held
+func (c *metricCache) rotate(timestamp time.Time) int {
+	oldPeriod := c.curPeriod
+	c.curPeriod = oldPeriod + 1
+	if c.curPeriod >= len(c.intervals) {
+		c.curPeriod = 0
+	}
+	o := c.intervals[oldPeriod]
+	o.stopstamp = timestamp
+	if o.numEvicted > 0 {
+		c.evicted += uint64(o.numEvicted)
+		if c.evicted == uint64(o.numEvicted) {
+			cclog.ComponentWarnf("MetricCache", "Interval reached maximal number of %d messages, evicted %d messages", c.maxMessages, o.numEvicted)
+		} else {
+			cclog.ComponentDebugf("MetricCache", "Interval reached maximal number of %d messages, evicted %d messages (%d in total)", c.maxMessages, o.numEvicted, c.evicted)
+		}
+	}
+
+	// Reuse the oldest interval. Drop the references to the old messages, so
+	// they can be freed
+	p := c.intervals[c.curPeriod]
+	clear(p.metrics[:p.numMetrics])
+	p.numMetrics = 0
+	p.numEvicted = 0
+	p.startstamp = timestamp
+	p.stopstamp = timestamp
+	return oldPeriod
+}
+
+// tick finishes the current cache interval and evaluates the aggregation metrics
+// on the metrics of the last interval. It is called by the MetricCache goroutine
+// for each tick of the ticker
+func (c *metricCache) tick(timestamp time.Time) {
+	starttime, endtime, metrics := c.finish(timestamp)
+	if len(metrics) > 0 {
+		c.aggEngine.Eval(starttime, endtime, metrics)
+	} else {
+		// This message is also printed in the first interval after startup
+		cclog.ComponentDebug("MetricCache", "EMPTY INTERVAL?")
+	}
+}
+
+// derive finishes the current cache interval like tick but returns the derived
+// messages of the aggregations instead of sending them to the output channel
+func (c *metricCache) derive(timestamp time.Time) []lp.CCMessage {
+	starttime, endtime, metrics := c.finish(timestamp)
+	if len(metrics) == 0 {
+		return []lp.CCMessage{}
+	}
+	return c.aggEngine.Derive(starttime, endtime, metrics)
+}
+
+// finish starts a new cache interval and returns the finished one. The finished
+// interval is not modified by Add() until it gets reused after numPeriods ticks
+func (c *metricCache) finish(timestamp time.Time) (time.Time, time.Time, []lp.CCMessage) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rotate(timestamp)
+	return c.GetPeriod(1)
+}
+
+// accept checks whether a metric should be stored in the cache. If no filter is
+// set, only metrics used by any of the aggregations are stored.
+func (c *metricCache) accept(metric lp.CCMessage) bool {
+	if len(c.filter) == 0 {
+		return c.aggEngine.Matches(metric)
+	}
+	value, err := agg.EvalBoolCondition(c.filter, getParamMap(metric))
+	if err != nil {
+		cclog.ComponentErrorf("MetricCache", "Evaluation of filter '%s' failed: %s", c.filter, err.Error())
+		return true
+	}
+	return value
+}
+
 // Add a metric to the cache. The interval is defined by the global timer (rotate() in Start())
 // The intervals list is used as round-robin buffer and the metric list grows dynamically and
-// to avoid reallocations
+// to avoid reallocations. Metrics not matching the filter are skipped and at most maxMessages
+// metrics are stored per interval.
 func (c *metricCache) Add(metric lp.CCMessage) {
-	if c.curPeriod >= 0 && c.curPeriod < c.numPeriods {
-		c.lock.Lock()
-		p := c.intervals[c.curPeriod]
-		if p.numMetrics < p.sizeMetrics {
-			p.metrics[p.numMetrics] = metric
-			p.numMetrics++
-			p.stopstamp = metric.Time()
-		} else {
-			p.metrics = append(p.metrics, metric)
-			p.numMetrics++
-			p.sizeMetrics++
-			p.stopstamp = metric.Time()
-		}
-		c.lock.Unlock()
+	if metric == nil || !c.accept(metric) {
+		return
+	}
+	c.lock.Lock()
+	p := c.intervals[c.curPeriod]
+	if c.maxMessages > 0 && p.numMetrics >= c.maxMessages {
+		p.numEvicted++
+	} else if p.numMetrics < p.sizeMetrics {
+		p.metrics[p.numMetrics] = metric
+		p.numMetrics++
+		p.stopstamp = metric.Time()
+	} else {
+		p.metrics = append(p.metrics, metric)
+		p.numMetrics++
+		p.sizeMetrics++
+		p.stopstamp = metric.Time()
 	}
+	c.lock.Unlock()
 }
 
-func (c *metricCache) AddAggregation(name, function, condition string, tags, meta map[string]string) error {
-	return c.aggEngine.AddAggregation(name, function, condition, tags, meta)
+func (c *metricCache) AddAggregation(config agg.MetricAggregatorIntervalConfig) error {
+	return c.aggEngine.AddAggregation(config)
 }
 
 func (c *metricCache) DeleteAggregation(name string) error {
 	return c.aggEngine.DeleteAggregation(name)
 }
 
+// SetFilter sets the condition for metrics to store in the cache. With an empty
+// condition, the metrics matching the conditions of the aggregations are stored.
+func (c *metricCache) SetFilter(condition string) error {
+	if len(condition) > 0 {
+		if err := agg.CheckCondition(condition); err != nil {
+			return fmt.Errorf("MetricCache: invalid filter '%s': %w", condition, err)
+		}
+	}
+	c.filter = condition
+	return nil
+}
+
+// SetMaxMessages sets the maximal number of metrics stored per interval, 0 for no limit
+func (c *metricCache) SetMaxMessages(maxMessages int) {
+	c.lock.Lock()
+	c.maxMessages = max(0, maxMessages)
+	c.lock.Unlock()
+}
+
+// EvictedMessages returns the number of metrics that were not stored because an
+// interval reached the maximal number of messages
+func (c *metricCache) EvictedMessages() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.evicted
+}
+
+// SkippedMessages returns for each aggregation the number of matching messages
+// that were skipped because of missing or invalid values
+func (c *metricCache) SkippedMessages() map[string]uint64 {
+	return c.aggEngine.SkippedMessages()
+}
+
+// DroppedMessages returns for each aggregation the number of derived messages
+// that were dropped because the output channel was full
+func (c *metricCache) DroppedMessages() map[string]uint64 {
+	return c.aggEngine.DroppedMessages()
+}
+
 // Get all metrics of a interval. The index is the difference to the current interval, so index=0
 // is the current one, index=1 the last interval and so on. Returns and empty array if a wrong index
 // is given (negative index, index larger than configured number of total intervals, ...)
 func (c *metricCache) GetPeriod(index int) (time.Time, time.Time, []lp.CCMessage) {
 	start := time.Now()
 	stop := time.Now()
-	var metrics []lp.CCMessage
-	if index >= 0 && index < c.numPeriods {
+	metrics := make([]lp.CCMessage, 0)
+	if index >= 0 && index <= c.numPeriods {
 		pindex := c.curPeriod - index
 		if pindex < 0 {
-			pindex = c.numPeriods - pindex
+			pindex += len(c.intervals)
 		}
-		if pindex >= 0 && pindex < c.numPeriods {
-			start = c.intervals[pindex].startstamp
-			stop = c.intervals[pindex].stopstamp
-			metrics = c.intervals[pindex].metrics
-		} else {
-			metrics = make([]lp.CCMessage, 0)
-		}
-	} else {
-		metrics = make([]lp.CCMessage, 0)
+		p := c.intervals[pindex]
+		start = p.startstamp
+		stop = p.stopstamp
+		metrics = p.metrics[:p.numMetrics]
 	}
 	return start, stop, metrics
 }
@@ -20,12 +20,21 @@ import (
 	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
 	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
 	mp "github.com/ClusterCockpit/cc-lib/v2/messageProcessor"
+	ad "github.com/ClusterCockpit/cc-metric-collector/internal/anomalyDetector"
 	agg "github.com/ClusterCockpit/cc-metric-collector/internal/metricAggregator"
+	mc "github.com/ClusterCockpit/cc-metric-collector/pkg/metricCatalog"
 	mct "github.com/ClusterCockpit/cc-metric-collector/pkg/multiChanTicker"
 )
 
 const ROUTER_MAX_FORWARD = 50
 
+// Size of the buffer between MetricCache and MetricRouter. The aggregator does not
+// block when sending derived metrics, so they would get lost while the router is busy
+const ROUTER_CACHE_BUFFER = 200
+
+// Default maximal number of messages stored by the MetricCache per interval
+const ROUTER_CACHE_MAX_MESSAGES = 100000
+
 // Metric router tag configuration
 type metricRouterTagConfig struct {
 	Key       string `json:"key"`   // Tag name
@@ -44,10 +53,14 @@ type metricRouterConfig struct {
 	RenameMetrics     map[string]string                    `json:"rename_metrics"`      // Map to rename metric name from key to value
 	IntervalStamp     bool                                 `json:"interval_timestamp"`  // Update timestamp periodically by ticker each interval?
 	NumCacheIntervals int                                  `json:"num_cache_intervals"` // Number of intervals of cached metrics for evaluation
+	CacheIf           string                               `json:"cache_if,omitempty"`  // Condition for metrics stored in the cache (default: metrics matching any interval aggregate)
+	CacheMaxMessages  int                                  `json:"cache_max_messages"`  // Maximal number of metrics stored in the cache per interval (0 for no limit)
 	MaxForward        int                                  `json:"max_forward"`         // Number of maximal forwarded metrics at one select
 	NormalizeUnits    bool                                 `json:"normalize_units"`     // Check unit meta flag and normalize it using cc-units
 	ChangeUnitPrefix  map[string]string                    `json:"change_unit_prefix"`  // Add prefix that should be applied to the metrics
 	MessageProcessor  json.RawMessage                      `json:"process_messages,omitempty"`
+	AnomalyDetection  *ad.AnomalyDetectorConfig            `json:"anomaly_detection,omitempty"` // Per-series anomaly detection for selected metrics
+	ValidateMetrics   string                               `json:"validate_metrics,omitempty"`  // Check collector metrics against the metric catalog: "warn" or "drop"
 }
 
 // Metric router data structure
@@ -66,6 +79,9 @@ type metricRouter struct {
 	cachewg     sync.WaitGroup      // wait group for MetricCache
 	maxForward  int                 // number of metrics to forward maximally in one iteration
 	mp          mp.MessageProcessor
+	detector    ad.AnomalyDetector // anomaly detection for processed metrics (optional)
+	catalog     *mc.Catalog        // metric catalog of the collectors for validation (optional)
+	invalid     map[string]bool    // validation errors already reported
 }
 
 // MetricRouter access functions
@@ -74,6 +90,8 @@ type MetricRouter interface {
 	AddCollectorInput(input chan lp.CCMessage)
 	AddReceiverInput(input chan lp.CCMessage)
 	AddOutput(output chan lp.CCMessage)
+	SetCatalog(catalog *mc.Catalog)
+	Stats() map[string]uint64
 	Start()
 	Close()
 }
@@ -87,11 +105,12 @@ type MetricRouter interface {
 func (r *metricRouter) Init(ticker mct.MultiChanTicker, wg *sync.WaitGroup, routerConfig json.RawMessage) error {
 	r.outputs = make([]chan lp.CCMessage, 0)
 	r.done = make(chan bool)
-	r.cache_input = make(chan lp.CCMessage)
+	r.cache_input = make(chan lp.CCMessage, ROUTER_CACHE_BUFFER)
 	r.wg = wg
 	r.ticker = ticker
 	r.config.MaxForward = ROUTER_MAX_FORWARD
 	r.config.HostnameTagName = "hostname"
+	r.config.CacheMaxMessages = ROUTER_CACHE_MAX_MESSAGES
 
 	// Set hostname
 	hostname, err := os.Hostname()
@@ -108,6 +127,12 @@ func (r *metricRouter) Init(ticker mct.MultiChanTicker, wg *sync.WaitGroup, rout
 		return fmt.Errorf("failed to decode metric router config: %w", err)
 	}
 	r.maxForward = max(1, r.config.MaxForward)
+	switch r.config.ValidateMetrics {
+	case "", "warn", "drop":
+	default:
+		return fmt.Errorf("MetricRouter: invalid value '%s' for validate_metrics, use 'warn' or 'drop'", r.config.ValidateMetrics)
+	}
+	r.invalid = make(map[string]bool)
 
 	if r.config.NumCacheIntervals > 0 {
 		r.cache, err = NewCache(r.cache_input, r.ticker, &r.cachewg, r.config.NumCacheIntervals)
@@ -115,11 +140,22 @@ func (r *metricRouter) Init(ticker mct.MultiChanTicker, wg *sync.WaitGroup, rout
 			return fmt.Errorf("MetricRouter: failed to initialize MetricCache: %w", err)
 		}
 		for _, agg := range r.config.IntervalAgg {
-			err = r.cache.AddAggregation(agg.Name, agg.Function, agg.Condition, agg.Tags, agg.Meta)
+			err = r.cache.AddAggregation(agg)
 			if err != nil {
 				return fmt.Errorf("MetricCache AddAggregation() failed: %w", err)
 			}
 		}
+		err = r.cache.SetFilter(r.config.CacheIf)
+		if err != nil {
+			return fmt.Errorf("MetricCache SetFilter() failed: %w", err)
+		}
+		r.cache.SetMaxMessages(r.config.CacheMaxMessages)
+	}
+	if r.config.AnomalyDetection != nil {
+		r.detector, err = ad.New(*r.config.AnomalyDetection)
+		if err != nil {
+			return fmt.Errorf("MetricRouter: failed to initialize AnomalyDetector: %w", err)
+		}
 	}
 	p, err := mp.NewMessageProcessor()
 	if err != nil {
@@ -224,6 +260,32 @@ func (r *metricRouter) DoAddTags(point lp.CCMessage) {
 	}
 }
 
+// validate checks a message of a collector against the metric catalog. Each
+// validation error is reported once. It returns false if the message should
+// be dropped.
+func (r *metricRouter) validate(p lp.CCMessage) bool {
+	if r.catalog == nil || len(r.config.ValidateMetrics) == 0 {
+		return true
+	}
+	err := r.catalog.Validate(p)
+	if err == nil {
+		return true
+	}
+	if msg := err.Error(); !r.invalid[msg] {
+		r.invalid[msg] = true
+		cclog.ComponentWarn("MetricRouter", msg)
+	}
+	return r.config.ValidateMetrics != "drop"
+}
+
+// detect returns the anomaly scores and events derived from a processed message
+func (r *metricRouter) detect(m lp.CCMessage) []lp.CCMessage {
+	if r.detector == nil || m == nil {
+		return nil
+	}
+	return r.detector.Process(m)
+}
+
 // Start starts the metric router
 func (r *metricRouter) Start() {
 	// start timer if configured
@@ -242,6 +304,9 @@ func (r *metricRouter) Start() {
 	// Forward message received from collector channel
 	coll_forward := func(p lp.CCMessage) {
 		// receive from metric collector
+		if !r.validate(p) {
+			return
+		}
 		if r.config.IntervalStamp {
 			p.SetTime(r.timestamp)
 		}
@@ -250,11 +315,21 @@ func (r *metricRouter) Start() {
 			for _, o := range r.outputs {
 				o <- m
 			}
+			for _, y := range r.detect(m) {
+				for _, o := range r.outputs {
+					o <- y
+				}
+			}
 		}
 		// even if the metric is dropped, it is stored in the cache for
-		// aggregations
-		if r.config.NumCacheIntervals > 0 {
-			r.cache.Add(m)
+		// aggregations. The message processor returns nil for dropped
+		// metrics, so the unprocessed metric is stored instead
+		if r.config.NumCacheIntervals > 0 && err == nil {
+			if m != nil {
+				r.cache.Add(m)
+			} else {
+				r.cache.Add(p)
+			}
 		}
 	}
 
@@ -269,6 +344,11 @@ func (r *metricRouter) Start() {
 			for _, o := range r.outputs {
 				o <- m
 			}
+			for _, y := range r.detect(m) {
+				for _, o := range r.outputs {
+					o <- y
+				}
+			}
 		}
 	}
 
@@ -280,6 +360,11 @@ func (r *metricRouter) Start() {
 			for _, o := range r.outputs {
 				o <- m
 			}
+			for _, y := range r.detect(m) {
+				for _, o := range r.outputs {
+					o <- y
+				}
+			}
 		}
 	}
 
@@ -332,6 +417,31 @@ func (r *metricRouter) AddReceiverInput(input chan lp.CCMessage) {
 	r.recv_input = input
 }
 
+// SetCatalog sets the metric catalog used to validate the metrics of the collectors
+func (r *metricRouter) SetCatalog(catalog *mc.Catalog) {
+	r.catalog = catalog
+}
+
+// Stats returns the counters of the MetricCache: the number of messages evicted
+// because an interval was full and the number of messages skipped or dropped by
+// the interval aggregates. Without MetricCache, an empty map is returned.
+func (r *metricRouter) Stats() map[string]uint64 {
+	stats := make(map[string]uint64)
+	if r.cache == nil {
+		return stats
+	}
+	stats["router_cache_evicted"] = r.cache.EvictedMessages()
+	stats["router_aggregation_skipped"] = 0
+	stats["router_aggregation_dropped"] = 0
+	for _, skipped := range r.cache.SkippedMessages() {
+		stats["router_aggregation_skipped"] += skipped
+	}
+	for _, dropped := range r.cache.DroppedMessages() {
+		stats["router_aggregation_dropped"] += dropped
+	}
+	return stats
+}
+
 // AddOutput adds a output channel to the metric router
 func (r *metricRouter) AddOutput(output chan lp.CCMessage) {
 	r.outputs = append(r.outputs, output)
@@ -0,0 +1,141 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package metricRouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+)
+
+// OfflineRouter applies a metric router configuration to recorded messages.
+// It uses the same message processor, MetricCache and MetricAggregator as the
+// MetricRouter, but there are no goroutines, channels or tickers involved. The
+// caller feeds the messages with Process() and simulates the end of an interval
+// with Tick(). This makes the results reproducible, so it can be used to test
+// router configurations and as base for unit tests.
+type OfflineRouter struct {
+	router    metricRouter
+	cache     *metricCache
+	wg        sync.WaitGroup
+	timestamp time.Time
+}
+
+// Process sends a message through the message processor like a message from a
+// collector. The processed message is returned followed by the anomaly scores
+// and events derived from it. The list is empty if the message was dropped.
+// Like in the MetricRouter, the message is added to the MetricCache if caching
+// is enabled.
+func (o *OfflineRouter) Process(p lp.CCMessage) ([]lp.CCMessage, error) {
+	r := &o.router
+	if r.config.IntervalStamp {
+		p.SetTime(o.timestamp)
+	}
+	m, err := r.mp.ProcessMessage(p)
+	// even if the metric is dropped, it is stored in the cache for
+	// aggregations
+	if r.config.NumCacheIntervals > 0 && err == nil {
+		if m != nil {
+			o.cache.Add(m)
+		} else {
+			o.cache.Add(p)
+		}
+	}
+	out := make([]lp.CCMessage, 0)
+	if err == nil && m != nil {
+		out = append(out, m)
+		out = append(out, r.detect(m)...)
+	}
+	return out, err
+}
+
+// Tick simulates a tick of the ticker at the given timestamp. It finishes the
+// current cache interval, evaluates the interval aggregates and returns the
+// derived messages after they passed the message processor.
+func (o *OfflineRouter) Tick(timestamp time.Time) ([]lp.CCMessage, error) {
+	r := &o.router
+	o.timestamp = timestamp
+	out := make([]lp.CCMessage, 0)
+	if r.config.NumCacheIntervals == 0 {
+		return out, nil
+	}
+
+	// The derived messages are collected directly from the aggregator instead
+	// of the bounded cache channel, so no message is dropped
+	var errs []error
+	for _, d := range o.cache.derive(timestamp) {
+		m, err := r.mp.ProcessMessage(d)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if m != nil {
+			out = append(out, m)
+			out = append(out, r.detect(m)...)
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// SkippedMessages returns for each interval aggregate the number of matching
+// messages that were skipped because of missing or invalid values
+func (o *OfflineRouter) SkippedMessages() map[string]uint64 {
+	if o.cache == nil {
+		return map[string]uint64{}
+	}
+	return o.cache.SkippedMessages()
+}
+
+// DroppedMessages returns for each interval aggregate the number of derived
+// messages that were dropped because the output channel was full
+func (o *OfflineRouter) DroppedMessages() map[string]uint64 {
+	if o.cache == nil {
+		return map[string]uint64{}
+	}
+	return o.cache.DroppedMessages()
+}
+
+// EvictedMessages returns the number of messages that were not stored in the
+// MetricCache because an interval reached the maximal number of messages
+func (o *OfflineRouter) EvictedMessages() uint64 {
+	if o.cache == nil {
+		return 0
+	}
+	return o.cache.EvictedMessages()
+}
+
+// SetTimestamp sets the start of the first interval. It is used for messages
+// when the option interval_timestamp is set
+func (o *OfflineRouter) SetTimestamp(timestamp time.Time) {
+	o.timestamp = timestamp
+	if o.cache != nil {
+		o.cache.setStart(timestamp)
+	}
+}
+
+// NewOffline creates a new offline router from a metric router configuration
+func NewOffline(routerConfig json.RawMessage) (*OfflineRouter, error) {
+	o := new(OfflineRouter)
+	err := o.router.Init(nil, &o.wg, routerConfig)
+	if err != nil {
+		return nil, err
+	}
+	if o.router.config.NumCacheIntervals > 0 {
+		c, ok := o.router.cache.(*metricCache)
+		if !ok {
+			return nil, fmt.Errorf("OfflineRouter: unsupported MetricCache implementation")
+		}
+		o.cache = c
+	}
+	o.timestamp = time.Now()
+	return o, nil
+}
@@ -0,0 +1,189 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package metricRouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+	agg "github.com/ClusterCockpit/cc-metric-collector/internal/metricAggregator"
+)
+
+func TestMain(m *testing.M) {
+	cclog.Init("err", false)
+	os.Exit(m.Run())
+}
+
+// newTestRouter creates an offline router with a MetricCache and the given
+// interval aggregates
+func newTestRouter(t *testing.T, aggregates []agg.MetricAggregatorIntervalConfig) *OfflineRouter {
+	t.Helper()
+	config, err := json.Marshal(map[string]any{
+		"num_cache_intervals": 1,
+		"interval_aggregates": aggregates,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewOffline(config)
+	if err != nil {
+		t.Fatalf("NewOffline() failed: %v", err)
+	}
+	return r
+}
+
+// runInterval processes the values as messages of the metric test_metric and
+// returns the derived messages of the interval
+func runInterval(t *testing.T, r *OfflineRouter, values []any) []lp.CCMessage {
+	t.Helper()
+	start := time.Unix(1700000000, 0)
+	r.SetTimestamp(start)
+	for i, v := range values {
+		m, err := lp.NewMetric(
+			"test_metric",
+			map[string]string{"type": "hwthread", "type-id": fmt.Sprint(i)},
+			map[string]string{"source": "test"},
+			v,
+			start.Add(time.Duration(i)*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Process(m); err != nil {
+			t.Fatalf("Process() failed: %v", err)
+		}
+	}
+	out, err := r.Tick(start.Add(10 * time.Second))
+	if err != nil {
+		t.Fatalf("Tick() failed: %v", err)
+	}
+	return out
+}
+
+func TestOfflineAggregationFunctions(t *testing.T) {
+	tests := []struct {
+		function         string
+		values           []any
+		preserveIntegers bool
+		want             any
+	}{
+		{"sum(values)", []any{1.0, 2.0, 3.0, 4.0}, false, 10.0},
+		{"avg(values)", []any{1.0, 2.0, 3.0, 4.0}, false, 2.5},
+		{"mean(values)", []any{1.0, 2.0, 3.0, 4.0}, false, 2.5},
+		{"min(values)", []any{3.0, 1.0, 4.0, 2.0}, false, 1.0},
+		{"max(values)", []any{3.0, 1.0, 4.0, 2.0}, false, 4.0},
+		{"median(values)", []any{5.0, 1.0, 3.0}, false, 3.0},
+		{"len(values)", []any{5.0, 1.0, 3.0}, false, int64(3)},
+		// Integer values are converted to float64 by default
+		{"sum(values)", []any{int64(1), int64(2), uint64(3)}, false, 6.0},
+		{"sum(values)", []any{int64(1), int64(2), uint64(3)}, true, int64(6)},
+		// A single float value falls back to float64
+		{"sum(values)", []any{int64(1), 2.5}, true, 3.5},
+		// Booleans count as 1 and 0
+		{"sum(values)", []any{true, false, true}, false, 2.0},
+		{"max(values) - min(values)", []any{2.0, 7.0, 4.0}, false, 5.0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.function, tt.values), func(t *testing.T) {
+			r := newTestRouter(t, []agg.MetricAggregatorIntervalConfig{{
+				Name:             "test_agg",
+				Condition:        "metric.Name() == 'test_metric'",
+				Function:         tt.function,
+				Tags:             map[string]string{"type": "node"},
+				Meta:             map[string]string{"source": "MetricAggregator"},
+				PreserveIntegers: tt.preserveIntegers,
+			}})
+			out := runInterval(t, r, tt.values)
+			if len(out) != 1 {
+				t.Fatalf("got %d derived messages, want 1", len(out))
+			}
+			if out[0].Name() != "test_agg" {
+				t.Errorf("got metric name %s, want test_agg", out[0].Name())
+			}
+			got, ok := out[0].GetField("value")
+			if !ok {
+				t.Fatalf("derived message has no value")
+			}
+			if got != tt.want {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestOfflineSkippedMessages(t *testing.T) {
+	r := newTestRouter(t, []agg.MetricAggregatorIntervalConfig{{
+		Name:      "test_agg",
+		Condition: "metric.Name() == 'test_metric'",
+		Function:  "sum(values)",
+		Tags:      map[string]string{"type": "node"},
+	}})
+	out := runInterval(t, r, []any{1.0, "invalid", 2.0})
+	if len(out) != 1 {
+		t.Fatalf("got %d derived messages, want 1", len(out))
+	}
+	if got, _ := out[0].GetField("value"); got != 3.0 {
+		t.Errorf("got %v, want 3", got)
+	}
+	if skipped := r.SkippedMessages()["test_agg"]; skipped != 1 {
+		t.Errorf("got %d skipped messages, want 1", skipped)
+	}
+}
+
+// More derived messages than fit into the buffer between MetricCache and
+// MetricRouter must not get lost in the offline router
+func TestOfflineManyDerivedMessages(t *testing.T) {
+	const numAggregates = 2 * ROUTER_CACHE_BUFFER
+	aggregates := make([]agg.MetricAggregatorIntervalConfig, 0, numAggregates)
+	for i := range numAggregates {
+		aggregates = append(aggregates, agg.MetricAggregatorIntervalConfig{
+			Name:      fmt.Sprintf("test_agg_%d", i),
+			Condition: "metric.Name() == 'test_metric'",
+			Function:  "sum(values)",
+			Tags:      map[string]string{"type": "node"},
+		})
+	}
+	r := newTestRouter(t, aggregates)
+	out := runInterval(t, r, []any{1.0, 2.0})
+	if len(out) != numAggregates {
+		t.Errorf("got %d derived messages, want %d", len(out), numAggregates)
+	}
+	for name, dropped := range r.DroppedMessages() {
+		if dropped > 0 {
+			t.Errorf("aggregation %s dropped %d messages", name, dropped)
+		}
+	}
+}
+
+func TestOfflineCacheMaxMessages(t *testing.T) {
+	config := []byte(`{
+		"num_cache_intervals": 1,
+		"cache_max_messages": 2,
+		"interval_aggregates": [
+			{"name": "test_agg", "if": "metric.Name() == 'test_metric'", "function": "len(values)", "tags": {"type": "node"}}
+		]
+	}`)
+	r, err := NewOffline(config)
+	if err != nil {
+		t.Fatalf("NewOffline() failed: %v", err)
+	}
+	out := runInterval(t, r, []any{1.0, 2.0, 3.0, 4.0})
+	if len(out) != 1 {
+		t.Fatalf("got %d derived messages, want 1", len(out))
+	}
+	if got, _ := out[0].GetField("value"); got != int64(2) {
+		t.Errorf("got %v, want 2", got)
+	}
+	if evicted := r.EvictedMessages(); evicted != 2 {
+		t.Errorf("got %d evicted messages, want 2", evicted)
+	}
+}
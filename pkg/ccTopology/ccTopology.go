@@ -10,17 +10,34 @@ package ccTopology
 import (
 	"fmt"
 	"log"
-	"os"
+	"maps"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	cclogger "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
 )
 
 const SYSFS_CPUBASE = `/sys/devices/system/cpu`
+const SYSFS_PCIBASE = `/sys/bus/pci/devices`
+const SYSFS_NODEBASE = `/sys/devices/system/node`
+const SYSFS_CLASSBASE = `/sys/class`
+
+// List of the efficiency cores on hybrid CPUs like Intel Alder Lake. The
+// performance cores are listed in /sys/devices/cpu_core/cpus.
+const SYSFS_CPU_ATOM = `/sys/devices/cpu_atom/cpus`
+
+// Core types of the hardware threads. On systems without hybrid CPUs, all
+// hardware threads are performance cores.
+const (
+	CORE_TYPE_PERFORMANCE = 0
+	CORE_TYPE_EFFICIENCY  = 1
+)
 
 // Structure holding all information about a hardware thread
 // See https://www.kernel.org/doc/Documentation/ABI/stable/sysfs-devices-system-cpu
@@ -31,25 +48,75 @@ type HwthreadEntry struct {
 	CoreCPUsList []int // CPUs within the same core
 	Core         int   // Socket local core ID
 	Socket       int   // Sockets (physical) ID
-	Die          int   // Die ID
+	Die          int   // Die ID, numbered in the order of socket and socket local die ID
+	SocketDie    int   // Socket local die ID
 	NumaDomain   int   // NUMA Domain
+	L3Domain     int   // ID of the L3 cache, -1 without L3 cache
+	CoreType     int   // Core type on hybrid CPUs (CORE_TYPE_PERFORMANCE or CORE_TYPE_EFFICIENCY)
+}
+
+// Structure holding all information about a CPU cache
+// See https://www.kernel.org/doc/Documentation/ABI/testing/sysfs-devices-system-cpu
+type CacheEntry struct {
+	ID             int    `json:"id"`         // Cache ID, numbered per level and type in the order of the first hardware thread
+	Level          int    `json:"level"`      // Cache level (1, 2, 3, ...)
+	Type           string `json:"type"`       // Cache type: Data, Instruction or Unified
+	Size           int64  `json:"size"`       // Cache size in bytes
+	SharedCPUsList []int  `json:"sharedCpus"` // CPUs sharing the cache
+}
+
+// Structure holding all information about a PCI device like a GPU, an
+// InfiniBand HCA, a NIC or a NVMe controller
+type PciDeviceEntry struct {
+	Address       string   `json:"address"`    // PCI address like 0000:3b:00.0
+	Type          string   `json:"type"`       // Device type: gpu, ib, nic or nvme
+	Names         []string `json:"names"`      // Names of the device in its sysfs class like mlx5_0, eth0 or nvme0
+	Vendor        string   `json:"vendor"`     // PCI vendor ID like 0x10de
+	NumaDomain    int      `json:"numaDomain"` // NUMA domain, -1 without NUMA affinity
+	Socket        int      `json:"socket"`     // CPU socket of the local CPUs, -1 if unknown
+	LocalCPUsList []int    `json:"localCpus"`  // CPUs local to the device
+}
+
+// Structure holding all information about a NUMA node
+type NumaNodeEntry struct {
+	ID        int         `json:"id"`        // NUMA node ID
+	MemTotal  int64       `json:"memTotal"`  // Memory of the NUMA node in bytes, -1 if unknown
+	Distances map[int]int `json:"distances"` // Distances to the NUMA nodes by NUMA node ID
 }
 
-var cache struct {
+// Cached topology. Init() creates a new cache and replaces the current one, so
+// the functions of this package always work on a consistent topology.
+type topologyCache struct {
 	HwthreadList   []int // List of CPU hardware threads
 	SMTList        []int // List of symmetric hyper threading IDs
 	CoreList       []int // List of CPU core IDs
 	SocketList     []int // List of CPU sockets (physical) IDs
-	DieList        []int // List of CPU Die IDs
+	DieList        []int // List of CPU die IDs
 	NumaDomainList []int // List of NUMA Domains
+	L3DomainList   []int // List of L3 cache IDs
+	CoreTypeList   []int // List of core types
+	OfflineList    []int // List of offline hardware threads
 
-	CpuData []HwthreadEntry
+	OnlineMask onlineMask // Online mask the topology was read with
+	Generation uint64     // Incremented with each change of the online mask
+
+	CpuData       []HwthreadEntry
+	CacheData     []CacheEntry
+	NumaNodeData  []NumaNodeEntry
+	PciDeviceData []PciDeviceEntry
+}
+
+var topology atomic.Pointer[topologyCache]
+
+// current returns the current topology cache
+func current() *topologyCache {
+	return topology.Load()
 }
 
 // fileToInt reads an integer value from a sysfs file
 // In case of an error -1 is returned
 func fileToInt(path string) int {
-	buffer, err := os.ReadFile(path)
+	buffer, err := hostfs.ReadFile(path)
 	if err != nil {
 		cclogger.ComponentError("ccTopology", fmt.Sprintf("fileToInt(): Reading \"%s\": %v", path, err))
 		return -1
@@ -69,7 +136,7 @@ func fileToInt(path string) int {
 // In case of an error nil is returned
 func fileToList(path string) []int {
 	// Read list
-	buffer, err := os.ReadFile(path)
+	buffer, err := hostfs.ReadFile(path)
 	if err != nil {
 		log.Print(err)
 		cclogger.ComponentError("ccTopology", "fileToList", "Reading", path, ":", err.Error())
@@ -109,15 +176,355 @@ func fileToList(path string) []int {
 	return list
 }
 
-// init initializes the cache structure
+// fileToString reads a string value from a sysfs file
+// In case of an error an empty string is returned
+func fileToString(path string) string {
+	buffer, err := hostfs.ReadFile(path)
+	if err != nil {
+		cclogger.ComponentError("ccTopology", fmt.Sprintf("fileToString(): Reading \"%s\": %v", path, err))
+		return ""
+	}
+	return strings.TrimSpace(string(buffer))
+}
+
+// sizeToBytes converts a size like 32K from sysfs to bytes
+// In case of an error -1 is returned
+func sizeToBytes(size string) int64 {
+	factor := int64(1)
+	switch {
+	case strings.HasSuffix(size, "K"):
+		factor = 1024
+	case strings.HasSuffix(size, "M"):
+		factor = 1024 * 1024
+	case strings.HasSuffix(size, "G"):
+		factor = 1024 * 1024 * 1024
+	}
+	value, err := strconv.ParseInt(strings.TrimRight(size, "KMG"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return value * factor
+}
+
+// getCaches reads the caches of all hardware threads. Caches shared by multiple
+// hardware threads are listed only once.
+func getCaches(hwthreads []int) []CacheEntry {
+	caches := make([]CacheEntry, 0)
+	for _, c := range hwthreads {
+		// Systems without cache information, e.g. some virtual machines, have no cache directory
+		globPath := filepath.Join(SYSFS_CPUBASE, fmt.Sprintf("cpu%d", c), "cache", "index[0-9]*")
+		files, err := hostfs.Glob(globPath)
+		if err != nil {
+			cclogger.ComponentError("CCTopology", "init:getCaches", err.Error())
+			return nil
+		}
+		for _, indexBase := range files {
+			level := fileToInt(filepath.Join(indexBase, "level"))
+			typ := fileToString(filepath.Join(indexBase, "type"))
+			sharedCPUsList := fileToList(filepath.Join(indexBase, "shared_cpu_list"))
+			if level < 0 || len(sharedCPUsList) == 0 {
+				continue
+			}
+			if slices.ContainsFunc(caches, func(e CacheEntry) bool {
+				return e.Level == level && e.Type == typ && slices.Equal(e.SharedCPUsList, sharedCPUsList)
+			}) {
+				continue
+			}
+			caches = append(caches, CacheEntry{
+				Level:          level,
+				Type:           typ,
+				Size:           sizeToBytes(fileToString(filepath.Join(indexBase, "size"))),
+				SharedCPUsList: sharedCPUsList,
+			})
+		}
+	}
+
+	// Number the caches per level and type in the order of the first hardware thread
+	slices.SortStableFunc(caches, func(a, b CacheEntry) int {
+		if a.Level != b.Level {
+			return a.Level - b.Level
+		}
+		if a.Type != b.Type {
+			return strings.Compare(a.Type, b.Type)
+		}
+		return a.SharedCPUsList[0] - b.SharedCPUsList[0]
+	})
+	ids := make(map[string]int)
+	for i := range caches {
+		key := fmt.Sprintf("%d:%s", caches[i].Level, caches[i].Type)
+		caches[i].ID = ids[key]
+		ids[key]++
+	}
+	return caches
+}
+
+// getCoreTypes reads the core types of the hardware threads on hybrid CPUs. Only
+// the efficiency cores are returned, all other hardware threads are performance cores.
+func getCoreTypes() map[int]int {
+	coreTypes := make(map[int]int)
+	if _, err := hostfs.Stat(SYSFS_CPU_ATOM); err != nil {
+		// No hybrid CPU
+		return coreTypes
+	}
+	for _, c := range fileToList(SYSFS_CPU_ATOM) {
+		coreTypes[c] = CORE_TYPE_EFFICIENCY
+	}
+	return coreTypes
+}
+
+// getNumaNodes reads the memory size and the distances of all NUMA nodes. NUMA
+// nodes without hardware threads, e.g. for HBM or CXL memory, are included.
+func getNumaNodes() []NumaNodeEntry {
+	globPath := filepath.Join(SYSFS_NODEBASE, "node[0-9]*")
+	regex := regexp.MustCompile(filepath.Join(SYSFS_NODEBASE, "node([[:digit:]]+)$"))
+	files, err := hostfs.Glob(globPath)
+	if err != nil {
+		cclogger.ComponentError("CCTopology", "init:getNumaNodes", err.Error())
+		return nil
+	}
+	ids := make([]int, 0, len(files))
+	for _, file := range files {
+		matches := regex.FindStringSubmatch(file)
+		if len(matches) != 2 {
+			continue
+		}
+		if id, err := strconv.Atoi(matches[1]); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	nodes := make([]NumaNodeEntry, 0, len(ids))
+	for _, id := range ids {
+		nodeBase := filepath.Join(SYSFS_NODEBASE, fmt.Sprintf("node%d", id))
+		n := NumaNodeEntry{
+			ID:        id,
+			MemTotal:  -1,
+			Distances: make(map[int]int),
+		}
+
+		// Lines in meminfo look like "Node 0 MemTotal:       263720204 kB"
+		for line := range strings.SplitSeq(fileToString(filepath.Join(nodeBase, "meminfo")), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[2] == "MemTotal:" {
+				if value, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+					n.MemTotal = value * 1024
+				}
+				break
+			}
+		}
+
+		// The distances are listed in the order of the NUMA node IDs
+		for i, field := range strings.Fields(fileToString(filepath.Join(nodeBase, "distance"))) {
+			if i >= len(ids) {
+				break
+			}
+			if value, err := strconv.Atoi(field); err == nil {
+				n.Distances[ids[i]] = value
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// Vendors of GPUs. Display controllers of other vendors are commonly the
+// graphics of the BMC.
+var gpuVendors = map[string]bool{
+	"0x10de": true, // NVIDIA
+	"0x1002": true, // AMD
+	"0x8086": true, // Intel
+}
+
+// Device types of the sysfs classes
+var pciDeviceClasses = []struct {
+	typ   string
+	class string
+}{
+	{typ: "ib", class: "infiniband"},
+	{typ: "nic", class: "net"},
+	{typ: "nvme", class: "nvme"},
+	{typ: "gpu", class: "drm"},
+}
+
+var pciAddressRegex = regexp.MustCompile(`[[:xdigit:]]{4}:[[:xdigit:]]{2}:[[:xdigit:]]{2}\.[[:xdigit:]]`)
+
+// classDevicePciAddress gets the PCI address of a device in a sysfs class like
+// /sys/class/net/eth0. The links in the class directories point to the device
+// below the PCI bus. Virtual devices have no PCI address.
+func classDevicePciAddress(class, name string) (string, bool) {
+	target, err := hostfs.Readlink(filepath.Join(SYSFS_CLASSBASE, class, name))
+	if err != nil {
+		return "", false
+	}
+	addresses := pciAddressRegex.FindAllString(target, -1)
+	if len(addresses) == 0 {
+		return "", false
+	}
+	return strings.ToLower(addresses[len(addresses)-1]), true
+}
+
+// pciDeviceAffinity reads the NUMA domain and the local CPUs of a PCI device and
+// derives the socket from the local CPUs
+func pciDeviceAffinity(address string, cpuData []HwthreadEntry) (int, int, []int) {
+	numaDomain := -1
+	if buffer, err := hostfs.ReadFile(filepath.Join(SYSFS_PCIBASE, address, "numa_node")); err == nil {
+		if id, err := strconv.Atoi(strings.TrimSpace(string(buffer))); err == nil && id >= 0 {
+			numaDomain = id
+		}
+	}
+	var localCPUsList []int
+	if _, err := hostfs.Stat(filepath.Join(SYSFS_PCIBASE, address, "local_cpulist")); err == nil {
+		localCPUsList = fileToList(filepath.Join(SYSFS_PCIBASE, address, "local_cpulist"))
+	}
+	socket := -1
+	for i := range cpuData {
+		d := &cpuData[i]
+		if (len(localCPUsList) > 0 && slices.Contains(localCPUsList, d.CpuID)) ||
+			(len(localCPUsList) == 0 && numaDomain >= 0 && d.NumaDomain == numaDomain) {
+			if socket >= 0 && d.Socket != socket {
+				// Device is local to multiple sockets
+				socket = -1
+				break
+			}
+			socket = d.Socket
+		}
+	}
+	return numaDomain, socket, localCPUsList
+}
+
+// getPciDevices enumerates the GPUs, InfiniBand HCAs, NICs and NVMe controllers
+func getPciDevices(cpuData []HwthreadEntry) []PciDeviceEntry {
+	devices := make([]PciDeviceEntry, 0)
+	add := func(typ, address, name string) {
+		i := slices.IndexFunc(devices, func(d PciDeviceEntry) bool { return d.Type == typ && d.Address == address })
+		if i >= 0 {
+			if len(name) > 0 {
+				devices[i].Names = append(devices[i].Names, name)
+			}
+			return
+		}
+		d := PciDeviceEntry{
+			Address: address,
+			Type:    typ,
+			Names:   make([]string, 0),
+			Vendor:  fileToString(filepath.Join(SYSFS_PCIBASE, address, "vendor")),
+		}
+		if len(name) > 0 {
+			d.Names = append(d.Names, name)
+		}
+		d.NumaDomain, d.Socket, d.LocalCPUsList = pciDeviceAffinity(address, cpuData)
+		devices = append(devices, d)
+	}
+
+	// GPUs by PCI class: display controllers (0x03xxxx) and processing accelerators (0x12xxxx)
+	files, err := hostfs.Glob(filepath.Join(SYSFS_PCIBASE, "*"))
+	if err != nil {
+		cclogger.ComponentError("CCTopology", "init:getPciDevices", err.Error())
+		return nil
+	}
+	for _, file := range files {
+		address := filepath.Base(file)
+		buffer, err := hostfs.ReadFile(filepath.Join(file, "class"))
+		if err != nil {
+			continue
+		}
+		class := strings.TrimSpace(string(buffer))
+		if !strings.HasPrefix(class, "0x03") && !strings.HasPrefix(class, "0x12") {
+			continue
+		}
+		buffer, err = hostfs.ReadFile(filepath.Join(file, "vendor"))
+		if err != nil || !gpuVendors[strings.TrimSpace(string(buffer))] {
+			continue
+		}
+		add("gpu", address, "")
+	}
+
+	// Devices by sysfs class
+	for _, c := range pciDeviceClasses {
+		entries, err := hostfs.ReadDir(filepath.Join(SYSFS_CLASSBASE, c.class))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			address, ok := classDevicePciAddress(c.class, e.Name())
+			if !ok {
+				continue
+			}
+			if c.typ == "gpu" {
+				// Only the cards of already found GPUs, not the render nodes
+				if !strings.HasPrefix(e.Name(), "card") || strings.Contains(e.Name(), "-") ||
+					!slices.ContainsFunc(devices, func(d PciDeviceEntry) bool { return d.Type == "gpu" && d.Address == address }) {
+					continue
+				}
+			}
+			add(c.typ, address, e.Name())
+		}
+	}
+
+	slices.SortStableFunc(devices, func(a, b PciDeviceEntry) int {
+		if a.Type != b.Type {
+			return strings.Compare(a.Type, b.Type)
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return devices
+}
+
+// The online mask of the hardware threads as read from sysfs. An empty mask
+// means that the online file is not available and all hardware threads are
+// online.
+type onlineMask string
+
+// readOnlineMask reads the list of online hardware threads
+func readOnlineMask() onlineMask {
+	buffer, err := hostfs.ReadFile(filepath.Join(SYSFS_CPUBASE, "online"))
+	if err != nil {
+		return ""
+	}
+	return onlineMask(strings.TrimSpace(string(buffer)))
+}
+
+// online returns the list of online hardware threads or nil if unknown
+func (mask onlineMask) online() []int {
+	if len(mask) == 0 {
+		return nil
+	}
+	return fileToList(filepath.Join(SYSFS_CPUBASE, "online"))
+}
+
+// Callbacks for changes of the topology
+var changeCallbacks = struct {
+	callbacks []func()
+	mutex     sync.Mutex
+}{}
+
+// init initializes the cache structure with the default sysfs root
 func init() {
+	Init()
+}
+
+// Init reads the topology from sysfs and replaces the cache structure. It has
+// to be called again after the sysfs root was changed (see hostfs.Init())
+func Init() {
+	var generation uint64
+	if c := current(); c != nil {
+		generation = c.Generation
+	}
+	initTopology(generation)
+}
+
+// initTopology reads the topology from sysfs and replaces the cache structure
+func initTopology(generation uint64) {
+	cache := new(topologyCache)
+	cache.Generation = generation
 	getHWThreads := func() []int {
 		globPath := filepath.Join(SYSFS_CPUBASE, "cpu[0-9]*")
 		regexPath := filepath.Join(SYSFS_CPUBASE, "cpu([[:digit:]]+)")
 		regex := regexp.MustCompile(regexPath)
 
 		// File globbing for hardware threads
-		files, err := filepath.Glob(globPath)
+		files, err := hostfs.Glob(globPath)
 		if err != nil {
 			cclogger.ComponentError("CCTopology", "init:getHWThreads", err.Error())
 			return nil
@@ -153,7 +560,7 @@ func init() {
 		regex := regexp.MustCompile(regexPath)
 
 		// File globbing for NUMA node
-		files, err := filepath.Glob(globPath)
+		files, err := hostfs.Glob(globPath)
 		if err != nil {
 			cclogger.ComponentError("CCTopology", "init:getNumaDomain", err.Error())
 			return -1
@@ -181,12 +588,29 @@ func init() {
 		return id
 	}
 
-	cache.HwthreadList = getHWThreads()
+	// Only online hardware threads are part of the topology. Offline hardware
+	// threads have no topology information in sysfs.
+	cache.OnlineMask = readOnlineMask()
+	cache.HwthreadList = make([]int, 0)
+	cache.OfflineList = make([]int, 0)
+	online := cache.OnlineMask.online()
+	for _, c := range getHWThreads() {
+		if online == nil || slices.Contains(online, c) {
+			cache.HwthreadList = append(cache.HwthreadList, c)
+		} else {
+			cache.OfflineList = append(cache.OfflineList, c)
+		}
+	}
+	cache.CacheData = getCaches(cache.HwthreadList)
+	cache.NumaNodeData = getNumaNodes()
+	coreTypes := getCoreTypes()
 	cache.CoreList = make([]int, len(cache.HwthreadList))
 	cache.SocketList = make([]int, len(cache.HwthreadList))
 	cache.DieList = make([]int, len(cache.HwthreadList))
 	cache.SMTList = make([]int, len(cache.HwthreadList))
 	cache.NumaDomainList = make([]int, len(cache.HwthreadList))
+	cache.L3DomainList = make([]int, 0, len(cache.HwthreadList))
+	cache.CoreTypeList = make([]int, len(cache.HwthreadList))
 	cache.CpuData = make([]HwthreadEntry, len(cache.HwthreadList))
 	for i, c := range cache.HwthreadList {
 		// Set cpuBase directory for topology lookup
@@ -215,16 +639,55 @@ func init() {
 		// Lookup NUMA domain id
 		cache.NumaDomainList[i] = getNumaDomain(cpuBase)
 
+		// Lookup L3 cache
+		l3Domain := -1
+		for j := range cache.CacheData {
+			d := &cache.CacheData[j]
+			if d.Level == 3 && d.Type != "Instruction" && slices.Contains(d.SharedCPUsList, c) {
+				l3Domain = d.ID
+				cache.L3DomainList = append(cache.L3DomainList, l3Domain)
+				break
+			}
+		}
+
+		// Lookup core type
+		cache.CoreTypeList[i] = coreTypes[c]
+
 		cache.CpuData[i] = HwthreadEntry{
 			CpuID:        cache.HwthreadList[i],
 			SMT:          cache.SMTList[i],
 			CoreCPUsList: coreCPUsList,
 			Socket:       cache.SocketList[i],
 			NumaDomain:   cache.NumaDomainList[i],
-			Die:          cache.DieList[i],
+			SocketDie:    cache.DieList[i],
 			Core:         cache.CoreList[i],
+			L3Domain:     l3Domain,
+			CoreType:     cache.CoreTypeList[i],
+		}
+	}
+
+	// The die_id of sysfs is only unique within a socket, e.g. all dies of a
+	// two socket node without multiple dies per socket have die_id 0. The dies
+	// are numbered in the order of socket and socket local die ID instead.
+	type dieKey struct{ socket, die int }
+	dieKeys := make([]dieKey, 0)
+	for _, d := range cache.CpuData {
+		key := dieKey{d.Socket, d.SocketDie}
+		if !slices.Contains(dieKeys, key) {
+			dieKeys = append(dieKeys, key)
 		}
 	}
+	slices.SortFunc(dieKeys, func(a, b dieKey) int {
+		if a.socket != b.socket {
+			return a.socket - b.socket
+		}
+		return a.die - b.die
+	})
+	for i := range cache.CpuData {
+		d := &cache.CpuData[i]
+		d.Die = slices.Index(dieKeys, dieKey{d.Socket, d.SocketDie})
+		cache.DieList[i] = d.Die
+	}
 
 	slices.Sort(cache.HwthreadList)
 	cache.HwthreadList = slices.Compact(cache.HwthreadList)
@@ -243,36 +706,128 @@ func init() {
 
 	slices.Sort(cache.NumaDomainList)
 	cache.NumaDomainList = slices.Compact(cache.NumaDomainList)
+
+	slices.Sort(cache.L3DomainList)
+	cache.L3DomainList = slices.Compact(cache.L3DomainList)
+
+	slices.Sort(cache.CoreTypeList)
+	cache.CoreTypeList = slices.Compact(cache.CoreTypeList)
+
+	cache.PciDeviceData = getPciDevices(cache.CpuData)
+
+	topology.Store(cache)
+}
+
+// Refresh checks whether hardware threads went online or offline, e.g. after
+// SMT was toggled with /sys/devices/system/cpu/smt/control. If the online mask
+// changed, the topology is read again and the registered change callbacks are
+// called. It returns whether the topology changed.
+func Refresh() bool {
+	c := current()
+	if c != nil && readOnlineMask() == c.OnlineMask {
+		return false
+	}
+	var generation uint64
+	if c != nil {
+		generation = c.Generation + 1
+	}
+	initTopology(generation)
+	c = current()
+	cclogger.ComponentInfo("CCTopology", fmt.Sprintf("Online hardware threads changed to %s", c.OnlineMask))
+
+	changeCallbacks.mutex.Lock()
+	callbacks := slices.Clone(changeCallbacks.callbacks)
+	changeCallbacks.mutex.Unlock()
+	for _, f := range callbacks {
+		f()
+	}
+	return true
+}
+
+// OnChange registers a callback that is called by Refresh() when hardware
+// threads went online or offline
+func OnChange(callback func()) {
+	changeCallbacks.mutex.Lock()
+	changeCallbacks.callbacks = append(changeCallbacks.callbacks, callback)
+	changeCallbacks.mutex.Unlock()
+}
+
+// Generation gets the generation of the topology. It is incremented each time
+// Refresh() detects a change of the online hardware threads, so users of the
+// topology can detect changes.
+func Generation() uint64 {
+	return current().Generation
+}
+
+// OfflineHwthreadList gets the list of offline hardware thread IDs
+func OfflineHwthreadList() []int {
+	cache := current()
+	return slices.Clone(cache.OfflineList)
+}
+
+// IsHwthreadOnline checks whether a hardware thread is online
+func IsHwthreadOnline(cpuID int) bool {
+	cache := current()
+	return slices.Contains(cache.HwthreadList, cpuID)
 }
 
 // SocketList gets the list of CPU socket IDs
 func SocketList() []int {
+	cache := current()
 	return slices.Clone(cache.SocketList)
 }
 
-// HwthreadList gets the list of hardware thread IDs in the order of listing in /proc/cpuinfo
+// HwthreadList gets the list of online hardware thread IDs in the order of listing in /proc/cpuinfo
 func HwthreadList() []int {
+	cache := current()
 	return slices.Clone(cache.HwthreadList)
 }
 
 // CoreList gets the list of CPU core IDs in the order of listing in /proc/cpuinfo
 func CoreList() []int {
+	cache := current()
 	return slices.Clone(cache.CoreList)
 }
 
 // Get list of NUMA node IDs
 func NumaNodeList() []int {
+	cache := current()
 	return slices.Clone(cache.NumaDomainList)
 }
 
-// DieList gets the list of CPU die IDs
+// DieList gets the list of CPU die IDs. The die IDs are numbered in the order
+// of socket and socket local die ID, so they are unique on the node.
 func DieList() []int {
+	cache := current()
 	if len(cache.DieList) > 0 {
 		return slices.Clone(cache.DieList)
 	}
 	return SocketList()
 }
 
+// L3DomainList gets the list of L3 cache IDs
+func L3DomainList() []int {
+	cache := current()
+	return slices.Clone(cache.L3DomainList)
+}
+
+// CoreTypeList gets the list of core types (CORE_TYPE_PERFORMANCE, CORE_TYPE_EFFICIENCY)
+func CoreTypeList() []int {
+	cache := current()
+	return slices.Clone(cache.CoreTypeList)
+}
+
+// CoreTypeName gets the name of a core type
+func CoreTypeName(coreType int) string {
+	switch coreType {
+	case CORE_TYPE_PERFORMANCE:
+		return "performance"
+	case CORE_TYPE_EFFICIENCY:
+		return "efficiency"
+	}
+	return "unknown"
+}
+
 // GetTypeList gets the list of specified type using the naming format inside ClusterCockpit
 func GetTypeList(topology_type string) []int {
 	switch topology_type {
@@ -288,6 +843,10 @@ func GetTypeList(topology_type string) []int {
 		return CoreList()
 	case "hwthread":
 		return HwthreadList()
+	case "l3Domain":
+		return L3DomainList()
+	case "coreType":
+		return CoreTypeList()
 	}
 	return []int{}
 }
@@ -306,12 +865,20 @@ func GetTypeId(hwt HwthreadEntry, topology_type string) (int, error) {
 		return hwt.Core, nil
 	case "hwthread":
 		return hwt.CpuID, nil
+	case "l3Domain":
+		if hwt.L3Domain < 0 {
+			return -1, fmt.Errorf("hardware thread %d has no L3 cache", hwt.CpuID)
+		}
+		return hwt.L3Domain, nil
+	case "coreType":
+		return hwt.CoreType, nil
 	}
 	return -1, fmt.Errorf("unknown topology type '%s'", topology_type)
 }
 
 // CpuData returns CPU data for each hardware thread
 func CpuData() []HwthreadEntry {
+	cache := current()
 	// return a deep copy to protect cache data
 	c := slices.Clone(cache.CpuData)
 	for i := range c {
@@ -320,6 +887,40 @@ func CpuData() []HwthreadEntry {
 	return c
 }
 
+// CacheData returns the data of all CPU caches
+func CacheData() []CacheEntry {
+	cache := current()
+	// return a deep copy to protect cache data
+	c := slices.Clone(cache.CacheData)
+	for i := range c {
+		c[i].SharedCPUsList = slices.Clone(cache.CacheData[i].SharedCPUsList)
+	}
+	return c
+}
+
+// NumaNodeData returns the data of all NUMA nodes
+func NumaNodeData() []NumaNodeEntry {
+	cache := current()
+	// return a deep copy to protect cache data
+	n := slices.Clone(cache.NumaNodeData)
+	for i := range n {
+		n[i].Distances = maps.Clone(cache.NumaNodeData[i].Distances)
+	}
+	return n
+}
+
+// PciDeviceData returns the data of all GPUs, InfiniBand HCAs, NICs and NVMe controllers
+func PciDeviceData() []PciDeviceEntry {
+	cache := current()
+	// return a deep copy to protect cache data
+	d := slices.Clone(cache.PciDeviceData)
+	for i := range d {
+		d[i].Names = slices.Clone(cache.PciDeviceData[i].Names)
+		d[i].LocalCPUsList = slices.Clone(cache.PciDeviceData[i].LocalCPUsList)
+	}
+	return d
+}
+
 // Structure holding basic information about a CPU
 type CpuInformation struct {
 	NumHWthreads   int
@@ -328,10 +929,14 @@ type CpuInformation struct {
 	NumDies        int
 	NumCores       int
 	NumNumaDomains int
+	NumL3Domains   int
+	NumCoreTypes   int
+	NumOffline     int
 }
 
 // CpuInformation reports basic information about the CPU
 func CpuInfo() CpuInformation {
+	cache := current()
 	return CpuInformation{
 		NumNumaDomains: len(cache.NumaDomainList),
 		SMTWidth:       len(cache.SMTList),
@@ -339,12 +944,16 @@ func CpuInfo() CpuInformation {
 		NumCores:       len(cache.CoreList),
 		NumSockets:     len(cache.SocketList),
 		NumHWthreads:   len(cache.HwthreadList),
+		NumL3Domains:   len(cache.L3DomainList),
+		NumCoreTypes:   len(cache.CoreTypeList),
+		NumOffline:     len(cache.OfflineList),
 	}
 }
 
 // GetHwthreadSocket gets the CPU socket ID for a given hardware thread ID
 // In case hardware thread ID is not found -1 is returned
 func GetHwthreadSocket(cpuID int) int {
+	cache := current()
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
 		if d.CpuID == cpuID {
@@ -357,6 +966,7 @@ func GetHwthreadSocket(cpuID int) int {
 // GetHwthreadNumaDomain gets the NUMA domain ID for a given hardware thread ID
 // In case hardware thread ID is not found -1 is returned
 func GetHwthreadNumaDomain(cpuID int) int {
+	cache := current()
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
 		if d.CpuID == cpuID {
@@ -369,6 +979,7 @@ func GetHwthreadNumaDomain(cpuID int) int {
 // GetHwthreadDie gets the CPU die ID for a given hardware thread ID
 // In case hardware thread ID is not found -1 is returned
 func GetHwthreadDie(cpuID int) int {
+	cache := current()
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
 		if d.CpuID == cpuID {
@@ -381,6 +992,7 @@ func GetHwthreadDie(cpuID int) int {
 // GetHwthreadCore gets the CPU core ID for a given hardware thread ID
 // In case hardware thread ID is not found -1 is returned
 func GetHwthreadCore(cpuID int) int {
+	cache := current()
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
 		if d.CpuID == cpuID {
@@ -390,8 +1002,91 @@ func GetHwthreadCore(cpuID int) int {
 	return -1
 }
 
+// GetHwthreadL3Domain gets the L3 cache ID for a given hardware thread ID
+// In case hardware thread ID is not found or has no L3 cache -1 is returned
+func GetHwthreadL3Domain(cpuID int) int {
+	cache := current()
+	for i := range cache.CpuData {
+		d := &cache.CpuData[i]
+		if d.CpuID == cpuID {
+			return d.L3Domain
+		}
+	}
+	return -1
+}
+
+// GetHwthreadCoreType gets the core type for a given hardware thread ID
+// In case hardware thread ID is not found -1 is returned
+func GetHwthreadCoreType(cpuID int) int {
+	cache := current()
+	for i := range cache.CpuData {
+		d := &cache.CpuData[i]
+		if d.CpuID == cpuID {
+			return d.CoreType
+		}
+	}
+	return -1
+}
+
+// GetHwthreadCaches gets all caches used by a given hardware thread ID
+func GetHwthreadCaches(cpuID int) []CacheEntry {
+	cache := current()
+	caches := make([]CacheEntry, 0)
+	for i := range cache.CacheData {
+		d := &cache.CacheData[i]
+		if slices.Contains(d.SharedCPUsList, cpuID) {
+			c := *d
+			c.SharedCPUsList = slices.Clone(d.SharedCPUsList)
+			caches = append(caches, c)
+		}
+	}
+	return caches
+}
+
+// GetHwthreadCacheSize gets the size in bytes of the data or unified cache of
+// the given level for a given hardware thread ID
+// In case the cache is not found -1 is returned
+func GetHwthreadCacheSize(cpuID int, level int) int64 {
+	cache := current()
+	for i := range cache.CacheData {
+		d := &cache.CacheData[i]
+		if d.Level == level && d.Type != "Instruction" && slices.Contains(d.SharedCPUsList, cpuID) {
+			return d.Size
+		}
+	}
+	return -1
+}
+
+// GetNumaNodeMemory gets the memory size in bytes of a NUMA node
+// In case NUMA node ID is not found -1 is returned
+func GetNumaNodeMemory(numaDomain int) int64 {
+	cache := current()
+	for i := range cache.NumaNodeData {
+		if cache.NumaNodeData[i].ID == numaDomain {
+			return cache.NumaNodeData[i].MemTotal
+		}
+	}
+	return -1
+}
+
+// GetNumaDistance gets the distance between two NUMA nodes
+// In case one of the NUMA node IDs is not found -1 is returned
+func GetNumaDistance(from, to int) int {
+	cache := current()
+	for i := range cache.NumaNodeData {
+		if cache.NumaNodeData[i].ID == from {
+			if d, ok := cache.NumaNodeData[i].Distances[to]; ok {
+				return d
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
 // GetSocketHwthreads gets all hardware thread IDs associated with a CPU socket
 func GetSocketHwthreads(socket int) []int {
+	cache := current()
 	cpuList := make([]int, 0)
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
@@ -404,6 +1099,7 @@ func GetSocketHwthreads(socket int) []int {
 
 // GetNumaDomainHwthreads gets the all hardware thread IDs associated with a NUMA domain
 func GetNumaDomainHwthreads(numaDomain int) []int {
+	cache := current()
 	cpuList := make([]int, 0)
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
@@ -416,6 +1112,7 @@ func GetNumaDomainHwthreads(numaDomain int) []int {
 
 // GetDieHwthreads gets all hardware thread IDs associated with a CPU die
 func GetDieHwthreads(die int) []int {
+	cache := current()
 	cpuList := make([]int, 0)
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
@@ -428,6 +1125,7 @@ func GetDieHwthreads(die int) []int {
 
 // GetCoreHwthreads get all hardware thread IDs associated with a CPU core
 func GetCoreHwthreads(core int) []int {
+	cache := current()
 	cpuList := make([]int, 0)
 	for i := range cache.CpuData {
 		d := &cache.CpuData[i]
@@ -438,6 +1136,32 @@ func GetCoreHwthreads(core int) []int {
 	return cpuList
 }
 
+// GetL3DomainHwthreads gets all hardware thread IDs sharing an L3 cache
+func GetL3DomainHwthreads(l3Domain int) []int {
+	cache := current()
+	cpuList := make([]int, 0)
+	for i := range cache.CpuData {
+		d := &cache.CpuData[i]
+		if d.L3Domain == l3Domain && l3Domain >= 0 {
+			cpuList = append(cpuList, d.CpuID)
+		}
+	}
+	return cpuList
+}
+
+// GetCoreTypeHwthreads gets all hardware thread IDs of a core type
+func GetCoreTypeHwthreads(coreType int) []int {
+	cache := current()
+	cpuList := make([]int, 0)
+	for i := range cache.CpuData {
+		d := &cache.CpuData[i]
+		if d.CoreType == coreType {
+			cpuList = append(cpuList, d.CpuID)
+		}
+	}
+	return cpuList
+}
+
 // GetTypeList gets the list of specified type using the naming format inside ClusterCockpit
 func GetTypeHwthreads(topology_type string, id int) []int {
 	switch topology_type {
@@ -453,6 +1177,115 @@ func GetTypeHwthreads(topology_type string, id int) []int {
 		return GetCoreHwthreads(id)
 	case "hwthread":
 		return []int{id}
+	case "l3Domain":
+		return GetL3DomainHwthreads(id)
+	case "coreType":
+		return GetCoreTypeHwthreads(id)
 	}
 	return []int{}
 }
+
+// NormalizePciAddress converts a PCI address like 00000000:3B:00.0 (NVML) to
+// the format used in sysfs (0000:3b:00.0)
+func NormalizePciAddress(address string) (string, error) {
+	var domain, bus, device, function uint64
+	_, err := fmt.Sscanf(strings.ToLower(strings.TrimSpace(address)), "%x:%x:%x.%x", &domain, &bus, &device, &function)
+	if err != nil {
+		return "", fmt.Errorf("invalid PCI address '%s': %w", address, err)
+	}
+	return fmt.Sprintf("%04x:%02x:%02x.%x", domain, bus, device, function), nil
+}
+
+// GetPciDeviceNumaDomain gets the NUMA domain ID of a PCI device like a GPU
+// In case the PCI device is not found or has no NUMA affinity -1 is returned
+func GetPciDeviceNumaDomain(address string) int {
+	cache := current()
+	pciAddress, err := NormalizePciAddress(address)
+	if err != nil {
+		return -1
+	}
+	buffer, err := hostfs.ReadFile(filepath.Join(SYSFS_PCIBASE, pciAddress, "numa_node"))
+	if err != nil {
+		return -1
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(string(buffer)))
+	if err != nil || !slices.Contains(cache.NumaDomainList, id) {
+		return -1
+	}
+	return id
+}
+
+// GetPciDevicesOfType gets all PCI devices of a type (gpu, ib, nic or nvme)
+func GetPciDevicesOfType(deviceType string) []PciDeviceEntry {
+	devices := make([]PciDeviceEntry, 0)
+	for _, d := range PciDeviceData() {
+		if d.Type == deviceType {
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+// GetPciDevice gets the PCI device with the given PCI address
+// In case the PCI device is not found false is returned
+func GetPciDevice(address string) (PciDeviceEntry, bool) {
+	pciAddress, err := NormalizePciAddress(address)
+	if err != nil {
+		return PciDeviceEntry{}, false
+	}
+	for _, d := range PciDeviceData() {
+		if d.Address == pciAddress {
+			return d, true
+		}
+	}
+	return PciDeviceEntry{}, false
+}
+
+// GetNamedPciDevice gets the PCI device of a type with the given name like the
+// InfiniBand HCA mlx5_0
+// In case the PCI device is not found false is returned
+func GetNamedPciDevice(deviceType string, name string) (PciDeviceEntry, bool) {
+	for _, d := range PciDeviceData() {
+		if d.Type == deviceType && slices.Contains(d.Names, name) {
+			return d, true
+		}
+	}
+	return PciDeviceEntry{}, false
+}
+
+// GetPciDeviceSocket gets the CPU socket ID of a PCI device
+// In case the PCI device is not found or is local to multiple sockets -1 is returned
+func GetPciDeviceSocket(address string) int {
+	cache := current()
+	pciAddress, err := NormalizePciAddress(address)
+	if err != nil {
+		return -1
+	}
+	if d, ok := GetPciDevice(pciAddress); ok {
+		return d.Socket
+	}
+	_, socket, _ := pciDeviceAffinity(pciAddress, cache.CpuData)
+	return socket
+}
+
+// GetPciDeviceLocalCPUs gets the hardware thread IDs local to a PCI device
+func GetPciDeviceLocalCPUs(address string) []int {
+	cache := current()
+	pciAddress, err := NormalizePciAddress(address)
+	if err != nil {
+		return []int{}
+	}
+	if d, ok := GetPciDevice(pciAddress); ok {
+		return d.LocalCPUsList
+	}
+	_, _, localCPUsList := pciDeviceAffinity(pciAddress, cache.CpuData)
+	return localCPUsList
+}
+
+// GetClassDevicePciAddress gets the PCI address of a device in a sysfs class
+// like the block device nvme0n1 (class block) or the network interface ib0
+// (class net)
+// In case the device is not found or is no PCI device false is returned
+func GetClassDevicePciAddress(class string, name string) (string, bool) {
+	return classDevicePciAddress(class, name)
+}
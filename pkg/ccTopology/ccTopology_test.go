@@ -0,0 +1,138 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package ccTopology
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	cclog "github.com/ClusterCockpit/cc-lib/v2/ccLogger"
+	"github.com/ClusterCockpit/cc-metric-collector/pkg/hostfs"
+)
+
+func TestMain(m *testing.M) {
+	cclog.Init("crit", false)
+	os.Exit(m.Run())
+}
+
+// writeSysfs creates a sysfs tree with the given number of sockets, dies per
+// socket, cores per die and hardware threads per core. The hardware threads
+// are numbered like on x86: first the first thread of all cores, then the
+// second one. It returns the expected hardware threads of each die.
+func writeSysfs(t *testing.T, sockets, dies, cores, threads int) (string, [][]int) {
+	t.Helper()
+	root := t.TempDir()
+	write := func(path string, format string, args ...any) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, fmt.Appendf(nil, format+"\n", args...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	numCores := sockets * dies * cores
+	write("devices/system/cpu/online", "0-%d", numCores*threads-1)
+	if err := os.MkdirAll(filepath.Join(root, "bus/pci/devices"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dieHwthreads := make([][]int, sockets*dies)
+	for s := range sockets {
+		write(fmt.Sprintf("devices/system/node/node%d/meminfo", s), "Node %d MemTotal:       1048576 kB", s)
+		for d := range dies {
+			for c := range cores {
+				core := (s*dies+d)*cores + c
+				siblings := make([]int, 0, threads)
+				for i := range threads {
+					siblings = append(siblings, i*numCores+core)
+				}
+				for _, cpu := range siblings {
+					base := fmt.Sprintf("devices/system/cpu/cpu%d", cpu)
+					write(base+"/topology/physical_package_id", "%d", s)
+					write(base+"/topology/die_id", "%d", d)
+					write(base+"/topology/core_id", "%d", d*cores+c)
+					write(base+"/topology/core_cpus_list", "%d,%d", siblings[0], siblings[len(siblings)-1])
+					if err := os.MkdirAll(filepath.Join(root, base, fmt.Sprintf("node%d", s)), 0o755); err != nil {
+						t.Fatal(err)
+					}
+				}
+				die := &dieHwthreads[s*dies+d]
+				*die = append(*die, siblings...)
+			}
+		}
+	}
+	for _, die := range dieHwthreads {
+		slices.Sort(die)
+	}
+	return root, dieHwthreads
+}
+
+func TestDies(t *testing.T) {
+	tests := []struct {
+		name                 string
+		sockets, dies, cores int
+		threads              int
+	}{
+		{"one socket", 1, 1, 4, 2},
+		// die_id is 0 on both sockets
+		{"two sockets", 2, 1, 4, 2},
+		{"two sockets with two dies", 2, 2, 2, 2},
+		{"four sockets without SMT", 4, 1, 2, 1},
+	}
+	defer func() {
+		if err := hostfs.Init(hostfs.HostfsConfig{}); err != nil {
+			t.Error(err)
+		}
+		Init()
+	}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, want := writeSysfs(t, tt.sockets, tt.dies, tt.cores, tt.threads)
+			if err := hostfs.Init(hostfs.HostfsConfig{SysRoot: root}); err != nil {
+				t.Fatal(err)
+			}
+			Init()
+
+			wantList := make([]int, len(want))
+			for i := range want {
+				wantList[i] = i
+			}
+			if got := DieList(); !slices.Equal(got, wantList) {
+				t.Errorf("DieList() = %v, want %v", got, wantList)
+			}
+			if got := GetTypeList("die"); !slices.Equal(got, wantList) {
+				t.Errorf("GetTypeList(die) = %v, want %v", got, wantList)
+			}
+			for die, hwthreads := range want {
+				if got := GetTypeHwthreads("die", die); !slices.Equal(got, hwthreads) {
+					t.Errorf("GetTypeHwthreads(die, %d) = %v, want %v", die, got, hwthreads)
+				}
+				for _, cpu := range hwthreads {
+					if got := GetHwthreadDie(cpu); got != die {
+						t.Errorf("GetHwthreadDie(%d) = %d, want %d", cpu, got, die)
+					}
+				}
+			}
+			for _, d := range CpuData() {
+				id, err := GetTypeId(d, "die")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Contains(want[id], d.CpuID) {
+					t.Errorf("GetTypeId(%d, die) = %d, not the die of the hardware thread", d.CpuID, id)
+				}
+			}
+			if got := Export().Die; !slices.EqualFunc(got, want, slices.Equal) {
+				t.Errorf("Export().Die = %v, want %v", got, want)
+			}
+		})
+	}
+}
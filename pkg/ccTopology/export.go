@@ -0,0 +1,133 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package ccTopology
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// Structure holding an accelerator in the format of the ClusterCockpit
+// cluster configuration
+type AcceleratorExport struct {
+	ID   string `json:"id"`   // PCI address of the accelerator
+	Type string `json:"type"` // Accelerator type like "Nvidia GPU"
+}
+
+// Structure holding the topology of the node. The hardware thread lists use the
+// format of the topology section of the ClusterCockpit cluster configuration:
+// each list contains the hardware threads of an entity, ordered by the IDs of
+// the entities, so the type-id of the metrics selects the list. The core_id and
+// die_id of sysfs are only unique within a socket, so cores are identified by
+// their first hardware thread, the type-id of core metrics, and dies by their
+// die ID, which is numbered in the order of socket and socket local die ID. The
+// caches, NUMA nodes and PCI devices add the relations
+// not covered by this format.
+type TopologyExport struct {
+	Generation   uint64              `json:"generation"`          // Incremented with each change of the online hardware threads
+	Node         []int               `json:"node"`                // Online hardware threads
+	Offline      []int               `json:"offline,omitempty"`   // Offline hardware threads
+	Socket       [][]int             `json:"socket"`              // Hardware threads per socket
+	MemoryDomain [][]int             `json:"memoryDomain"`        // Hardware threads per NUMA domain
+	Die          [][]int             `json:"die"`                 // Hardware threads per die, ordered by socket and die ID
+	Core         [][]int             `json:"core"`                // Hardware threads per core, ordered by the first hardware thread
+	L3Domain     [][]int             `json:"l3Domain,omitempty"`  // Hardware threads per L3 cache
+	CoreType     map[string][]int    `json:"coreType,omitempty"`  // Hardware threads per core type name
+	Accelerators []AcceleratorExport `json:"accelerators"`        // GPUs
+	Caches       []CacheEntry        `json:"caches,omitempty"`    // CPU caches
+	NumaNodes    []NumaNodeEntry     `json:"numaNodes,omitempty"` // NUMA nodes with memory and distances
+	PciDevices   []PciDeviceEntry    `json:"pciDevices"`          // GPUs, HCAs, NICs and NVMe controllers
+}
+
+// Accelerator types of the GPU vendors in the ClusterCockpit cluster configuration
+var acceleratorTypes = map[string]string{
+	"0x10de": "Nvidia GPU",
+	"0x1002": "AMD GPU",
+	"0x8086": "Intel GPU",
+}
+
+// Export returns the current topology of the node
+func Export() TopologyExport {
+	cache := current()
+
+	// Group the hardware threads by an ID of the hardware thread entries
+	group := func(ids []int, id func(HwthreadEntry) int) [][]int {
+		groups := make([][]int, 0, len(ids))
+		for _, i := range ids {
+			hwthreads := make([]int, 0)
+			for _, d := range cache.CpuData {
+				if id(d) == i {
+					hwthreads = append(hwthreads, d.CpuID)
+				}
+			}
+			groups = append(groups, hwthreads)
+		}
+		return groups
+	}
+
+	// Cores are identified by their first hardware thread like in the type-id
+	// of core metrics
+	coreHwthreads := make(map[int][]int)
+	for _, d := range cache.CpuData {
+		core := d.CpuID
+		if len(d.CoreCPUsList) > 0 {
+			core = d.CoreCPUsList[0]
+		}
+		coreHwthreads[core] = append(coreHwthreads[core], d.CpuID)
+	}
+	cores := make([][]int, 0, len(coreHwthreads))
+	for _, core := range slices.Sorted(maps.Keys(coreHwthreads)) {
+		cores = append(cores, coreHwthreads[core])
+	}
+
+	t := TopologyExport{
+		Generation:   cache.Generation,
+		Node:         slices.Clone(cache.HwthreadList),
+		Offline:      slices.Clone(cache.OfflineList),
+		Socket:       group(cache.SocketList, func(d HwthreadEntry) int { return d.Socket }),
+		MemoryDomain: group(cache.NumaDomainList, func(d HwthreadEntry) int { return d.NumaDomain }),
+		Die:          group(cache.DieList, func(d HwthreadEntry) int { return d.Die }),
+		Core:         cores,
+		L3Domain:     group(cache.L3DomainList, func(d HwthreadEntry) int { return d.L3Domain }),
+		CoreType:     make(map[string][]int),
+		Accelerators: make([]AcceleratorExport, 0),
+		Caches:       make([]CacheEntry, 0, len(cache.CacheData)),
+		NumaNodes:    make([]NumaNodeEntry, 0, len(cache.NumaNodeData)),
+		PciDevices:   make([]PciDeviceEntry, 0, len(cache.PciDeviceData)),
+	}
+	for _, coreType := range cache.CoreTypeList {
+		hwthreads := group([]int{coreType}, func(d HwthreadEntry) int { return d.CoreType })
+		t.CoreType[CoreTypeName(coreType)] = hwthreads[0]
+	}
+	for _, c := range cache.CacheData {
+		c.SharedCPUsList = slices.Clone(c.SharedCPUsList)
+		t.Caches = append(t.Caches, c)
+	}
+	for _, n := range cache.NumaNodeData {
+		n.Distances = maps.Clone(n.Distances)
+		t.NumaNodes = append(t.NumaNodes, n)
+	}
+	for _, d := range cache.PciDeviceData {
+		d.Names = slices.Clone(d.Names)
+		d.LocalCPUsList = slices.Clone(d.LocalCPUsList)
+		t.PciDevices = append(t.PciDevices, d)
+		if d.Type == "gpu" {
+			t.Accelerators = append(t.Accelerators, AcceleratorExport{
+				ID:   d.Address,
+				Type: acceleratorTypes[d.Vendor],
+			})
+		}
+	}
+	return t
+}
+
+// ExportJSON returns the current topology of the node in JSON format
+func ExportJSON() ([]byte, error) {
+	return json.MarshalIndent(Export(), "", "  ")
+}
@@ -0,0 +1,41 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package hostfs
+
+import (
+	"sync"
+	"time"
+)
+
+// The clock used for the timestamps of the collectors. Collectors deriving
+// rates use Now() instead of time.Now(), so a replayed fixture can run with a
+// simulated clock instead of waiting for the recorded intervals.
+var clock = struct {
+	now   func() time.Time
+	mutex sync.RWMutex
+}{
+	now: time.Now,
+}
+
+// Now returns the current time of the clock, by default the wall clock time
+func Now() time.Time {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+	return clock.now()
+}
+
+// SetClock replaces the clock returned by Now(). With nil, the wall clock is
+// used again.
+func SetClock(now func() time.Time) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	if now == nil {
+		now = time.Now
+	}
+	clock.now = now
+}
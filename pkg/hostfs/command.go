@@ -0,0 +1,221 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package hostfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Cmd describes an external command like exec.Cmd. Commands run through this
+// package are recorded to or replayed from a fixture.
+type Cmd struct {
+	Path  string   // Command to run
+	Args  []string // Command line arguments without the command
+	Stdin string   // Input of the command
+}
+
+// Recorded output of a command in a fixture
+type commandRecord struct {
+	Command  []string `json:"command"`
+	Stdin    string   `json:"stdin,omitempty"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr,omitempty"`
+	ExitCode int      `json:"exit_code"`
+	Error    string   `json:"error,omitempty"`
+	Errno    int      `json:"errno,omitempty"` // System error number, e.g. for permission errors
+}
+
+// ReplayError is returned for commands that failed while recording a fixture
+type ReplayError struct {
+	Command  string
+	ExitCode int
+	Message  string
+	Errno    syscall.Errno
+}
+
+func (e *ReplayError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the system error number, so errors.Is(err, syscall.EACCES) works
+// like for the recorded error
+func (e *ReplayError) Unwrap() error {
+	if e.Errno == 0 {
+		return nil
+	}
+	return e.Errno
+}
+
+// Command returns the Cmd to run the command with the given arguments
+func Command(name string, args ...string) *Cmd {
+	return &Cmd{
+		Path: name,
+		Args: args,
+	}
+}
+
+// String returns the command line of the command
+func (c *Cmd) String() string {
+	return strings.Join(append([]string{c.Path}, c.Args...), " ")
+}
+
+// key returns the file name of the command output in a fixture
+func (c *Cmd) key() string {
+	h := sha256.New()
+	h.Write([]byte(c.Path))
+	for _, a := range c.Args {
+		h.Write([]byte{0})
+		h.Write([]byte(a))
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(c.Stdin))
+	return hex.EncodeToString(h.Sum(nil))[:16] + ".json"
+}
+
+// Run runs the command and returns its standard output and standard error.
+// When recording a fixture, the outputs are stored in the directory of the
+// current tick. When replaying a fixture, the stored outputs are returned.
+func (c *Cmd) Run() ([]byte, []byte, error) {
+	mode, tickDir, initDir := fixtureState()
+	if mode == fixtureReplay {
+		return c.replay(tickDir, initDir)
+	}
+
+	cmd := exec.Command(c.Path, c.Args...)
+	if len(c.Stdin) > 0 {
+		cmd.Stdin = strings.NewReader(c.Stdin)
+	}
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+
+	if mode == fixtureRecord {
+		r := commandRecord{
+			Command: append([]string{c.Path}, c.Args...),
+			Stdin:   c.Stdin,
+			Stdout:  stdout.String(),
+			Stderr:  stderr.String(),
+		}
+		if err != nil {
+			r.ExitCode = ExitCode(err)
+			r.Error = err.Error()
+			r.Errno = errnoOf(err)
+		}
+		c.record(r, tickDir)
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// record stores the outputs of the command in the directory of the current tick
+func (c *Cmd) record(r commandRecord, tickDir string) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return
+	}
+	dir := filepath.Join(tickDir, FIXTURE_COMMANDS)
+	if err := os.MkdirAll(dir, 0o755); err == nil {
+		_ = os.WriteFile(filepath.Join(dir, c.key()), data, 0o644)
+	}
+}
+
+// Output runs the command and returns its standard output like exec.Cmd.Output()
+func (c *Cmd) Output() ([]byte, error) {
+	stdout, _, err := c.Run()
+	return stdout, err
+}
+
+// replay returns the recorded outputs of the command. The outputs are looked up
+// in the directory of the current tick and then in the init directory.
+func (c *Cmd) replay(tickDir, initDir string) ([]byte, []byte, error) {
+	var data []byte
+	var err error
+	for _, dir := range []string{tickDir, initDir} {
+		data, err = os.ReadFile(filepath.Join(dir, FIXTURE_COMMANDS, c.key()))
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("hostfs: no recorded output for command '%s'", c.String())
+	}
+	var r commandRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, nil, fmt.Errorf("hostfs: invalid recorded output for command '%s': %w", c.String(), err)
+	}
+	if len(r.Error) > 0 {
+		return []byte(r.Stdout), []byte(r.Stderr), &ReplayError{
+			Command:  c.String(),
+			ExitCode: r.ExitCode,
+			Message:  r.Error,
+			Errno:    syscall.Errno(r.Errno),
+		}
+	}
+	return []byte(r.Stdout), []byte(r.Stderr), nil
+}
+
+// errnoOf returns the system error number of an error, e.g. for a command that
+// could not be started because of missing permissions, or 0 without one
+func errnoOf(err error) int {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return int(errno)
+	}
+	return 0
+}
+
+// ExitCode returns the exit code of a failed command or -1 if the command
+// could not be started
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var replayErr *ReplayError
+	if errors.As(err, &replayErr) {
+		return replayErr.ExitCode
+	}
+	return -1
+}
+
+// LookPath searches for an executable like exec.LookPath(). When recording a
+// fixture, the result is stored, when replaying a fixture, the stored result
+// is returned, so the commands match the recorded ones.
+func LookPath(file string) (string, error) {
+	c := Cmd{Path: "LookPath", Args: []string{file}}
+	mode, tickDir, initDir := fixtureState()
+	if mode == fixtureReplay {
+		stdout, _, err := c.replay(tickDir, initDir)
+		return string(stdout), err
+	}
+	p, err := exec.LookPath(file)
+	if mode == fixtureRecord {
+		r := commandRecord{
+			Command: []string{c.Path, file},
+			Stdout:  p,
+		}
+		if err != nil {
+			r.ExitCode = -1
+			r.Error = err.Error()
+			r.Errno = errnoOf(err)
+		}
+		c.record(r, tickDir)
+	}
+	return p, err
+}
@@ -0,0 +1,91 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package hostfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCommandRecordReplay(t *testing.T) {
+	// A file without execute permission fails to start with EACCES
+	noexec := filepath.Join(t.TempDir(), "noexec")
+	if err := os.WriteFile(noexec, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		cmd          *Cmd
+		wantStdout   string
+		wantErr      bool
+		wantExitCode int
+		wantErrno    syscall.Errno
+	}{
+		{"success", &Cmd{Path: "/bin/sh", Args: []string{"-c", "echo out"}}, "out\n", false, 0, 0},
+		{"stdin", &Cmd{Path: "/bin/sh", Args: []string{"-c", "cat"}, Stdin: "in\n"}, "in\n", false, 0, 0},
+		{"exit code", &Cmd{Path: "/bin/sh", Args: []string{"-c", "echo out; exit 3"}}, "out\n", true, 3, 0},
+		{"not executable", &Cmd{Path: noexec}, "", true, -1, syscall.EACCES},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := Record(dir); err != nil {
+				t.Fatal(err)
+			}
+			NextTick()
+			_, _, recordErr := tt.cmd.Run()
+			StopFixture()
+			if (recordErr != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", recordErr, tt.wantErr)
+			}
+
+			if err := Replay(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer StopFixture()
+			NextTick()
+			stdout, _, err := tt.cmd.Run()
+			if string(stdout) != tt.wantStdout {
+				t.Errorf("got stdout %q, want %q", stdout, tt.wantStdout)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replayed Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if code := ExitCode(err); code != tt.wantExitCode {
+				t.Errorf("got exit code %d, want %d", code, tt.wantExitCode)
+			}
+			if tt.wantErrno != 0 && !errors.Is(err, tt.wantErrno) {
+				t.Errorf("replayed error %v is not %v", err, tt.wantErrno)
+			}
+			if errors.Is(recordErr, syscall.EACCES) != errors.Is(err, syscall.EACCES) {
+				t.Errorf("replayed error %v differs from recorded error %v", err, recordErr)
+			}
+		})
+	}
+}
+
+func TestCommandReplayMissing(t *testing.T) {
+	dir := t.TempDir()
+	if err := Record(dir); err != nil {
+		t.Fatal(err)
+	}
+	StopFixture()
+	if err := Replay(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer StopFixture()
+	if _, _, err := Command("/bin/true").Run(); err == nil {
+		t.Error("got no error for a command without recorded output")
+	}
+}
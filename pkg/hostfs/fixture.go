@@ -0,0 +1,230 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package hostfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Fixture modes
+const (
+	fixtureOff = iota
+	fixtureRecord
+	fixtureReplay
+)
+
+// Directory of a fixture for the files accessed before the first tick, e.g. in
+// the Init() functions of the collectors
+const FIXTURE_INIT = "init"
+
+// Directory of a fixture for the recorded command outputs
+const FIXTURE_COMMANDS = "_commands"
+
+// A fixture holds the files and command outputs a collector accessed, one
+// directory per tick. Each tick directory is a tree with the host paths like
+// <fixture>/tick-0001/proc/stat.
+var fixture = struct {
+	mode  int
+	dir   string
+	tick  int
+	mutex sync.RWMutex
+}{}
+
+// tickName returns the directory name of a tick in a fixture
+func tickName(tick int) string {
+	if tick == 0 {
+		return FIXTURE_INIT
+	}
+	return fmt.Sprintf("tick-%04d", tick)
+}
+
+// FixtureTickDir returns the directory of a tick in a fixture directory. Tick 0
+// is the init directory.
+func FixtureTickDir(dir string, tick int) string {
+	return filepath.Join(dir, tickName(tick))
+}
+
+// Record starts recording all files accessed through this package and all
+// commands run through this package to the fixture directory. Recording starts
+// with the init directory, NextTick() switches to the next tick directory.
+func Record(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, FIXTURE_INIT), 0o755); err != nil {
+		return fmt.Errorf("hostfs: failed to create fixture directory: %w", err)
+	}
+	fixture.mutex.Lock()
+	fixture.mode = fixtureRecord
+	fixture.dir = dir
+	fixture.tick = 0
+	fixture.mutex.Unlock()
+	return nil
+}
+
+// Replay starts replaying a fixture recorded with Record(). All files and
+// command outputs are taken from the fixture directory instead of the host.
+// Replay starts with the init directory, NextTick() switches to the next tick
+// directory.
+func Replay(dir string) error {
+	info, err := os.Stat(filepath.Join(dir, FIXTURE_INIT))
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("hostfs: '%s' is no fixture directory", dir)
+	}
+	fixture.mutex.Lock()
+	fixture.mode = fixtureReplay
+	fixture.dir = dir
+	fixture.tick = 0
+	fixture.mutex.Unlock()
+	return nil
+}
+
+// StopFixture stops recording or replaying a fixture
+func StopFixture() {
+	fixture.mutex.Lock()
+	fixture.mode = fixtureOff
+	fixture.dir = ""
+	fixture.tick = 0
+	fixture.mutex.Unlock()
+}
+
+// NextTick switches to the next tick directory when recording or replaying a
+// fixture. It returns the number of the new tick. Without fixture, it does nothing.
+func NextTick() int {
+	fixture.mutex.Lock()
+	defer fixture.mutex.Unlock()
+	if fixture.mode == fixtureOff {
+		return 0
+	}
+	fixture.tick++
+	if fixture.mode == fixtureRecord {
+		if err := os.MkdirAll(filepath.Join(fixture.dir, tickName(fixture.tick)), 0o755); err != nil {
+			return fixture.tick
+		}
+	}
+	return fixture.tick
+}
+
+// FixtureTicks returns the number of recorded ticks in a fixture directory
+func FixtureTicks(dir string) (int, error) {
+	ticks := 0
+	for {
+		info, err := os.Stat(filepath.Join(dir, tickName(ticks+1)))
+		if err != nil || !info.IsDir() {
+			break
+		}
+		ticks++
+	}
+	if ticks == 0 {
+		if _, err := os.Stat(filepath.Join(dir, FIXTURE_INIT)); err != nil {
+			return 0, fmt.Errorf("hostfs: '%s' is no fixture directory", dir)
+		}
+	}
+	return ticks, nil
+}
+
+// fixtureState returns the mode, the directory of the current tick and the
+// init directory of the fixture
+func fixtureState() (int, string, string) {
+	fixture.mutex.RLock()
+	defer fixture.mutex.RUnlock()
+	if fixture.mode == fixtureOff {
+		return fixtureOff, "", ""
+	}
+	return fixture.mode, filepath.Join(fixture.dir, tickName(fixture.tick)), filepath.Join(fixture.dir, FIXTURE_INIT)
+}
+
+// replayPath returns the path of a host path in the fixture. Files are looked
+// up in the directory of the current tick and then in the init directory.
+func replayPath(path string, tickDir, initDir string) string {
+	p := filepath.Join(tickDir, path)
+	if _, err := os.Lstat(p); err == nil || tickDir == initDir {
+		return p
+	}
+	i := filepath.Join(initDir, path)
+	if _, err := os.Lstat(i); err == nil {
+		return i
+	}
+	return p
+}
+
+// replayHostPath returns the host path of a path in the fixture
+func replayHostPath(path string, tickDir, initDir string) string {
+	for _, dir := range []string{tickDir, initDir} {
+		if contains(dir, path) {
+			return filepath.Join("/", strings.TrimPrefix(path, dir))
+		}
+	}
+	return path
+}
+
+// recordFile copies the file with the given host path to the fixture and
+// returns the path of the copy
+func recordFile(path string, tickDir string) (string, error) {
+	data, err := os.ReadFile(localPath(path))
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(tickDir, path)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", fmt.Errorf("hostfs: failed to record '%s': %w", path, err)
+	}
+	if err := os.WriteFile(dest, data, 0o644); err != nil {
+		return "", fmt.Errorf("hostfs: failed to record '%s': %w", path, err)
+	}
+	return dest, nil
+}
+
+// recordEntry adds the file or directory with the given host path to the
+// fixture without copying the content of files. Existing entries are kept.
+// Symbolic links are followed, so the fixture contains the files below linked
+// directories, unless the link itself is requested.
+func recordEntry(path string, tickDir string, link bool) {
+	stat := os.Stat
+	if link {
+		stat = os.Lstat
+	}
+	info, err := stat(localPath(path))
+	if err != nil {
+		return
+	}
+	dest := filepath.Join(tickDir, path)
+	if _, err := os.Lstat(dest); err == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		if target, err := os.Readlink(localPath(path)); err == nil {
+			_ = os.Symlink(target, dest)
+		}
+	case info.IsDir():
+		_ = os.MkdirAll(dest, 0o755)
+	default:
+		_ = os.WriteFile(dest, nil, 0o644)
+	}
+}
+
+// recordDir adds a directory with its entries to the fixture
+func recordDir(path string, entries []os.DirEntry, tickDir string) {
+	recordEntry(path, tickDir, false)
+	for _, e := range entries {
+		recordEntry(filepath.Join(path, e.Name()), tickDir, false)
+	}
+}
+
+// sortedUnique sorts the list and removes duplicates
+func sortedUnique(list []string) []string {
+	slices.Sort(list)
+	return slices.Compact(list)
+}
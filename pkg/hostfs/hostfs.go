@@ -0,0 +1,266 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+// Package hostfs resolves paths of the host's procfs and sysfs against
+// configurable root directories. When the collector runs in a container, the
+// host's /proc and /sys can be mounted e.g. below /host. Collectors use the
+// host paths like /proc/stat and the functions of this package access the
+// files below the configured roots.
+package hostfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Configuration of the root directories
+type HostfsConfig struct {
+	HostRoot string `json:"host_root,omitempty"` // Root directory of the host filesystem (default /)
+	ProcRoot string `json:"proc_root,omitempty"` // Root directory of the host procfs (default <host_root>/proc)
+	SysRoot  string `json:"sys_root,omitempty"`  // Root directory of the host sysfs (default <host_root>/sys)
+}
+
+// A mount maps a host path to a local directory
+type mount struct {
+	host  string
+	local string
+}
+
+var roots = struct {
+	mounts    []mount // ordered from the most to the least specific host path
+	procLinks bool    // map the links to the own process in /proc to the init process
+	mutex     sync.RWMutex
+}{}
+
+// Links in the procfs pointing to the process reading them. With a configured
+// procfs root, they would describe the collector process in its container,
+// e.g. /proc/self/mounts would list the mounts of the container and /proc/net
+// the container's network namespace. So they are mapped to the init process
+// of the host.
+var procLinks = []mount{
+	{host: "/proc/self", local: "/proc/1"},
+	{host: "/proc/thread-self", local: "/proc/1"},
+	{host: "/proc/net", local: "/proc/1/net"},
+	{host: "/proc/mounts", local: "/proc/1/mounts"},
+}
+
+// Init sets the root directories. An empty configuration restores the
+// defaults, so host paths are used as they are.
+func Init(config HostfsConfig) error {
+	mounts := make([]mount, 0, 3)
+	add := func(host, local string) error {
+		if len(local) == 0 {
+			return nil
+		}
+		local = filepath.Clean(local)
+		if local == host {
+			return nil
+		}
+		info, err := os.Stat(local)
+		if err != nil {
+			return fmt.Errorf("hostfs: root directory for %s: %w", host, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("hostfs: root directory '%s' for %s is no directory", local, host)
+		}
+		mounts = append(mounts, mount{host: host, local: local})
+		return nil
+	}
+
+	procRoot := config.ProcRoot
+	sysRoot := config.SysRoot
+	if len(config.HostRoot) > 0 {
+		if len(procRoot) == 0 {
+			procRoot = filepath.Join(config.HostRoot, "proc")
+		}
+		if len(sysRoot) == 0 {
+			sysRoot = filepath.Join(config.HostRoot, "sys")
+		}
+	}
+	if err := add("/proc", procRoot); err != nil {
+		return err
+	}
+	if err := add("/sys", sysRoot); err != nil {
+		return err
+	}
+	if err := add("/", config.HostRoot); err != nil {
+		return err
+	}
+
+	roots.mutex.Lock()
+	roots.mounts = mounts
+	roots.procLinks = len(procRoot) > 0 && filepath.Clean(procRoot) != "/proc"
+	roots.mutex.Unlock()
+	return nil
+}
+
+// contains checks whether the path is the directory dir or inside of it
+func contains(dir, path string) bool {
+	if dir == "/" {
+		return strings.HasPrefix(path, "/")
+	}
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// localPath returns the path below the configured roots for a host path
+func localPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	roots.mutex.RLock()
+	defer roots.mutex.RUnlock()
+	if len(roots.mounts) == 0 {
+		return path
+	}
+	path = filepath.Clean(path)
+	if roots.procLinks {
+		for _, l := range procLinks {
+			if contains(l.host, path) {
+				path = filepath.Join(l.local, strings.TrimPrefix(path, l.host))
+				break
+			}
+		}
+	}
+	for _, m := range roots.mounts {
+		if contains(m.host, path) {
+			return filepath.Join(m.local, strings.TrimPrefix(path, m.host))
+		}
+	}
+	return path
+}
+
+// Path returns the local path for a host path like /proc/stat. Relative paths
+// are returned unchanged. When replaying a fixture, the path in the fixture is
+// returned.
+func Path(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	if mode, tickDir, initDir := fixtureState(); mode == fixtureReplay {
+		return replayPath(filepath.Clean(path), tickDir, initDir)
+	}
+	return localPath(path)
+}
+
+// HostPath returns the host path for a local path returned by Path(). It is
+// the inverse function of Path().
+func HostPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	path = filepath.Clean(path)
+	if mode, tickDir, initDir := fixtureState(); mode == fixtureReplay {
+		return replayHostPath(path, tickDir, initDir)
+	}
+	roots.mutex.RLock()
+	defer roots.mutex.RUnlock()
+	for _, m := range roots.mounts {
+		if contains(m.local, path) {
+			return filepath.Join(m.host, strings.TrimPrefix(path, m.local))
+		}
+	}
+	return path
+}
+
+// ReadFile reads the file with the given host path like os.ReadFile()
+func ReadFile(path string) ([]byte, error) {
+	if mode, tickDir, _ := fixtureState(); mode == fixtureRecord && filepath.IsAbs(path) {
+		copyPath, err := recordFile(filepath.Clean(path), tickDir)
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(copyPath)
+	}
+	return os.ReadFile(Path(path))
+}
+
+// Open opens the file with the given host path like os.Open(). When recording
+// a fixture, the recorded copy of the file is opened.
+func Open(path string) (*os.File, error) {
+	if mode, tickDir, _ := fixtureState(); mode == fixtureRecord && filepath.IsAbs(path) {
+		copyPath, err := recordFile(filepath.Clean(path), tickDir)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(copyPath)
+	}
+	return os.Open(Path(path))
+}
+
+// Stat returns the file information for the given host path like os.Stat()
+func Stat(path string) (fs.FileInfo, error) {
+	if mode, tickDir, _ := fixtureState(); mode == fixtureRecord && filepath.IsAbs(path) {
+		recordEntry(filepath.Clean(path), tickDir, false)
+	}
+	return os.Stat(Path(path))
+}
+
+// ReadDir reads the directory with the given host path like os.ReadDir()
+func ReadDir(path string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(Path(path))
+	if mode, tickDir, _ := fixtureState(); err == nil && mode == fixtureRecord && filepath.IsAbs(path) {
+		recordDir(filepath.Clean(path), entries, tickDir)
+	}
+	return entries, err
+}
+
+// Readlink returns the destination of the symbolic link with the given host
+// path like os.Readlink(). Links in procfs and sysfs are commonly relative, so
+// the destination is not mapped.
+func Readlink(path string) (string, error) {
+	if mode, tickDir, _ := fixtureState(); mode == fixtureRecord && filepath.IsAbs(path) {
+		recordEntry(filepath.Clean(path), tickDir, true)
+	}
+	return os.Readlink(Path(path))
+}
+
+// Access checks the access permissions of the file with the given host path
+// like unix.Access()
+func Access(path string, mode uint32) error {
+	if fmode, tickDir, _ := fixtureState(); fmode == fixtureRecord && filepath.IsAbs(path) {
+		recordEntry(filepath.Clean(path), tickDir, false)
+	}
+	return unix.Access(Path(path), mode)
+}
+
+// Glob returns the host paths of all files matching the pattern like
+// filepath.Glob(). The pattern is a host path.
+func Glob(pattern string) ([]string, error) {
+	mode, tickDir, initDir := fixtureState()
+	if mode == fixtureReplay && filepath.IsAbs(pattern) {
+		// Files in the directory of the current tick and in the init directory
+		matches := make([]string, 0)
+		for _, dir := range []string{tickDir, initDir} {
+			m, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range m {
+				matches = append(matches, filepath.Join("/", strings.TrimPrefix(p, dir)))
+			}
+		}
+		return sortedUnique(matches), nil
+	}
+
+	matches, err := filepath.Glob(localPath(pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i] = HostPath(matches[i])
+		if mode == fixtureRecord {
+			recordEntry(matches[i], tickDir, false)
+		}
+	}
+	return matches, nil
+}
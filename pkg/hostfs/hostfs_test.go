@@ -0,0 +1,58 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+package hostfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"proc", "sys"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		config HostfsConfig
+		path   string
+		want   string
+	}{
+		{"default", HostfsConfig{}, "/proc/stat", "/proc/stat"},
+		{"default self", HostfsConfig{}, "/proc/self/mounts", "/proc/self/mounts"},
+		{"default net", HostfsConfig{}, "/proc/net/snmp", "/proc/net/snmp"},
+		{"relative", HostfsConfig{HostRoot: root}, "proc/stat", "proc/stat"},
+		{"host root proc", HostfsConfig{HostRoot: root}, "/proc/stat", filepath.Join(root, "proc/stat")},
+		{"host root sys", HostfsConfig{HostRoot: root}, "/sys/class/hwmon", filepath.Join(root, "sys/class/hwmon")},
+		{"host root etc", HostfsConfig{HostRoot: root}, "/etc/os-release", filepath.Join(root, "etc/os-release")},
+		{"proc root", HostfsConfig{ProcRoot: root}, "/proc/stat", filepath.Join(root, "stat")},
+		{"proc root sys", HostfsConfig{ProcRoot: root}, "/sys/class/hwmon", "/sys/class/hwmon"},
+		// Links to the own process are mapped to the init process of the host
+		{"self", HostfsConfig{HostRoot: root}, "/proc/self/mounts", filepath.Join(root, "proc/1/mounts")},
+		{"self mountstats", HostfsConfig{ProcRoot: root}, "/proc/self/mountstats", filepath.Join(root, "1/mountstats")},
+		{"thread-self", HostfsConfig{ProcRoot: root}, "/proc/thread-self/status", filepath.Join(root, "1/status")},
+		{"net", HostfsConfig{ProcRoot: root}, "/proc/net/snmp6", filepath.Join(root, "1/net/snmp6")},
+		{"mounts", HostfsConfig{ProcRoot: root}, "/proc/mounts", filepath.Join(root, "1/mounts")},
+		{"no link", HostfsConfig{ProcRoot: root}, "/proc/selfish", filepath.Join(root, "selfish")},
+		{"sys root only", HostfsConfig{SysRoot: root}, "/proc/self/mounts", "/proc/self/mounts"},
+	}
+	defer Init(HostfsConfig{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Init(tt.config); err != nil {
+				t.Fatalf("Init() failed: %v", err)
+			}
+			if got := Path(tt.path); got != tt.want {
+				t.Errorf("Path(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
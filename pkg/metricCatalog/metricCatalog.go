@@ -0,0 +1,183 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved. This file is part of cc-lib.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// additional authors:
+// Holger Obermaier (NHR@KIT)
+
+// Package metricCatalog describes the metrics the collectors can send and
+// validates messages against these descriptions
+package metricCatalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	lp "github.com/ClusterCockpit/cc-lib/v2/ccMessage"
+)
+
+// Kind of the value of a metric
+type Kind string
+
+const (
+	Gauge   Kind = "gauge"   // Value at the time of the measurement, including differences and rates
+	Counter Kind = "counter" // Monotonically increasing value since an arbitrary point in time
+	Text    Kind = "text"    // Metric with a string value like a state name
+	Event   Kind = "event"   // Event message with a string payload
+)
+
+// Unit of metrics whose unit is determined at runtime
+const AnyUnit = "*"
+
+// Metric describes a metric a collector can send
+type Metric struct {
+	Name        string   `json:"name"`               // Metric name, '*' matches any characters for metrics named at runtime
+	Unit        string   `json:"unit,omitempty"`     // Unit sent as meta information, AnyUnit if determined at runtime
+	UnitTag     bool     `json:"unit_tag,omitempty"` // Unit sent as tag instead of meta information
+	Types       []string `json:"types,omitempty"`    // Type tags like "node" or "hwthread" the metric is sent for
+	Kind        Kind     `json:"kind"`               // Kind of the value
+	Description string   `json:"description"`        // Description of the metric
+	Options     []string `json:"options,omitempty"`  // Configuration options required to send the metric
+}
+
+// Collector holds the metrics a collector can send
+type Collector struct {
+	Name    string   `json:"collector"`
+	Metrics []Metric `json:"metrics"`
+}
+
+// Catalog holds the metrics of all collectors and looks them up by name
+type Catalog struct {
+	Collectors []Collector
+	names      map[string][]*Metric // Metrics with fixed names
+	patterns   []*Metric            // Metrics named at runtime
+}
+
+// New creates a catalog of the collectors' metrics
+func New(collectors []Collector) *Catalog {
+	c := &Catalog{
+		Collectors: collectors,
+		names:      make(map[string][]*Metric),
+		patterns:   make([]*Metric, 0),
+	}
+	slices.SortFunc(c.Collectors, func(a, b Collector) int { return strings.Compare(a.Name, b.Name) })
+	for i := range c.Collectors {
+		for j := range c.Collectors[i].Metrics {
+			m := &c.Collectors[i].Metrics[j]
+			if strings.Contains(m.Name, "*") {
+				c.patterns = append(c.patterns, m)
+			} else {
+				c.names[m.Name] = append(c.names[m.Name], m)
+			}
+		}
+	}
+	return c
+}
+
+// Lookup returns all descriptions matching the metric name. Different
+// collectors may send metrics with the same name, e.g. 'cpufreq'.
+func (c *Catalog) Lookup(name string) []*Metric {
+	metrics := slices.Clone(c.names[name])
+	for _, m := range c.patterns {
+		if ok, _ := path.Match(m.Name, name); ok {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// unitLocation returns where the unit of the metric is sent
+func (m *Metric) unitLocation() string {
+	if m.UnitTag {
+		return "tag"
+	}
+	return "meta"
+}
+
+// check checks a message against a single metric description
+func (m *Metric) check(msg lp.CCMessage) error {
+	switch m.Kind {
+	case Event:
+		if !msg.IsEvent() {
+			return fmt.Errorf("metric '%s' is expected to be an event", msg.Name())
+		}
+	case Text:
+		v, _ := msg.GetField("value")
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("metric '%s' has no string value", msg.Name())
+		}
+	default:
+		if !msg.IsMetric() {
+			return fmt.Errorf("metric '%s' has no numeric value", msg.Name())
+		}
+	}
+	if t, ok := msg.GetTag("type"); ok && len(m.Types) > 0 && !slices.Contains(m.Types, t) {
+		return fmt.Errorf("metric '%s' is sent for type '%s', expected one of %v", msg.Name(), t, m.Types)
+	}
+	if m.Unit == AnyUnit {
+		return nil
+	}
+	unit, _ := msg.GetMeta("unit")
+	if m.UnitTag {
+		unit, _ = msg.GetTag("unit")
+	}
+	if unit != m.Unit {
+		return fmt.Errorf("metric '%s' has %s unit '%s', expected '%s'", msg.Name(), m.unitLocation(), unit, m.Unit)
+	}
+	return nil
+}
+
+// Validate checks the name, the type tag and the unit of a message sent by a
+// collector against the catalog
+func (c *Catalog) Validate(msg lp.CCMessage) error {
+	metrics := c.Lookup(msg.Name())
+	if len(metrics) == 0 {
+		return fmt.Errorf("metric '%s' is not in the metric catalog", msg.Name())
+	}
+	var err error
+	for _, m := range metrics {
+		if err = m.check(msg); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// JSON returns the catalog in JSON format
+func (c *Catalog) JSON() ([]byte, error) {
+	return json.MarshalIndent(c.Collectors, "", "  ")
+}
+
+// Markdown returns the catalog as Markdown tables, one for each collector
+func (c *Catalog) Markdown() string {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	code := func(s []string) string {
+		if len(s) == 0 {
+			return ""
+		}
+		return "`" + strings.Join(s, "`, `") + "`"
+	}
+	var b strings.Builder
+	b.WriteString("# Metric catalog\n")
+	for _, col := range c.Collectors {
+		fmt.Fprintf(&b, "\n## `%s` collector\n\n", col.Name)
+		if len(col.Metrics) == 0 {
+			b.WriteString("The collector does not send predefined metrics.\n")
+			continue
+		}
+		b.WriteString("| Metric | Unit | Types | Kind | Options | Description |\n")
+		b.WriteString("|--------|------|-------|------|---------|-------------|\n")
+		for _, m := range col.Metrics {
+			unit := escape.Replace(m.Unit)
+			if m.UnitTag && len(unit) > 0 {
+				unit += " (tag)"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s | %s |\n",
+				m.Name, unit, code(m.Types), m.Kind, code(m.Options), escape.Replace(m.Description))
+		}
+	}
+	return b.String()
+}